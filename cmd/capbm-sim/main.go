@@ -0,0 +1,232 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// capbm-sim shows which BareMetalHosts the Machines of a MachineSet would
+// claim, and why the other hosts are rejected, without changing anything.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/controller/machineset"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// hostResult is the outcome of evaluating one BareMetalHost.
+type hostResult struct {
+	host   *bmh.BareMetalHost
+	reason actuator.HostRejection
+	detail string
+}
+
+// simulation is the outcome of placing the Machines of a MachineSet.
+type simulation struct {
+	machineSet       *machinev1beta1.MachineSet
+	hosts            []hostResult
+	rejections       *actuator.RejectionSummary
+	candidates       int
+	replicas         int
+	existingMachines int
+	autoscaleCount   int32
+	autoscaleErr     error
+}
+
+// options are the command line options of capbm-sim.
+type options struct {
+	machineSet string
+	namespace  string
+	replicas   int
+	manifests  string
+}
+
+// parseFlags reads the options from the command line arguments, and writes
+// the usage to output if they are invalid.
+func parseFlags(args []string, output io.Writer) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("capbm-sim", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.machineSet, "machineset", "", "Name of the MachineSet to simulate. Required.")
+	flags.StringVar(&opts.namespace, "namespace", "openshift-machine-api", "Namespace of the MachineSet.")
+	flags.IntVar(&opts.replicas, "replicas", -1, "Number of replicas to simulate. Defaults to the replicas of the MachineSet.")
+	flags.StringVar(&opts.manifests, "manifests", "",
+		"Directory of YAML or JSON manifests to read instead of the cluster. "+
+			"It should contain the MachineSet, its Machines, the BareMetalHosts and their Namespaces.")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if opts.machineSet == "" {
+		fmt.Fprintln(output, "--machineset is required")
+		flags.Usage()
+		return nil, errors.New("--machineset is required")
+	}
+	return opts, nil
+}
+
+func main() {
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	c, err := newClient(newScheme(), opts.manifests)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	ms := &machinev1beta1.MachineSet{}
+	err = c.Get(ctx, client.ObjectKey{Name: opts.machineSet, Namespace: opts.namespace}, ms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to get MachineSet %s/%s: %v\n", opts.namespace, opts.machineSet, err)
+		os.Exit(1)
+	}
+
+	sim, err := simulate(ctx, c, ms, opts.replicas)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sim.print(os.Stdout)
+}
+
+// newScheme returns a scheme with the kinds the simulation reads.
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		corev1.AddToScheme, machinev1beta1.AddToScheme, bmh.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			panic(err)
+		}
+	}
+	return scheme
+}
+
+// newClient returns a client for the cluster, or for the objects in the
+// manifests directory if one is given.
+func newClient(scheme *runtime.Scheme, manifests string) (client.Client, error) {
+	if manifests != "" {
+		objs, err := loadManifests(manifests, scheme)
+		if err != nil {
+			return nil, err
+		}
+		return fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build(), nil
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// simulate evaluates every BareMetalHost the Machines of the MachineSet may
+// claim, using the same rules as the actuator and the autoscaler.
+func simulate(ctx context.Context, c client.Client, ms *machinev1beta1.MachineSet, replicas int) (*simulation, error) {
+	if replicas < 0 {
+		replicas = 1
+		if ms.Spec.Replicas != nil {
+			replicas = int(*ms.Spec.Replicas)
+		}
+	}
+	sim := &simulation{
+		machineSet: ms,
+		rejections: actuator.NewRejectionSummary(),
+		replicas:   replicas,
+	}
+
+	// The rules for a new Machine created from the template of the MachineSet
+	filter, err := actuator.NewMachineSetHostFilter(ms, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ProviderSpec of MachineSet: %w", err)
+	}
+
+	hosts, err := actuator.ListHosts(ctx, c, &ms.Spec.Template.Spec.ProviderSpec, ms.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list BareMetalHosts: %w", err)
+	}
+	for i := range hosts {
+		reason, detail := filter.Reject(&hosts[i])
+		if reason == "" {
+			sim.candidates++
+		}
+		sim.rejections.Add(&hosts[i], reason)
+		sim.hosts = append(sim.hosts, hostResult{host: &hosts[i], reason: reason, detail: detail})
+	}
+
+	msselector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	machines := machinev1beta1.MachineList{}
+	err = c.List(ctx, &machines, client.InNamespace(ms.Namespace), client.MatchingLabelsSelector{Selector: msselector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Machines: %w", err)
+	}
+	sim.existingMachines = len(machines.Items)
+
+	sim.autoscaleCount, sim.autoscaleErr = machineset.CountHosts(ctx, c, ms)
+	return sim, nil
+}
+
+func (sim *simulation) print(out io.Writer) {
+	fmt.Fprintf(out, "MachineSet %s/%s\n\n", sim.machineSet.Namespace, sim.machineSet.Name)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tSTATE\tRESULT")
+	for _, result := range sim.hosts {
+		outcome := "candidate"
+		if result.reason != "" {
+			outcome = fmt.Sprintf("rejected: %s (%s)", result.reason, result.detail)
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\n", result.host.Namespace, result.host.Name,
+			result.host.Status.Provisioning.State, outcome)
+	}
+	w.Flush()
+
+	newMachines := max(sim.replicas-sim.existingMachines, 0)
+	placed := min(newMachines, sim.candidates)
+	fmt.Fprintf(out, "\nCandidate hosts: %d of %d\n", sim.candidates, len(sim.hosts))
+	if summary := sim.rejections.String(); summary != "" {
+		fmt.Fprintf(out, "Rejected hosts: %s\n", summary)
+	}
+	fmt.Fprintf(out, "Replicas: %d (%d existing Machines, %d new)\n", sim.replicas, sim.existingMachines, newMachines)
+	fmt.Fprintf(out, "New Machines that would get a host: %d\n", placed)
+	fmt.Fprintf(out, "New Machines that would not get a host: %d\n", newMachines-placed)
+	if sim.autoscaleErr != nil {
+		fmt.Fprintf(out, "Autoscale count: unknown (%v)\n", sim.autoscaleErr)
+	} else {
+		fmt.Fprintf(out, "Autoscale count: %d\n", sim.autoscaleCount)
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const machineSetManifest = `apiVersion: machine.openshift.io/v1beta1
+kind: MachineSet
+metadata:
+  name: worker
+  namespace: openshift-machine-api
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      machine.openshift.io/cluster-api-machineset: worker
+  template:
+    metadata:
+      labels:
+        machine.openshift.io/cluster-api-machineset: worker
+    spec:
+      providerSpec:
+        value:
+          apiVersion: baremetal.cluster.k8s.io/v1alpha1
+          kind: BareMetalMachineProviderSpec
+          image:
+            url: http://172.22.0.1/images/rhcos.qcow2
+            checksum: http://172.22.0.1/images/rhcos.qcow2.md5sum
+          hostSelector:
+            matchLabels:
+              size: large
+`
+
+const machineManifest = `{
+  "apiVersion": "machine.openshift.io/v1beta1",
+  "kind": "Machine",
+  "metadata": {
+    "name": "worker-0",
+    "namespace": "openshift-machine-api",
+    "labels": {"machine.openshift.io/cluster-api-machineset": "worker"},
+    "annotations": {"metal3.io/BareMetalHost": "openshift-machine-api/host-consumed"}
+  }
+}
+`
+
+// hostsManifest is a List, as written by "oc get -o yaml", followed by a
+// Secret and an object of a kind unknown to the scheme.
+const hostsManifest = `apiVersion: v1
+kind: List
+items:
+- apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: host-available
+    namespace: openshift-machine-api
+    labels:
+      size: large
+  spec:
+    bmc:
+      address: ipmi://192.168.111.1
+      credentialsName: host-available-bmc-secret
+  status:
+    provisioning:
+      state: available
+- apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: host-consumed
+    namespace: openshift-machine-api
+    labels:
+      size: large
+  spec:
+    consumerRef:
+      apiVersion: machine.openshift.io/v1beta1
+      kind: Machine
+      name: worker-0
+      namespace: openshift-machine-api
+  status:
+    provisioning:
+      state: provisioned
+- apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: host-small
+    namespace: openshift-machine-api
+    labels:
+      size: small
+  status:
+    provisioning:
+      state: available
+- apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: host-error
+    namespace: openshift-machine-api
+    labels:
+      size: large
+  status:
+    errorMessage: BMC unreachable
+    provisioning:
+      state: available
+- apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: host-registering
+    namespace: openshift-machine-api
+    labels:
+      size: large
+  status:
+    provisioning:
+      state: registering
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: host-available-bmc-secret
+  namespace: openshift-machine-api
+data:
+  username: YWRtaW4=
+  password: cGFzc3dvcmQ=
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: skipped
+`
+
+// writeManifests writes the test manifests to a new directory, along with a
+// file that is not a manifest.
+func writeManifests(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"machineset.yaml":  machineSetManifest,
+		"machines.json":    machineManifest,
+		"hosts/hosts.yml":  hostsManifest,
+		"hosts/README.txt": "not a manifest",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario      string
+		Args          []string
+		Expected      *options
+		ExpectedError string
+	}{
+		{
+			Scenario: "defaults",
+			Args:     []string{"--machineset", "worker"},
+			Expected: &options{machineSet: "worker", namespace: "openshift-machine-api", replicas: -1},
+		},
+		{
+			Scenario: "all options",
+			Args:     []string{"--machineset=worker", "--namespace=myns", "--replicas=5", "--manifests=/tmp/manifests"},
+			Expected: &options{machineSet: "worker", namespace: "myns", replicas: 5, manifests: "/tmp/manifests"},
+		},
+		{
+			Scenario:      "missing machineset",
+			Args:          []string{"--replicas", "2"},
+			ExpectedError: "--machineset is required",
+		},
+		{
+			Scenario:      "invalid replicas",
+			Args:          []string{"--machineset", "worker", "--replicas", "many"},
+			ExpectedError: `invalid value "many" for flag -replicas`,
+		},
+		{
+			Scenario:      "unknown flag",
+			Args:          []string{"--machineset", "worker", "--machines", "2"},
+			ExpectedError: "flag provided but not defined: -machines",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			opts, err := parseFlags(tc.Args, output)
+			if tc.ExpectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+					t.Errorf("expected error %q, got %v", tc.ExpectedError, err)
+				}
+				if !strings.Contains(output.String(), "Usage of capbm-sim") {
+					t.Errorf("expected the usage to be written, got %q", output.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *opts != *tc.Expected {
+				t.Errorf("expected %+v, got %+v", *tc.Expected, *opts)
+			}
+		})
+	}
+
+	_, err := parseFlags([]string{"--help"}, &bytes.Buffer{})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp for --help, got %v", err)
+	}
+}
+
+func TestLoadManifests(t *testing.T) {
+	objs, err := loadManifests(writeManifests(t), newScheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]*bmh.BareMetalHost{}
+	var machineSet *machinev1beta1.MachineSet
+	var machine *machinev1beta1.Machine
+	var secret *corev1.Secret
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *bmh.BareMetalHost:
+			hosts[o.Name] = o
+		case *machinev1beta1.MachineSet:
+			machineSet = o
+		case *machinev1beta1.Machine:
+			machine = o
+		case *corev1.Secret:
+			secret = o
+		default:
+			t.Errorf("unexpected object %T", obj)
+		}
+	}
+
+	if len(hosts) != 5 {
+		t.Errorf("expected the 5 hosts of the List, got %d", len(hosts))
+	}
+	if host := hosts["host-available"]; host == nil {
+		t.Error("host-available not loaded")
+	} else {
+		if host.Namespace != "openshift-machine-api" || host.Labels["size"] != "large" {
+			t.Errorf("unexpected metadata for host-available: %+v", host.ObjectMeta)
+		}
+		if host.Spec.BMC.CredentialsName != "host-available-bmc-secret" {
+			t.Errorf("expected BMC credentials host-available-bmc-secret, got %q", host.Spec.BMC.CredentialsName)
+		}
+		if host.Status.Provisioning.State != bmh.StateAvailable {
+			t.Errorf("expected host-available to be available, got %q", host.Status.Provisioning.State)
+		}
+	}
+	if host := hosts["host-consumed"]; host == nil || host.Spec.ConsumerRef == nil || host.Spec.ConsumerRef.Name != "worker-0" {
+		t.Errorf("expected host-consumed to be consumed by worker-0, got %+v", host)
+	}
+	if host := hosts["host-error"]; host == nil || host.Status.ErrorMessage != "BMC unreachable" {
+		t.Errorf("expected host-error to have an error message, got %+v", host)
+	}
+
+	if secret == nil {
+		t.Error("Secret not loaded")
+	} else if string(secret.Data["username"]) != "admin" || string(secret.Data["password"]) != "password" {
+		t.Errorf("unexpected Secret data: %v", secret.Data)
+	}
+
+	if machine == nil {
+		t.Error("Machine not loaded")
+	} else {
+		if machine.Labels["machine.openshift.io/cluster-api-machineset"] != "worker" {
+			t.Errorf("unexpected Machine labels: %v", machine.Labels)
+		}
+		if machine.Annotations[actuator.HostAnnotation] != "openshift-machine-api/host-consumed" {
+			t.Errorf("unexpected Machine annotations: %v", machine.Annotations)
+		}
+	}
+
+	if machineSet == nil {
+		t.Error("MachineSet not loaded")
+	} else {
+		if machineSet.Spec.Replicas == nil || *machineSet.Spec.Replicas != 3 {
+			t.Errorf("expected 3 replicas, got %v", machineSet.Spec.Replicas)
+		}
+		if !actuator.IsBareMetalProviderSpec(&machineSet.Spec.Template.Spec.ProviderSpec) {
+			t.Error("expected a baremetal ProviderSpec in the MachineSet template")
+		}
+	}
+}
+
+func TestLoadManifestsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.yaml")
+	if err := os.WriteFile(path, []byte("apiVersion: v1\nkind: Secret\nmetadata: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := loadManifests(dir, newScheme())
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected an error naming %s, got %v", path, err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	c, err := newClient(newScheme(), writeManifests(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := &machinev1beta1.MachineSet{}
+	key := client.ObjectKey{Name: "worker", Namespace: "openshift-machine-api"}
+	if err := c.Get(context.TODO(), key, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	sim, err := simulate(context.TODO(), c, ms, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.replicas != 3 || sim.existingMachines != 1 || sim.candidates != 1 || len(sim.hosts) != 5 {
+		t.Errorf("expected 3 replicas, 1 existing Machine and 1 candidate among 5 hosts, got %d, %d, %d and %d",
+			sim.replicas, sim.existingMachines, sim.candidates, len(sim.hosts))
+	}
+	reasons := map[string]actuator.HostRejection{}
+	for _, result := range sim.hosts {
+		reasons[result.host.Name] = result.reason
+	}
+	expectedReasons := map[string]actuator.HostRejection{
+		"host-available":   "",
+		"host-consumed":    actuator.HostConsumed,
+		"host-small":       actuator.HostSelectorMismatch,
+		"host-error":       actuator.HostHasError,
+		"host-registering": actuator.HostWrongState,
+	}
+	for name, expected := range expectedReasons {
+		if reasons[name] != expected {
+			t.Errorf("expected %s to be rejected as %q, got %q", name, expected, reasons[name])
+		}
+	}
+
+	out := &bytes.Buffer{}
+	sim.print(out)
+	for _, expected := range []string{
+		"MachineSet openshift-machine-api/worker",
+		"openshift-machine-api/host-available",
+		"rejected: consumed (consumed by Machine openshift-machine-api/worker-0)",
+		"Candidate hosts: 1 of 5",
+		"Replicas: 3 (1 existing Machines, 2 new)",
+		"New Machines that would get a host: 1",
+		"New Machines that would not get a host: 1",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output:\n%s", expected, out.String())
+		}
+	}
+
+	// More replicas than hosts
+	sim, err = simulate(context.TODO(), c, ms, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	sim.print(out)
+	if !strings.Contains(out.String(), "Replicas: 1 (1 existing Machines, 0 new)") {
+		t.Errorf("expected no new Machines for 1 replica:\n%s", out.String())
+	}
+}
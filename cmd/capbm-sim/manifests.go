@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// loadManifests reads every object in the YAML and JSON files under dir,
+// including the items of List objects such as the output of
+// "oc get -o yaml". Objects of kinds unknown to the scheme are skipped.
+func loadManifests(dir string, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	objs := []runtime.Object{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		fileObjs, err := loadManifestFile(path, scheme)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		objs = append(objs, fileObjs...)
+		return nil
+	})
+	return objs, err
+}
+
+func loadManifestFile(path string, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objs := []runtime.Object{}
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		if err := decoder.Decode(&u.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, err
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		items := []unstructured.Unstructured{*u}
+		if u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, err
+			}
+			items = list.Items
+		}
+		for _, item := range items {
+			obj, err := scheme.New(item.GroupVersionKind())
+			if runtime.IsNotRegisteredError(err) {
+				fmt.Fprintf(os.Stderr, "Skipping %s %s/%s in %s\n",
+					item.GetKind(), item.GetNamespace(), item.GetName(), path)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, obj); err != nil {
+				return nil, fmt.Errorf("%s %s/%s: %w", item.GetKind(), item.GetNamespace(), item.GetName(), err)
+			}
+			objs = append(objs, obj)
+		}
+	}
+}
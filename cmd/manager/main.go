@@ -40,9 +40,11 @@ import (
 	"github.com/openshift/library-go/pkg/features"
 	maomachine "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
+	k8scache "k8s.io/client-go/tools/cache"
 	"k8s.io/component-base/featuregate"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
@@ -120,6 +122,12 @@ func main() {
 	webhookCertdir := flag.String("webhook-cert-dir", defaultWebhookCertdir,
 		"Webhook cert dir, only used when webhook-enabled is true.")
 
+	providerDefaults := flag.String("provider-defaults-configmap", "openshift-machine-api/baremetal-provider-defaults",
+		"Namespace and name of the ConfigMap holding the defaults for the providerSpec of new Machines and MachineSets, only used when webhook-enabled is true.")
+
+	remediationLimits := flag.String("remediation-limits-configmap", "openshift-machine-api/baremetal-remediation-limits",
+		"Namespace and name of the ConfigMap holding the limits on the number of hosts remediated at once.")
+
 	tlsCipherSuites := flag.String("tls-cipher-suites", "",
 		"Comma-separated list of TLS cipher suites.")
 
@@ -212,8 +220,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	limitsNamespace, limitsName, err := k8scache.SplitMetaNamespaceKey(*remediationLimits)
+	if err != nil {
+		entryLog.Error(err, "invalid remediation-limits-configmap")
+		os.Exit(1)
+	}
+	remediationLimiter := &baremetal.RemediationLimiter{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		ConfigMap: types.NamespacedName{Namespace: limitsNamespace, Name: limitsName},
+	}
+
 	machineActuator, err := machine.NewActuator(machine.ActuatorParams{
-		Client: mgr.GetClient(),
+		Client:              mgr.GetClient(),
+		EventRecorder:       mgr.GetEventRecorderFor("baremetal-controller"),
+		RemediationAdmitter: remediationLimiter,
 	})
 	if err != nil {
 		panic(err)
@@ -248,7 +269,7 @@ func main() {
 
 	if err := (&metal3remediation.Metal3RemediationReconciler{
 		Client:         mgr.GetClient(),
-		ManagerFactory: baremetal.NewManagerFactory(mgr.GetClient()),
+		ManagerFactory: baremetal.NewManagerFactory(mgr.GetClient(), remediationLimiter),
 		Log:            ctrl.Log.WithName("controllers").WithName("Metal3Remediation"),
 	}).SetupWithManager(ctx, mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "Metal3Remediation")
@@ -265,6 +286,26 @@ func main() {
 			log.Error(err, "unable to create webhook", "webhook", "Metal3RemediationTemplate")
 			os.Exit(1)
 		}
+
+		defaultsNamespace, defaultsName, err := k8scache.SplitMetaNamespaceKey(*providerDefaults)
+		if err != nil {
+			log.Error(err, "invalid provider-defaults-configmap")
+			os.Exit(1)
+		}
+		defaulter := &capbmwebhook.ProviderSpecDefaulter{
+			Client:    mgr.GetAPIReader(),
+			ConfigMap: types.NamespacedName{Namespace: defaultsNamespace, Name: defaultsName},
+		}
+
+		if err := (&capbmwebhook.Machine{Defaults: defaulter}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "Machine")
+			os.Exit(1)
+		}
+
+		if err := (&capbmwebhook.MachineSet{Defaults: defaulter, Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "MachineSet")
+			os.Exit(1)
+		}
 	}
 
 	if err := mgr.AddReadyzCheck("ping", healthz.Ping); err != nil {
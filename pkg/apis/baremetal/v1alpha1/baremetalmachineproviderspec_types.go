@@ -18,10 +18,17 @@ package v1alpha1
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // +genclient
@@ -48,6 +55,55 @@ type BareMetalMachineProviderSpec struct {
 	// This is used to limit the set of BareMetalHost objects considered for
 	// claiming for a Machine.
 	HostSelector HostSelector `json:"hostSelector,omitempty"`
+
+	// AdoptExternallyProvisioned makes the Machine claim a BareMetalHost that
+	// was provisioned outside of the cluster (Spec.ExternallyProvisioned),
+	// without changing its image or power state. Image is not required in
+	// this mode, and the host is never deprovisioned when the Machine is
+	// deleted.
+	AdoptExternallyProvisioned bool `json:"adoptExternallyProvisioned,omitempty"`
+
+	// UseHostClaim makes the Machine claim a BareMetalHost by creating a
+	// HostClaim with the same name, instead of writing the ConsumerRef and
+	// deployment fields of the host directly. The HostClaim is deleted when
+	// the Machine is deleted. It cannot be combined with
+	// AdoptExternallyProvisioned or a DeletionPolicy other than Deprovision,
+	// and HostSelector.HostNamespaces may list at most one namespace.
+	UseHostClaim bool `json:"useHostClaim,omitempty"`
+
+	// DeletionPolicy controls what happens to the BareMetalHost when the
+	// Machine is deleted. It defaults to Deprovision.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy describes how the BareMetalHost claimed by a Machine is
+// handled when the Machine is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDeprovision wipes the host and returns it to the pool of
+	// available hosts. This is the default.
+	DeletionPolicyDeprovision DeletionPolicy = "Deprovision"
+
+	// DeletionPolicyRetain releases the host without deprovisioning it, and
+	// marks it so that it is not claimed again until an administrator clears
+	// the mark.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+
+	// DeletionPolicyDetach releases the host without deprovisioning it or
+	// changing its power state.
+	DeletionPolicyDetach DeletionPolicy = "Detach"
+)
+
+// IsValid returns an error if the DeletionPolicy is not one of the known
+// policies. An empty policy is valid and means Deprovision.
+func (p DeletionPolicy) IsValid() error {
+	switch p {
+	case "", DeletionPolicyDeprovision, DeletionPolicyRetain, DeletionPolicyDetach:
+		return nil
+	}
+	return fmt.Errorf("Unknown DeletionPolicy %q, must be one of %s, %s or %s",
+		p, DeletionPolicyDeprovision, DeletionPolicyRetain, DeletionPolicyDetach)
 }
 
 // HostSelector specifies matching criteria for labels on BareMetalHosts.
@@ -59,12 +115,57 @@ type HostSelector struct {
 
 	// Label match expressions that must be true on a chosen BareMetalHost
 	MatchExpressions []HostSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// HostNamespaces lists the namespaces in which to look for
+	// BareMetalHosts. If empty, only the Machine's own namespace is used.
+	// Hosts in another namespace are only considered if that namespace
+	// allows Machines from the Machine's namespace to consume them.
+	HostNamespaces []string `json:"hostNamespaces,omitempty"`
+
+	// CELSelector is a CEL expression that must evaluate to true for a
+	// chosen BareMetalHost, given the host, with its spec, status and
+	// hardware details, as the "host" variable. For example
+	// "host.status.hardware.nics.filter(n, n.speedGbps >= 25).size() >= 2".
+	CELSelector string `json:"celSelector,omitempty"`
 }
 
+// HostSelectorRequirement is a label match expression. The Operator is one of
+// In, NotIn, Exists, DoesNotExist, Gt or Lt, in any case. In and NotIn take
+// one or more values, Exists and DoesNotExist take none, and Gt and Lt take a
+// single integer that the label value is compared with.
 type HostSelectorRequirement struct {
 	Key      string             `json:"key"`
 	Operator selection.Operator `json:"operator"`
-	Values   []string           `json:"values"`
+	Values   []string           `json:"values,omitempty"`
+}
+
+// selectorOperators maps the lowercased Kubernetes names of operators to the
+// operators of label selectors.
+var selectorOperators = map[string]selection.Operator{
+	"in":           selection.In,
+	"notin":        selection.NotIn,
+	"exists":       selection.Exists,
+	"doesnotexist": selection.DoesNotExist,
+	"gt":           selection.GreaterThan,
+	"lt":           selection.LessThan,
+}
+
+// SelectorOperator returns the label selector operator for the Operator of
+// the requirement. Operators of the label selector syntax, such as "=" or
+// "!=", are returned unchanged.
+func (r *HostSelectorRequirement) SelectorOperator() selection.Operator {
+	lowercaseOperator := strings.ToLower(string(r.Operator))
+	if op, ok := selectorOperators[lowercaseOperator]; ok {
+		return op
+	}
+	return selection.Operator(lowercaseOperator)
+}
+
+// Requirement returns the label selector requirement for the match
+// expression. The errors are field errors with paths relative to the path
+// option.
+func (r *HostSelectorRequirement) Requirement(opts ...field.PathOption) (*labels.Requirement, error) {
+	return labels.NewRequirement(r.Key, r.SelectorOperator(), r.Values, opts...)
 }
 
 // Image holds the details of an image to use during provisioning.
@@ -74,8 +175,15 @@ type Image struct {
 
 	// Checksum is a md5sum value or a URL to retrieve one.
 	Checksum string `json:"checksum"`
+
+	// ChecksumType is the checksum algorithm for the image: md5, sha256,
+	// sha512 or auto. If empty, the BareMetalHost's default applies.
+	ChecksumType string `json:"checksumType,omitempty"`
 }
 
+// checksumTypes are the valid values of Image.ChecksumType.
+var checksumTypes = []string{"md5", "sha256", "sha512", "auto"}
+
 // Custom deploy is a description of a customized deploy process.
 type CustomDeploy struct {
 	// Custom deploy method name.
@@ -87,17 +195,106 @@ type CustomDeploy struct {
 // IsValid returns an error if the object is not valid, otherwise nil. The
 // string representation of the error is suitable for human consumption.
 func (s *BareMetalMachineProviderSpec) IsValid() error {
-	missing := []string{}
-	if s.CustomDeploy.Method == "" && s.Image.URL == "" {
-		missing = append(missing, "Image.URL")
+	if errs := s.Validate(nil); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	return nil
+}
+
+// Validate returns the problems with the fields of the object, with paths
+// relative to fldPath.
+func (s *BareMetalMachineProviderSpec) Validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	needsImage := s.CustomDeploy.Method == "" && !s.AdoptExternallyProvisioned
+	if needsImage && s.Image.URL == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("image", "url"),
+			"required unless customDeploy or adoptExternallyProvisioned is set"))
 	}
-	if s.CustomDeploy.Method == "" && s.Image.Checksum == "" {
-		missing = append(missing, "Image.Checksum")
+	if needsImage && s.Image.Checksum == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("image", "checksum"),
+			"required unless customDeploy or adoptExternallyProvisioned is set"))
 	}
-	if len(missing) > 0 {
-		return fmt.Errorf("Missing fields from ProviderSpec: %v", missing)
+
+	if s.Image.ChecksumType != "" && !slices.Contains(checksumTypes, s.Image.ChecksumType) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("image", "checksumType"),
+			s.Image.ChecksumType, checksumTypes))
 	}
-	return nil
+
+	allErrs = append(allErrs, s.HostSelector.Validate(fldPath.Child("hostSelector"))...)
+
+	if s.UseHostClaim {
+		useHostClaimPath := fldPath.Child("useHostClaim")
+		if s.AdoptExternallyProvisioned {
+			allErrs = append(allErrs, field.Forbidden(useHostClaimPath,
+				"cannot be combined with adoptExternallyProvisioned"))
+		}
+		if s.DeletionPolicy != "" && s.DeletionPolicy != DeletionPolicyDeprovision {
+			allErrs = append(allErrs, field.Forbidden(useHostClaimPath,
+				fmt.Sprintf("requires deletionPolicy %s", DeletionPolicyDeprovision)))
+		}
+		if len(s.HostSelector.HostNamespaces) > 1 {
+			allErrs = append(allErrs, field.TooMany(fldPath.Child("hostSelector", "hostNamespaces"),
+				len(s.HostSelector.HostNamespaces), 1))
+		}
+		if s.HostSelector.CELSelector != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("hostSelector", "celSelector"),
+				"cannot be combined with useHostClaim"))
+		}
+	}
+
+	if err := s.DeletionPolicy.IsValid(); err != nil {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("deletionPolicy"), s.DeletionPolicy,
+			[]DeletionPolicy{DeletionPolicyDeprovision, DeletionPolicyRetain, DeletionPolicyDetach}))
+	}
+	return allErrs
+}
+
+// Validate returns the problems with the labels, operators and values of the
+// HostSelector, with paths relative to fldPath. The CEL expression is
+// compiled by the callers, so that the API does not depend on CEL.
+// Operators are not case sensitive.
+func (h *HostSelector) Validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	matchLabelsPath := fldPath.Child("matchLabels")
+	for _, key := range slices.Sorted(maps.Keys(h.MatchLabels)) {
+		_, err := labels.NewRequirement(key, selection.Equals, []string{h.MatchLabels[key]},
+			field.WithPath(matchLabelsPath.Key(key)))
+		allErrs = append(allErrs, requirementErrors(err)...)
+	}
+
+	for i, req := range h.MatchExpressions {
+		_, err := req.Requirement(field.WithPath(fldPath.Child("matchExpressions").Index(i)))
+		allErrs = append(allErrs, requirementErrors(err)...)
+	}
+
+	for i, ns := range h.HostNamespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("hostNamespaces").Index(i), ns, msg))
+		}
+	}
+	return allErrs
+}
+
+// requirementErrors returns the field errors in the error returned by
+// labels.NewRequirement.
+func requirementErrors(err error) field.ErrorList {
+	if err == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		for _, e := range agg.Errors() {
+			if fieldErr, ok := e.(*field.Error); ok {
+				allErrs = append(allErrs, fieldErr)
+			} else {
+				allErrs = append(allErrs, field.InternalError(nil, e))
+			}
+		}
+		return allErrs
+	}
+	return append(allErrs, field.InternalError(nil, err))
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
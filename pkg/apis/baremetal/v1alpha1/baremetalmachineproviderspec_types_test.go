@@ -144,6 +144,151 @@ func TestProviderSpecIsValid(t *testing.T) {
 			ErrorExpected: false,
 			Name:          "HostSelector Multiple MatchLabels provided",
 		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+			ErrorExpected: false,
+			Name:          "Retain DeletionPolicy provided",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				DeletionPolicy: "Shred",
+			},
+			ErrorExpected: true,
+			Name:          "unknown DeletionPolicy",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				AdoptExternallyProvisioned: true,
+			},
+			ErrorExpected: false,
+			Name:          "Image not required when adopting externally provisioned hosts",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				HostSelector: HostSelector{
+					HostNamespaces: []string{"hosts", "more-hosts"},
+				},
+			},
+			ErrorExpected: false,
+			Name:          "HostSelector HostNamespaces provided",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				HostSelector: HostSelector{
+					HostNamespaces: []string{"Not_A_Namespace"},
+				},
+			},
+			ErrorExpected: true,
+			Name:          "invalid HostSelector HostNamespaces",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				UseHostClaim: true,
+			},
+			ErrorExpected: false,
+			Name:          "UseHostClaim provided",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				UseHostClaim:               true,
+				AdoptExternallyProvisioned: true,
+			},
+			ErrorExpected: true,
+			Name:          "UseHostClaim with AdoptExternallyProvisioned",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				UseHostClaim:   true,
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+			ErrorExpected: true,
+			Name:          "UseHostClaim with Retain DeletionPolicy",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				UseHostClaim: true,
+				HostSelector: HostSelector{
+					HostNamespaces: []string{"hosts", "more-hosts"},
+				},
+			},
+			ErrorExpected: true,
+			Name:          "UseHostClaim with several HostNamespaces",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				HostSelector: HostSelector{
+					MatchLabels: map[string]string{"role": "worker"},
+					MatchExpressions: []HostSelectorRequirement{
+						{Key: "rack", Operator: "In", Values: []string{"a", "b"}},
+						{Key: "gpu", Operator: "exists"},
+					},
+				},
+			},
+			ErrorExpected: false,
+			Name:          "HostSelector provided",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				HostSelector: HostSelector{
+					MatchLabels: map[string]string{"not a key": "worker"},
+				},
+			},
+			ErrorExpected: true,
+			Name:          "invalid HostSelector MatchLabels key",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				HostSelector: HostSelector{
+					MatchExpressions: []HostSelectorRequirement{
+						{Key: "rack", Operator: "contains", Values: []string{"a"}},
+					},
+				},
+			},
+			ErrorExpected: true,
+			Name:          "invalid HostSelector MatchExpressions operator",
+		},
 	}
 
 	for _, tc := range cases {
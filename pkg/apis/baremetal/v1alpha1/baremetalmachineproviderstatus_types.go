@@ -27,6 +27,12 @@ import (
 type BareMetalMachineProviderStatus struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// HostRejections counts the BareMetalHosts that could not be claimed by
+	// the Machine the last time no host was available, by reason. It is
+	// cleared once a host is claimed.
+	// +optional
+	HostRejections map[string]int `json:"hostRejections,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -95,6 +95,13 @@ func (in *BareMetalMachineProviderStatus) DeepCopyInto(out *BareMetalMachineProv
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.HostRejections != nil {
+		in, out := &in.HostRejections, &out.HostRejections
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BareMetalMachineProviderStatus.
@@ -179,6 +186,11 @@ func (in *HostSelector) DeepCopyInto(out *HostSelector) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.HostNamespaces != nil {
+		in, out := &in.HostNamespaces, &out.HostNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSelector.
@@ -0,0 +1,192 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BareMetalMachineProviderSpec holds data that the actuator needs to provision
+// and manage a Machine.
+type BareMetalMachineProviderSpec struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Image is the image to be provisioned. It is required unless
+	// Deploy.CustomDeploy is set or Deploy.AdoptExternallyProvisioned is
+	// true.
+	// +optional
+	Image *Image `json:"image,omitempty"`
+
+	// Deploy controls how the BareMetalHost is deployed.
+	// +optional
+	Deploy Deploy `json:"deploy,omitempty"`
+
+	// DataSecrets references the Secrets written to the BareMetalHost.
+	// +optional
+	DataSecrets DataSecrets `json:"dataSecrets,omitempty"`
+
+	// Selection controls which BareMetalHost is claimed for the Machine, and
+	// how.
+	// +optional
+	Selection Selection `json:"selection,omitempty"`
+
+	// Lifecycle holds the policies applied to the BareMetalHost over the
+	// life of the Machine.
+	// +optional
+	Lifecycle Lifecycle `json:"lifecycle,omitempty"`
+}
+
+// Image holds the details of an image to use during provisioning.
+type Image struct {
+	// URL is a location of an image to deploy.
+	URL string `json:"url"`
+
+	// Checksum is a md5sum value or a URL to retrieve one.
+	Checksum string `json:"checksum"`
+
+	// ChecksumType is the checksum algorithm for the image: md5, sha256,
+	// sha512 or auto. If empty, the BareMetalHost's default applies.
+	// +optional
+	ChecksumType string `json:"checksumType,omitempty"`
+}
+
+// Deploy controls how the BareMetalHost is deployed.
+type Deploy struct {
+	// CustomDeploy is used instead of writing Image to the host.
+	// +optional
+	CustomDeploy *CustomDeploy `json:"customDeploy,omitempty"`
+
+	// AdoptExternallyProvisioned makes the Machine claim a BareMetalHost
+	// that was provisioned outside of the cluster, without changing its
+	// image or power state. The host is never deprovisioned when the
+	// Machine is deleted.
+	// +optional
+	AdoptExternallyProvisioned bool `json:"adoptExternallyProvisioned,omitempty"`
+}
+
+// CustomDeploy is a description of a customized deploy process.
+type CustomDeploy struct {
+	// Custom deploy method name.
+	// This name is specific to the deploy ramdisk used. If you don't have
+	// a custom deploy ramdisk, you shouldn't use CustomDeploy.
+	Method string `json:"method"`
+}
+
+// DataSecrets references the Secrets written to the BareMetalHost.
+type DataSecrets struct {
+	// UserData references the Secret that holds user data needed by the
+	// bare metal operator. The Namespace is optional; it will default to
+	// the Machine's namespace if not specified.
+	// +optional
+	UserData *corev1.SecretReference `json:"userData,omitempty"`
+}
+
+// ClaimMode describes how a Machine claims its BareMetalHost.
+type ClaimMode string
+
+const (
+	// ClaimModeDirect writes the ConsumerRef and deployment fields of the
+	// BareMetalHost directly. This is the default.
+	ClaimModeDirect ClaimMode = "Direct"
+
+	// ClaimModeHostClaim creates a HostClaim with the same name as the
+	// Machine, which is bound to a BareMetalHost.
+	ClaimModeHostClaim ClaimMode = "HostClaim"
+)
+
+// Selection controls which BareMetalHost is claimed for a Machine.
+type Selection struct {
+	// Key/value pairs of labels that must exist on a chosen BareMetalHost
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// Label match expressions that must be true on a chosen BareMetalHost
+	// +optional
+	MatchExpressions []HostSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// HostNamespaces lists the namespaces in which to look for
+	// BareMetalHosts. If empty, only the Machine's own namespace is used.
+	// +optional
+	HostNamespaces []string `json:"hostNamespaces,omitempty"`
+
+	// CELSelector is a CEL expression that must evaluate to true for a
+	// chosen BareMetalHost, given the host as the "host" variable.
+	// +optional
+	CELSelector string `json:"celSelector,omitempty"`
+
+	// ClaimMode is how the BareMetalHost is claimed. It defaults to Direct.
+	// HostClaim cannot be combined with Deploy.AdoptExternallyProvisioned,
+	// a CELSelector or a DeletionPolicy other than Deprovision, and allows
+	// at most one entry in HostNamespaces.
+	// +optional
+	ClaimMode ClaimMode `json:"claimMode,omitempty"`
+}
+
+// HostSelectorRequirement is a label match expression.
+type HostSelectorRequirement struct {
+	Key      string             `json:"key"`
+	Operator selection.Operator `json:"operator"`
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// DeletionPolicy describes how the BareMetalHost claimed by a Machine is
+// handled when the Machine is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDeprovision wipes the host and returns it to the pool of
+	// available hosts. This is the default.
+	DeletionPolicyDeprovision DeletionPolicy = "Deprovision"
+
+	// DeletionPolicyRetain releases the host without deprovisioning it, and
+	// marks it so that it is not claimed again until an administrator clears
+	// the mark.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+
+	// DeletionPolicyDetach releases the host without deprovisioning it or
+	// changing its power state.
+	DeletionPolicyDetach DeletionPolicy = "Detach"
+)
+
+// Lifecycle holds the policies applied to the BareMetalHost over the life of
+// a Machine.
+type Lifecycle struct {
+	// DeletionPolicy controls what happens to the BareMetalHost when the
+	// Machine is deleted. It defaults to Deprovision.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BareMetalMachineProviderSpecList contains a list of BareMetalMachineProviderSpec
+type BareMetalMachineProviderSpecList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BareMetalMachineProviderSpec `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BareMetalMachineProviderSpec{}, &BareMetalMachineProviderSpecList{})
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var _ conversion.Convertible = &BareMetalMachineProviderSpec{}
+
+// ConvertTo converts this BareMetalMachineProviderSpec to the v1alpha1
+// version, which is the one the actuator works with.
+func (src *BareMetalMachineProviderSpec) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.BareMetalMachineProviderSpec)
+	if !ok {
+		return fmt.Errorf("unsupported conversion to %T", dstRaw)
+	}
+	*dst = v1alpha1.BareMetalMachineProviderSpec{}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.APIVersion = v1alpha1.SchemeGroupVersion.String()
+	dst.Kind = "BareMetalMachineProviderSpec"
+
+	if src.Image != nil {
+		dst.Image = v1alpha1.Image{
+			URL:          src.Image.URL,
+			Checksum:     src.Image.Checksum,
+			ChecksumType: src.Image.ChecksumType,
+		}
+	}
+	if src.Deploy.CustomDeploy != nil {
+		dst.CustomDeploy.Method = src.Deploy.CustomDeploy.Method
+	}
+	dst.AdoptExternallyProvisioned = src.Deploy.AdoptExternallyProvisioned
+	dst.UserData = src.DataSecrets.UserData.DeepCopy()
+
+	dst.HostSelector = v1alpha1.HostSelector{
+		HostNamespaces: slices.Clone(src.Selection.HostNamespaces),
+		CELSelector:    src.Selection.CELSelector,
+	}
+	if src.Selection.MatchLabels != nil {
+		dst.HostSelector.MatchLabels = map[string]string{}
+		for key, value := range src.Selection.MatchLabels {
+			dst.HostSelector.MatchLabels[key] = value
+		}
+	}
+	for _, req := range src.Selection.MatchExpressions {
+		dst.HostSelector.MatchExpressions = append(dst.HostSelector.MatchExpressions,
+			v1alpha1.HostSelectorRequirement{
+				Key:      req.Key,
+				Operator: req.Operator,
+				Values:   slices.Clone(req.Values),
+			})
+	}
+	dst.UseHostClaim = src.Selection.ClaimMode == ClaimModeHostClaim
+
+	dst.DeletionPolicy = v1alpha1.DeletionPolicy(src.Lifecycle.DeletionPolicy)
+	return nil
+}
+
+// ConvertFrom converts a v1alpha1 BareMetalMachineProviderSpec to this
+// version. Empty image and customDeploy fields are left out, and the default
+// Direct ClaimMode is left empty.
+func (dst *BareMetalMachineProviderSpec) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.BareMetalMachineProviderSpec)
+	if !ok {
+		return fmt.Errorf("unsupported conversion from %T", srcRaw)
+	}
+	*dst = BareMetalMachineProviderSpec{}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.APIVersion = SchemeGroupVersion.String()
+	dst.Kind = "BareMetalMachineProviderSpec"
+
+	if src.Image != (v1alpha1.Image{}) {
+		dst.Image = &Image{
+			URL:          src.Image.URL,
+			Checksum:     src.Image.Checksum,
+			ChecksumType: src.Image.ChecksumType,
+		}
+	}
+	if src.CustomDeploy.Method != "" {
+		dst.Deploy.CustomDeploy = &CustomDeploy{Method: src.CustomDeploy.Method}
+	}
+	dst.Deploy.AdoptExternallyProvisioned = src.AdoptExternallyProvisioned
+	dst.DataSecrets.UserData = src.UserData.DeepCopy()
+
+	dst.Selection.HostNamespaces = slices.Clone(src.HostSelector.HostNamespaces)
+	dst.Selection.CELSelector = src.HostSelector.CELSelector
+	if src.HostSelector.MatchLabels != nil {
+		dst.Selection.MatchLabels = map[string]string{}
+		for key, value := range src.HostSelector.MatchLabels {
+			dst.Selection.MatchLabels[key] = value
+		}
+	}
+	for _, req := range src.HostSelector.MatchExpressions {
+		dst.Selection.MatchExpressions = append(dst.Selection.MatchExpressions,
+			HostSelectorRequirement{
+				Key:      req.Key,
+				Operator: req.Operator,
+				Values:   slices.Clone(req.Values),
+			})
+	}
+	if src.UseHostClaim {
+		dst.Selection.ClaimMode = ClaimModeHostClaim
+	}
+
+	dst.Lifecycle.DeletionPolicy = DeletionPolicy(src.DeletionPolicy)
+	return nil
+}
+
+// checksumTypes are the valid values of Image.ChecksumType.
+var checksumTypes = []string{"md5", "sha256", "sha512", "auto"}
+
+// Validate returns the problems with the fields of the object, with paths
+// relative to fldPath.
+func (s *BareMetalMachineProviderSpec) Validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	imagePath := fldPath.Child("image")
+	needsImage := s.Deploy.CustomDeploy == nil && !s.Deploy.AdoptExternallyProvisioned
+	switch {
+	case s.Image == nil && needsImage:
+		allErrs = append(allErrs, field.Required(imagePath,
+			"required unless deploy.customDeploy or deploy.adoptExternallyProvisioned is set"))
+	case s.Image != nil:
+		if s.Image.URL == "" {
+			allErrs = append(allErrs, field.Required(imagePath.Child("url"), ""))
+		}
+		if s.Image.Checksum == "" {
+			allErrs = append(allErrs, field.Required(imagePath.Child("checksum"), ""))
+		}
+		if s.Image.ChecksumType != "" && !slices.Contains(checksumTypes, s.Image.ChecksumType) {
+			allErrs = append(allErrs, field.NotSupported(imagePath.Child("checksumType"),
+				s.Image.ChecksumType, checksumTypes))
+		}
+	}
+	if s.Deploy.CustomDeploy != nil && s.Deploy.CustomDeploy.Method == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("deploy", "customDeploy", "method"), ""))
+	}
+
+	selectionPath := fldPath.Child("selection")
+	hub := &v1alpha1.BareMetalMachineProviderSpec{}
+	if err := s.ConvertTo(hub); err != nil {
+		return append(allErrs, field.InternalError(fldPath, err))
+	}
+	// The fields of a v1alpha1 HostSelector have the same names as those of
+	// a Selection.
+	allErrs = append(allErrs, hub.HostSelector.Validate(selectionPath)...)
+
+	switch s.Selection.ClaimMode {
+	case "", ClaimModeDirect:
+	case ClaimModeHostClaim:
+		claimModePath := selectionPath.Child("claimMode")
+		if s.Deploy.AdoptExternallyProvisioned {
+			allErrs = append(allErrs, field.Forbidden(claimModePath,
+				"HostClaim cannot be combined with deploy.adoptExternallyProvisioned"))
+		}
+		if s.Lifecycle.DeletionPolicy != "" && s.Lifecycle.DeletionPolicy != DeletionPolicyDeprovision {
+			allErrs = append(allErrs, field.Forbidden(claimModePath,
+				fmt.Sprintf("HostClaim requires lifecycle.deletionPolicy %s", DeletionPolicyDeprovision)))
+		}
+		if len(s.Selection.HostNamespaces) > 1 {
+			allErrs = append(allErrs, field.TooMany(selectionPath.Child("hostNamespaces"),
+				len(s.Selection.HostNamespaces), 1))
+		}
+		if s.Selection.CELSelector != "" {
+			allErrs = append(allErrs, field.Forbidden(claimModePath,
+				"HostClaim cannot be combined with selection.celSelector"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(selectionPath.Child("claimMode"), s.Selection.ClaimMode,
+			[]ClaimMode{ClaimModeDirect, ClaimModeHostClaim}))
+	}
+
+	switch s.Lifecycle.DeletionPolicy {
+	case "", DeletionPolicyDeprovision, DeletionPolicyRetain, DeletionPolicyDetach:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("lifecycle", "deletionPolicy"),
+			s.Lifecycle.DeletionPolicy,
+			[]DeletionPolicy{DeletionPolicyDeprovision, DeletionPolicyRetain, DeletionPolicyDetach}))
+	}
+	return allErrs
+}
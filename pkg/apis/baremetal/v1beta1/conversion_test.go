@@ -0,0 +1,216 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestConversionRoundTrip(t *testing.T) {
+	cases := []struct {
+		Spec BareMetalMachineProviderSpec
+		Name string
+	}{
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: &Image{
+					URL:          "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum:     "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+					ChecksumType: "md5",
+				},
+				DataSecrets: DataSecrets{
+					UserData: &corev1.SecretReference{Name: "worker-user-data", Namespace: "otherns"},
+				},
+				Selection: Selection{
+					MatchLabels: map[string]string{"role": "worker"},
+					MatchExpressions: []HostSelectorRequirement{
+						{Key: "rack", Operator: "in", Values: []string{"a", "b"}},
+					},
+					HostNamespaces: []string{"pool"},
+					ClaimMode:      ClaimModeHostClaim,
+				},
+				Lifecycle: Lifecycle{DeletionPolicy: DeletionPolicyDeprovision},
+			},
+			Name: "image and selection",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy: Deploy{CustomDeploy: &CustomDeploy{Method: "install_coreos"}},
+				Selection: Selection{
+					CELSelector: "host.status.hardware.cpu.count >= 64",
+				},
+			},
+			Name: "celSelector",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy: Deploy{
+					CustomDeploy:               &CustomDeploy{Method: "install_coreos"},
+					AdoptExternallyProvisioned: true,
+				},
+				Lifecycle: Lifecycle{DeletionPolicy: DeletionPolicyRetain},
+			},
+			Name: "customDeploy without image",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			hub := &v1alpha1.BareMetalMachineProviderSpec{}
+			if err := tc.Spec.ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo: %v", err)
+			}
+			if hub.APIVersion != v1alpha1.SchemeGroupVersion.String() {
+				t.Errorf("unexpected apiVersion %q", hub.APIVersion)
+			}
+
+			spec := &BareMetalMachineProviderSpec{}
+			if err := spec.ConvertFrom(hub); err != nil {
+				t.Fatalf("ConvertFrom: %v", err)
+			}
+			expected := tc.Spec.DeepCopy()
+			expected.APIVersion = SchemeGroupVersion.String()
+			expected.Kind = "BareMetalMachineProviderSpec"
+			if !reflect.DeepEqual(expected, spec) {
+				t.Errorf("expected %+v, got %+v", expected, spec)
+			}
+		})
+	}
+}
+
+func TestConvertFromV1alpha1(t *testing.T) {
+	hub := &v1alpha1.BareMetalMachineProviderSpec{
+		CustomDeploy: v1alpha1.CustomDeploy{Method: "install_coreos"},
+	}
+	spec := &BareMetalMachineProviderSpec{}
+	if err := spec.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom: %v", err)
+	}
+	if spec.Image != nil {
+		t.Errorf("expected no image, got %+v", spec.Image)
+	}
+	if spec.Selection.ClaimMode != "" {
+		t.Errorf("expected empty claimMode, got %q", spec.Selection.ClaimMode)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		Spec           BareMetalMachineProviderSpec
+		ExpectedErrors []string
+		Name           string
+	}{
+		{
+			Spec:           BareMetalMachineProviderSpec{},
+			ExpectedErrors: []string{"spec.image: Required value"},
+			Name:           "empty spec",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: &Image{URL: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2", ChecksumType: "crc32"},
+			},
+			ExpectedErrors: []string{
+				"spec.image.checksum: Required value",
+				`spec.image.checksumType: Unsupported value: "crc32"`,
+			},
+			Name: "incomplete image",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy: Deploy{CustomDeploy: &CustomDeploy{}},
+			},
+			ExpectedErrors: []string{"spec.deploy.customDeploy.method: Required value"},
+			Name:           "customDeploy without method",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy: Deploy{CustomDeploy: &CustomDeploy{Method: "install_coreos"}},
+				Selection: Selection{
+					MatchExpressions: []HostSelectorRequirement{
+						{Key: "rack", Operator: "contains", Values: []string{"a"}},
+					},
+					HostNamespaces: []string{"Pool"},
+				},
+			},
+			ExpectedErrors: []string{
+				`spec.selection.matchExpressions[0].operator: Unsupported value: "contains"`,
+				`spec.selection.hostNamespaces[0]: Invalid value: "Pool"`,
+			},
+			Name: "invalid selection",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy: Deploy{AdoptExternallyProvisioned: true},
+				Selection: Selection{
+					HostNamespaces: []string{"a", "b"},
+					CELSelector:    "host.spec.online",
+					ClaimMode:      ClaimModeHostClaim,
+				},
+				Lifecycle: Lifecycle{DeletionPolicy: DeletionPolicyRetain},
+			},
+			ExpectedErrors: []string{
+				"spec.selection.claimMode: Forbidden: HostClaim cannot be combined with deploy.adoptExternallyProvisioned",
+				"spec.selection.claimMode: Forbidden: HostClaim requires lifecycle.deletionPolicy Deprovision",
+				"spec.selection.hostNamespaces: Too many",
+				"spec.selection.claimMode: Forbidden: HostClaim cannot be combined with selection.celSelector",
+			},
+			Name: "invalid HostClaim",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Deploy:    Deploy{AdoptExternallyProvisioned: true},
+				Selection: Selection{ClaimMode: "Shared"},
+				Lifecycle: Lifecycle{DeletionPolicy: "Delete"},
+			},
+			ExpectedErrors: []string{
+				`spec.selection.claimMode: Unsupported value: "Shared"`,
+				`spec.lifecycle.deletionPolicy: Unsupported value: "Delete"`,
+			},
+			Name: "unsupported values",
+		},
+		{
+			Spec: BareMetalMachineProviderSpec{
+				Image: &Image{
+					URL:      "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+					Checksum: "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum",
+				},
+				Selection: Selection{ClaimMode: ClaimModeHostClaim},
+			},
+			Name: "valid",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			errs := tc.Spec.Validate(field.NewPath("spec"))
+			if len(errs) != len(tc.ExpectedErrors) {
+				t.Fatalf("expected %d errors, got %v", len(tc.ExpectedErrors), errs)
+			}
+			for i, expected := range tc.ExpectedErrors {
+				if !strings.Contains(errs[i].Error(), expected) {
+					t.Errorf("expected error %q, got %q", expected, errs[i].Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// isControlPlane returns true if machine is a control-plane Machine.
+func isControlPlane(machine *machinev1beta1.Machine) bool {
+	return machine != nil && machine.Labels[machineRoleLabel] == machineRoleMaster
+}
+
+// controlPlaneHealth returns the number of control-plane Machines, and how
+// many of them, other than machine, are healthy: their Node is Ready and they
+// are not being remediated.
+func (l *RemediationLimiter) controlPlaneHealth(ctx context.Context, machine *machinev1beta1.Machine,
+	machines []machinev1beta1.Machine, inFlight map[client.ObjectKey]*machinev1beta1.Machine) (members, healthy int, err error) {
+	remediated := map[client.ObjectKey]bool{}
+	for _, other := range inFlight {
+		remediated[client.ObjectKeyFromObject(other)] = true
+	}
+
+	for i := range machines {
+		other := &machines[i]
+		if !isControlPlane(other) {
+			continue
+		}
+		members++
+		key := client.ObjectKeyFromObject(other)
+		if key == client.ObjectKeyFromObject(machine) || remediated[key] {
+			continue
+		}
+		ready, err := l.isNodeReady(ctx, other)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ready {
+			healthy++
+		}
+	}
+	return members, healthy, nil
+}
+
+// isNodeReady returns true if the Node of machine exists and is Ready.
+func (l *RemediationLimiter) isNodeReady(ctx context.Context, machine *machinev1beta1.Machine) (bool, error) {
+	if machine.Status.NodeRef == nil {
+		return false, nil
+	}
+	node := &corev1.Node{}
+	err := l.Client.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to get node %s", machine.Status.NodeRef.Name)
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue, nil
+		}
+	}
+	return false, nil
+}
+
+// quorum returns how many members of a control plane must be healthy for it
+// to keep its quorum.
+func quorum(members int) int {
+	return members/2 + 1
+}
+
+// admitControlPlane returns true if the host of the control-plane machine may
+// be powered off: no other control-plane Machine is being remediated, and a
+// quorum of the control plane is healthy without it.
+func (l *RemediationLimiter) admitControlPlane(ctx context.Context, machine *machinev1beta1.Machine,
+	machines []machinev1beta1.Machine, inFlight map[client.ObjectKey]*machinev1beta1.Machine) (bool, string, error) {
+	for _, other := range inFlight {
+		if isControlPlane(other) && client.ObjectKeyFromObject(other) != client.ObjectKeyFromObject(machine) {
+			return false, fmt.Sprintf("control-plane Machine %s is being remediated", other.Name), nil
+		}
+	}
+
+	members, healthy, err := l.controlPlaneHealth(ctx, machine, machines, inFlight)
+	if err != nil {
+		return false, "", err
+	}
+	if healthy < quorum(members) {
+		return false, fmt.Sprintf("%d of the other %d control-plane Nodes are Ready, %d are needed for quorum",
+			healthy, members-1, quorum(members)), nil
+	}
+	return true, fmt.Sprintf("%d of the other %d control-plane Nodes are Ready, %d are needed for quorum",
+		healthy, members-1, quorum(members)), nil
+}
+
+// AdmitFencing returns true if the host of machine may be fenced, that is
+// left powered off. The host of a control-plane Machine is only fenced if more
+// than a quorum of the control plane stays healthy, so that the remaining
+// members are not the last ones keeping it. The decisions on control-plane
+// Machines always have a reason.
+func (l *RemediationLimiter) AdmitFencing(ctx context.Context, machine *machinev1beta1.Machine,
+	host *bmov1alpha1.BareMetalHost) (bool, string, error) {
+	if !isControlPlane(machine) {
+		return true, "", nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	machines, inFlight, err := l.inFlightHosts(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	delete(inFlight, client.ObjectKeyFromObject(host))
+
+	members, healthy, err := l.controlPlaneHealth(ctx, machine, machines, inFlight)
+	if err != nil {
+		return false, "", err
+	}
+	if healthy <= quorum(members) {
+		return false, fmt.Sprintf("fencing would leave %d of %d control-plane members healthy, more than the quorum of %d are needed",
+			healthy, members, quorum(members)), nil
+	}
+	return true, fmt.Sprintf("fencing leaves %d of %d control-plane members healthy, more than the quorum of %d",
+		healthy, members, quorum(members)), nil
+}
+
+// AdmitFencing returns true if the host may be fenced without leaving only
+// the last healthy members of the control plane. The decision is recorded in
+// the RemediationStatus with its reason, if it has one.
+func (r *RemediationManager) AdmitFencing(ctx context.Context) (bool, string, error) {
+	host, _, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	if host == nil {
+		return false, "", errors.New("Unable to admit fencing, Host not found")
+	}
+
+	admitted, reason, err := r.limiter().AdmitFencing(ctx, r.OCPMachine, host)
+	if err != nil {
+		return false, "", err
+	}
+	if admitted {
+		r.recordDecision(DecisionAdmitted, reason)
+	} else {
+		r.recordDecision(DecisionRefused, reason)
+	}
+	return admitted, reason, nil
+}
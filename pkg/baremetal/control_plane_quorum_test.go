@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Control plane quorum", func() {
+	type member struct {
+		host    *bmov1alpha1.BareMetalHost
+		machine *machinev1beta1.Machine
+		node    *corev1.Node
+	}
+
+	newMember := func(name string, ready bool, phase string) ([]client.Object, member) {
+		m := member{
+			host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName},
+			},
+			machine: &machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   namespaceName,
+					Labels:      map[string]string{machineRoleLabel: machineRoleMaster},
+					Annotations: map[string]string{HostAnnotation: namespaceName + "/" + name},
+				},
+				Status: machinev1beta1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Kind: "Node", Name: name},
+				},
+			},
+			node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}},
+		}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		m.node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}
+		objects := []client.Object{m.host, m.machine, m.node}
+		if phase != "" {
+			objects = append(objects, &infrav1.Metal3Remediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespaceName,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: machinev1beta1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       name,
+					}},
+				},
+				Status: infrav1.Metal3RemediationStatus{Phase: phase},
+			})
+		}
+		return objects, m
+	}
+
+	// other describes a member of the control plane by the readiness of its
+	// Node and the phase of its remediation.
+	type other struct {
+		ready bool
+		phase string
+	}
+	ready, notReady := other{ready: true}, other{}
+
+	// newControlPlane returns the limiter of a control plane with the
+	// unhealthy master-0 and the others.
+	newControlPlane := func(others ...other) (*RemediationLimiter, member) {
+		objects, unhealthy := newMember("master-0", false, "")
+		for i, o := range others {
+			memberObjects, _ := newMember(fmt.Sprintf("master-%d", i+1), o.ready, o.phase)
+			objects = append(objects, memberObjects...)
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+		return &RemediationLimiter{Client: fakeClient}, unhealthy
+	}
+
+	It("should power off a member when the others keep the quorum", func() {
+		limiter, unhealthy := newControlPlane(ready, ready)
+		admitted, reason, err := limiter.AdmitRemediation(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeTrue())
+		Expect(reason).To(Equal("2 of the other 2 control-plane Nodes are Ready, 2 are needed for quorum"))
+	})
+
+	It("should not power off a member without a quorum of Ready others", func() {
+		limiter, unhealthy := newControlPlane(ready, notReady)
+		admitted, reason, err := limiter.AdmitRemediation(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).To(Equal("1 of the other 2 control-plane Nodes are Ready, 2 are needed for quorum"))
+	})
+
+	It("should serialize control-plane remediations", func() {
+		limiter, unhealthy := newControlPlane(ready, ready, ready, other{ready: true, phase: infrav1.PhaseWaiting})
+		admitted, reason, err := limiter.AdmitRemediation(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).To(Equal("control-plane Machine master-4 is being remediated"))
+	})
+
+	It("should serialize control-plane remediations admitted before they start", func() {
+		limiter, unhealthy := newControlPlane(ready, ready, ready, ready)
+		admitted, _, err := limiter.AdmitRemediation(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeTrue())
+
+		_, next := newMember("master-1", false, "")
+		var reason string
+		admitted, reason, err = limiter.AdmitRemediation(context.TODO(), next.machine, next.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).To(Equal("control-plane Machine master-0 is being remediated"))
+	})
+
+	It("should refuse to fence the last healthy members", func() {
+		limiter, unhealthy := newControlPlane(ready, ready)
+		admitted, reason, err := limiter.AdmitFencing(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).To(Equal("fencing would leave 2 of 3 control-plane members healthy, more than the quorum of 2 are needed"))
+
+		limiter, unhealthy = newControlPlane(ready, ready, ready, ready)
+		admitted, _, err = limiter.AdmitFencing(context.TODO(), unhealthy.machine, unhealthy.host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeTrue(), "4 healthy members of 5 are more than the quorum of 3")
+	})
+
+	It("should not guard workers", func() {
+		limiter, unhealthy := newControlPlane(notReady, notReady)
+		delete(unhealthy.machine.Labels, machineRoleLabel)
+		Expect(limiter.AdmitRemediation(context.TODO(), unhealthy.machine, unhealthy.host)).To(BeTrue())
+		Expect(limiter.AdmitFencing(context.TODO(), unhealthy.machine, unhealthy.host)).To(BeTrue())
+	})
+
+	It("should record the decisions in the remediation status", func() {
+		limiter, unhealthy := newControlPlane(ready, ready)
+		remediationMgr, err := NewRemediationManager(limiter.Client.(client.Client), &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{Name: "master-0", Namespace: namespaceName},
+		}, unhealthy.machine, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		remediationMgr.Limiter = limiter
+
+		admitted, _, err := remediationMgr.AdmitFencing(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(remediationMgr.remediationStatus().Decision).To(Equal(DecisionRefused))
+
+		admitted, reason, err := remediationMgr.AdmitRemediation(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeTrue())
+		Expect(remediationMgr.remediationStatus().Decision).To(Equal(DecisionAdmitted))
+		Expect(remediationMgr.remediationStatus().Reason).To(Equal(reason))
+	})
+})
@@ -31,19 +31,29 @@ type ManagerFactoryInterface interface {
 	)
 }
 
-// ManagerFactory only contains a client.
+// ManagerFactory contains a client and the limiter of remediations.
 type ManagerFactory struct {
-	client client.Client
+	client  client.Client
+	limiter *RemediationLimiter
 }
 
-// NewManagerFactory returns a new factory.
-func NewManagerFactory(client client.Client) ManagerFactory {
-	return ManagerFactory{client: client}
+// NewManagerFactory returns a new factory. Without a limiter, the managers
+// share one without limits, which still protects the control plane.
+func NewManagerFactory(client client.Client, limiter *RemediationLimiter) ManagerFactory {
+	if limiter == nil {
+		limiter = &RemediationLimiter{Client: client}
+	}
+	return ManagerFactory{client: client, limiter: limiter}
 }
 
 // NewRemediationManager creates a new RemediationManager.
 func (f ManagerFactory) NewRemediationManager(remediation *infrav1.Metal3Remediation,
 	ocpMachine *machinev1beta1.Machine,
 	remediationLog logr.Logger) (RemediationManagerInterface, error) {
-	return NewRemediationManager(f.client, remediation, ocpMachine, remediationLog)
+	remediationMgr, err := NewRemediationManager(f.client, remediation, ocpMachine, remediationLog)
+	if err != nil {
+		return nil, err
+	}
+	remediationMgr.Limiter = f.limiter
+	return remediationMgr, nil
 }
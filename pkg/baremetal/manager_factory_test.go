@@ -34,7 +34,7 @@ var _ = Describe("Manager factory testing", func() {
 
 	BeforeEach(func() {
 		fakeClient = fake.NewClientBuilder().WithScheme(setupScheme()).Build()
-		managerFactory = NewManagerFactory(fakeClient)
+		managerFactory = NewManagerFactory(fakeClient, nil)
 	})
 
 	It("returns a manager factory", func() {
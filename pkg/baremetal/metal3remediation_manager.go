@@ -18,7 +18,6 @@ package baremetal
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -36,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
 )
 
 const (
@@ -49,13 +49,39 @@ const (
 	machineRoleMaster = "master"
 )
 
+// Remediation strategies and phases of this provider, in addition to those of
+// the Metal3Remediation API.
+const (
+	// PowerOffRemediationStrategy fences the host of an unhealthy Machine: it
+	// is powered off and its Node is deleted so that the workload fails over,
+	// and it is left powered off for a human to investigate.
+	PowerOffRemediationStrategy infrav1.RemediationType = "PowerOff"
+
+	// PhaseFenced is the final phase of the PowerOff strategy. The host stays
+	// powered off and claimed by its Machine.
+	PhaseFenced = "Fenced"
+
+	// ReprovisionRemediationStrategy deprovisions the host of an unhealthy
+	// Machine and provisions it again with the image and userData of the
+	// Machine, which keeps its name and its host.
+	ReprovisionRemediationStrategy infrav1.RemediationType = "Reprovision"
+
+	// PhaseDeprovisioning is the phase of the Reprovision strategy in which
+	// the host is being deprovisioned.
+	PhaseDeprovisioning = "Deprovisioning"
+
+	// PhaseReprovisioning is the phase of the Reprovision strategy in which
+	// the host is being provisioned again, before waiting for its Node.
+	PhaseReprovisioning = "Reprovisioning"
+)
+
 // RemediationManagerInterface is an interface for a RemediationManager.
 type RemediationManagerInterface interface {
 	SetFinalizer()
 	UnsetFinalizer()
 	HasFinalizer() bool
 	TimeToRemediate(timeout time.Duration) (bool, time.Duration)
-	SetPowerOffAnnotation(ctx context.Context) error
+	SetPowerOffAnnotation(ctx context.Context, mode bmov1alpha1.RebootMode) error
 	RemovePowerOffAnnotation(ctx context.Context) error
 	IsPowerOffRequested(ctx context.Context) (bool, error)
 	IsPoweredOn(ctx context.Context) (bool, error)
@@ -82,6 +108,18 @@ type RemediationManagerInterface interface {
 
 	CanReprovision(context.Context) (bool, error)
 	DeleteMachine(ctx context.Context) error
+	DeprovisionHost(ctx context.Context) error
+	ReprovisionHost(ctx context.Context) error
+	GetProvisioningState(ctx context.Context) (bmov1alpha1.ProvisioningState, error)
+	RemoveReprovisioningAnnotation(ctx context.Context) error
+	EndReprovisioning(ctx context.Context) error
+	ResolveRemediationPolicy(ctx context.Context) error
+	AdvanceRemediationStep(result string) bool
+	SetQuarantineAnnotation(ctx context.Context) error
+	GetSoftPowerOffTimeout() *metav1.Duration
+	GetPowerOffMode() (bmov1alpha1.RebootMode, *metav1.Time)
+	AdmitRemediation(ctx context.Context) (bool, string, error)
+	AdmitFencing(ctx context.Context) (bool, string, error)
 }
 
 // RemediationManager is responsible for performing remediation reconciliation.
@@ -90,6 +128,9 @@ type RemediationManager struct {
 	Metal3Remediation *infrav1.Metal3Remediation
 	OCPMachine        *machinev1beta1.Machine
 	Log               logr.Logger
+	// Limiter admits the remediation within the cluster-wide limits. If it
+	// is nil, there is no limit.
+	Limiter *RemediationLimiter
 }
 
 // enforce implementation of interface.
@@ -141,8 +182,10 @@ func (r *RemediationManager) TimeToRemediate(timeout time.Duration) (bool, time.
 	return false, nextRemediation
 }
 
-// SetPowerOffAnnotation sets poweroff annotation on unhealthy host.
-func (r *RemediationManager) SetPowerOffAnnotation(ctx context.Context) error {
+// SetPowerOffAnnotation sets poweroff annotation on unhealthy host, requesting
+// a power off in the given mode, and records the mode in the remediation
+// status.
+func (r *RemediationManager) SetPowerOffAnnotation(ctx context.Context, mode bmov1alpha1.RebootMode) error {
 	host, helper, err := r.GetUnhealthyHost(ctx)
 	if err != nil {
 		return err
@@ -151,20 +194,21 @@ func (r *RemediationManager) SetPowerOffAnnotation(ctx context.Context) error {
 		return errors.New("Unable to set a PowerOff Annotation, Host not found")
 	}
 
-	r.Log.Info("Adding PowerOff annotation to host", "host", host.Name)
-	rebootMode := bmov1alpha1.RebootAnnotationArguments{}
-	rebootMode.Mode = bmov1alpha1.RebootModeHard
-	marshalledMode, err := json.Marshal(rebootMode)
-
-	if err != nil {
-		return err
-	}
-
+	r.Log.Info("Adding PowerOff annotation to host", "host", host.Name, "mode", mode)
 	if host.Annotations == nil {
 		host.Annotations = make(map[string]string)
 	}
-	host.Annotations[r.getPowerOffAnnotationKey()] = string(marshalledMode)
-	return helper.Patch(ctx, host)
+	host.Annotations[r.getPowerOffAnnotationKey()] = actuator.PowerOffRequest(mode)
+	if err := helper.Patch(ctx, host); err != nil {
+		return err
+	}
+
+	status := r.remediationStatus()
+	status.PowerOffMode = mode
+	now := metav1.Now()
+	status.PowerOffRequested = &now
+	r.setRemediationStatus(status)
+	return nil
 }
 
 // RemovePowerOffAnnotation removes poweroff annotation from unhealthy host.
@@ -279,8 +323,12 @@ func (r *RemediationManager) OnlineStatus(host *bmov1alpha1.BareMetalHost) bool
 	return host.Spec.Online
 }
 
-// GetRemediationType return type of remediation strategy.
+// GetRemediationType return type of remediation strategy, or of the current
+// step if the remediation has steps.
 func (r *RemediationManager) GetRemediationType() infrav1.RemediationType {
+	if step := r.currentStep(); step != nil {
+		return step.Type
+	}
 	if r.Metal3Remediation.Spec.Strategy == nil {
 		return ""
 	}
@@ -289,6 +337,9 @@ func (r *RemediationManager) GetRemediationType() infrav1.RemediationType {
 
 // RetryLimitIsSet returns true if retryLimit is set, false if not.
 func (r *RemediationManager) RetryLimitIsSet() bool {
+	if step := r.currentStep(); step != nil {
+		return step.RetryLimit > 0
+	}
 	if r.Metal3Remediation.Spec.Strategy == nil {
 		return false
 	}
@@ -297,6 +348,9 @@ func (r *RemediationManager) RetryLimitIsSet() bool {
 
 // HasReachRetryLimit returns true if retryLimit is reached.
 func (r *RemediationManager) HasReachRetryLimit() bool {
+	if step := r.currentStep(); step != nil {
+		return r.remediationStatus().Retries >= step.RetryLimit
+	}
 	if r.Metal3Remediation.Spec.Strategy == nil {
 		return false
 	}
@@ -325,14 +379,24 @@ func (r *RemediationManager) SetLastRemediationTime(remediationTime *metav1.Time
 	r.Metal3Remediation.Status.LastRemediated = remediationTime
 }
 
-// GetTimeout returns timeout duration from remediation request Spec.
+// GetTimeout returns timeout duration from remediation request Spec, or of
+// the current step if it sets one.
 func (r *RemediationManager) GetTimeout() *metav1.Duration {
+	if step := r.currentStep(); step != nil && step.Timeout != nil {
+		return step.Timeout
+	}
 	return r.Metal3Remediation.Spec.Strategy.Timeout
 }
 
-// IncreaseRetryCount increases the retry count on Status.
+// IncreaseRetryCount increases the retry count on Status, and of the current
+// step if the remediation has steps.
 func (r *RemediationManager) IncreaseRetryCount() {
 	r.Metal3Remediation.Status.RetryCount++
+	if r.currentStep() != nil {
+		status := r.remediationStatus()
+		status.Retries++
+		r.setRemediationStatus(status)
+	}
 }
 
 // GetNode returns the Node associated with the machine in the current context.
@@ -449,3 +513,106 @@ func (r *RemediationManager) DeleteMachine(ctx context.Context) error {
 	}
 	return err
 }
+
+// DeprovisionHost removes the image and custom deploy method from the host of
+// the unhealthy Machine, so that it is deprovisioned. The host stays claimed
+// by the Machine, and is annotated so that the Machine keeps existing until
+// the host is provisioned again.
+func (r *RemediationManager) DeprovisionHost(ctx context.Context) error {
+	host, helper, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return errors.New("Unable to deprovision, Host not found")
+	}
+
+	r.Log.Info("Deprovisioning host", "host", host.Name)
+	if host.Annotations == nil {
+		host.Annotations = make(map[string]string, 1)
+	}
+	host.Annotations[actuator.ReprovisioningAnnotation] = r.OCPMachine.Name
+	host.Spec.Image = nil
+	host.Spec.CustomDeploy = nil
+	return helper.Patch(ctx, host)
+}
+
+// ReprovisionHost sets the image, custom deploy method and userData from the
+// ProviderSpec of the unhealthy Machine on its host again, so that it is
+// provisioned.
+func (r *RemediationManager) ReprovisionHost(ctx context.Context) error {
+	host, helper, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return errors.New("Unable to reprovision, Host not found")
+	}
+
+	if err := actuator.SetProvisioningSpec(host, r.OCPMachine); err != nil {
+		return errors.Wrap(err, "Unable to reprovision, invalid ProviderSpec")
+	}
+	if host.Spec.Image == nil && host.Spec.CustomDeploy == nil {
+		return errors.New("Unable to reprovision, Machine has no image or custom deploy method")
+	}
+	r.Log.Info("Reprovisioning host", "host", host.Name)
+	return helper.Patch(ctx, host)
+}
+
+// GetProvisioningState returns the provisioning state of the host of the
+// unhealthy Machine.
+func (r *RemediationManager) GetProvisioningState(ctx context.Context) (bmov1alpha1.ProvisioningState, error) {
+	host, _, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return "", err
+	}
+	if host == nil {
+		return "", errors.New("Unable to check provisioning state, Host not found")
+	}
+	return host.Status.Provisioning.State, nil
+}
+
+// RemoveReprovisioningAnnotation removes the reprovisioning annotation from
+// the host of the unhealthy Machine once it is provisioned again.
+func (r *RemediationManager) RemoveReprovisioningAnnotation(ctx context.Context) error {
+	host, helper, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return errors.New("Unable to remove Reprovisioning Annotation, Host not found")
+	}
+	if _, ok := host.Annotations[actuator.ReprovisioningAnnotation]; !ok {
+		return nil
+	}
+
+	r.Log.Info("Removing Reprovisioning annotation from host", "host", host.Name)
+	delete(host.Annotations, actuator.ReprovisioningAnnotation)
+	return helper.Patch(ctx, host)
+}
+
+// EndReprovisioning is called when the remediation leaves the Reprovision
+// strategy before the host is provisioned again. The image, custom deploy
+// method and userData of the Machine are set on the host again, and the
+// reprovisioning annotation is removed, so that the host is not taken for
+// provisioned while it is blank. If the ProviderSpec is invalid, the host
+// stays blank and its Machine fails.
+func (r *RemediationManager) EndReprovisioning(ctx context.Context) error {
+	host, helper, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return errors.New("Unable to end reprovisioning, Host not found")
+	}
+	if _, ok := host.Annotations[actuator.ReprovisioningAnnotation]; !ok {
+		return nil
+	}
+
+	r.Log.Info("Restoring the provisioning spec of host", "host", host.Name)
+	if err := actuator.SetProvisioningSpec(host, r.OCPMachine); err != nil {
+		r.Log.Error(err, "Unable to restore the provisioning spec of host, invalid ProviderSpec", "host", host.Name)
+	}
+	delete(host.Annotations, actuator.ReprovisioningAnnotation)
+	return helper.Patch(ctx, host)
+}
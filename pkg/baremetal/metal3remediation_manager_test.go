@@ -29,11 +29,13 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
 )
 
 type testCaseRemediationManager struct {
@@ -841,7 +843,7 @@ var _ = Describe("Metal3Remediation manager", func() {
 				Expect(bmhost.ObjectMeta.Annotations).ToNot(HaveKey(remediationMgr.getPowerOffAnnotationKey()), "bmh should not have power off annotation")
 			}
 
-			ensureExists := func() {
+			ensureExists := func(mode bmov1alpha1.RebootMode) {
 				Expect(remediationMgr.IsPowerOffRequested(context.TODO())).To(BeTrue(), "IsPowerOffrequested should return true")
 				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), bmhost)).To(Succeed())
 				Expect(bmhost.Annotations).To(HaveKeyWithValue(
@@ -849,15 +851,22 @@ var _ = Describe("Metal3Remediation manager", func() {
 						Equal(remediationMgr.getPowerOffAnnotationKey()),
 						HaveSuffix(string(remediation.UID)), // to ensure that powerOffAnnotation can be formatted with the UID
 					),
-					ContainSubstring(string(bmov1alpha1.RebootModeHard)),
+					ContainSubstring(string(mode)),
 				), "bmh should have power off annotation")
+				recordedMode, requested := remediationMgr.GetPowerOffMode()
+				Expect(recordedMode).To(Equal(mode), "power off mode should be recorded")
+				Expect(requested).NotTo(BeNil(), "power off time should be recorded")
 			}
 
 			ensureNotExists()
 
 			By("Setting annotation")
-			Expect(remediationMgr.SetPowerOffAnnotation(context.TODO())).To(Succeed(), "SetPowerOffAnnotation should succeed")
-			ensureExists()
+			Expect(remediationMgr.SetPowerOffAnnotation(context.TODO(), bmov1alpha1.RebootModeSoft)).To(Succeed(), "SetPowerOffAnnotation should succeed")
+			ensureExists(bmov1alpha1.RebootModeSoft)
+
+			By("Escalating to a hard power off")
+			Expect(remediationMgr.SetPowerOffAnnotation(context.TODO(), bmov1alpha1.RebootModeHard)).To(Succeed(), "SetPowerOffAnnotation should succeed")
+			ensureExists(bmov1alpha1.RebootModeHard)
 
 			By("Removing annotation")
 			Expect(remediationMgr.RemovePowerOffAnnotation(context.TODO())).To(Succeed(), "RemovePowerOffAnnotation should succeed")
@@ -977,4 +986,127 @@ var _ = Describe("Metal3Remediation manager", func() {
 		})
 
 	})
+
+	Describe("Test Reprovisioning", func() {
+		bmhost := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myhost",
+				Namespace: namespaceName,
+			},
+			Spec: bmov1alpha1.BareMetalHostSpec{
+				Image: &bmov1alpha1.Image{
+					URL:      "http://example.com/old.qcow2",
+					Checksum: "http://example.com/old.qcow2.md5sum",
+				},
+				UserData: &corev1.SecretReference{
+					Name:      "worker-user-data",
+					Namespace: namespaceName,
+				},
+				ConsumerRef: &corev1.ObjectReference{
+					Kind:      "Machine",
+					Name:      "mym3machine",
+					Namespace: namespaceName,
+				},
+			},
+			Status: bmov1alpha1.BareMetalHostStatus{
+				Provisioning: bmov1alpha1.ProvisionStatus{
+					State: bmov1alpha1.StateProvisioned,
+				},
+			},
+		}
+
+		m3machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mym3machine",
+				Namespace: namespaceName,
+				Annotations: map[string]string{
+					HostAnnotation: namespaceName + "/myhost",
+				},
+			},
+			Spec: machinev1beta1.MachineSpec{
+				ProviderSpec: machinev1beta1.ProviderSpec{
+					Value: &runtime.RawExtension{
+						Raw: []byte(`{"image":{"url":"http://example.com/new.qcow2",` +
+							`"checksum":"http://example.com/new.qcow2.md5sum"},` +
+							`"userData":{"name":"worker-user-data"}}`),
+					},
+				},
+			},
+		}
+
+		remediation := &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myremediation",
+				Namespace: namespaceName,
+			},
+		}
+
+		It("should deprovision and reprovision the host of the machine", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(bmhost.DeepCopy(), m3machine, remediation).Build()
+
+			remediationMgr, err := NewRemediationManager(fakeClient, remediation, m3machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Getting the provisioning state")
+			Expect(remediationMgr.GetProvisioningState(context.TODO())).To(Equal(bmov1alpha1.StateProvisioned))
+
+			By("Deprovisioning the host")
+			Expect(remediationMgr.DeprovisionHost(context.TODO())).To(Succeed(), "DeprovisionHost should succeed")
+			host := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), host)).To(Succeed())
+			Expect(host.Spec.Image).To(BeNil(), "image should be removed")
+			Expect(host.Spec.ConsumerRef).To(Equal(bmhost.Spec.ConsumerRef), "host should stay claimed")
+			Expect(host.Spec.UserData).To(Equal(bmhost.Spec.UserData), "userData should be kept")
+			Expect(host.Annotations).To(HaveKeyWithValue(actuator.ReprovisioningAnnotation, m3machine.Name),
+				"host should be marked as reprovisioning for the machine")
+
+			By("Reprovisioning the host")
+			Expect(remediationMgr.ReprovisionHost(context.TODO())).To(Succeed(), "ReprovisionHost should succeed")
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), host)).To(Succeed())
+			Expect(host.Spec.Image).ToNot(BeNil(), "image should be set")
+			Expect(host.Spec.Image.URL).To(Equal("http://example.com/new.qcow2"), "image should come from the machine")
+			Expect(host.Spec.UserData).To(Equal(bmhost.Spec.UserData), "userData should come from the machine")
+			Expect(host.Spec.ConsumerRef).To(Equal(bmhost.Spec.ConsumerRef), "host should stay claimed")
+
+			By("Removing the reprovisioning annotation")
+			Expect(remediationMgr.RemoveReprovisioningAnnotation(context.TODO())).To(Succeed())
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), host)).To(Succeed())
+			Expect(host.Annotations).ToNot(HaveKey(actuator.ReprovisioningAnnotation))
+		})
+
+		It("should restore the host when the remediation ends before it is reprovisioned", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(bmhost.DeepCopy(), m3machine, remediation).Build()
+
+			remediationMgr, err := NewRemediationManager(fakeClient, remediation, m3machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.DeprovisionHost(context.TODO())).To(Succeed(), "DeprovisionHost should succeed")
+			Expect(remediationMgr.EndReprovisioning(context.TODO())).To(Succeed(), "EndReprovisioning should succeed")
+			host := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), host)).To(Succeed())
+			Expect(host.Spec.Image).ToNot(BeNil(), "image should be restored")
+			Expect(host.Spec.Image.URL).To(Equal("http://example.com/new.qcow2"), "image should come from the machine")
+			Expect(host.Spec.ConsumerRef).To(Equal(bmhost.Spec.ConsumerRef), "host should stay claimed")
+			Expect(host.Annotations).ToNot(HaveKey(actuator.ReprovisioningAnnotation),
+				"host should not be taken for provisioned any more")
+		})
+
+		It("should not reprovision the host without an image", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(bmhost.DeepCopy(), remediation).Build()
+			machine := m3machine.DeepCopy()
+			machine.Spec.ProviderSpec.Value.Raw = []byte(`{}`)
+
+			remediationMgr, err := NewRemediationManager(fakeClient, remediation, machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.DeprovisionHost(context.TODO())).To(Succeed(), "DeprovisionHost should succeed")
+			Expect(remediationMgr.ReprovisionHost(context.TODO())).ToNot(Succeed(), "ReprovisionHost should fail")
+		})
+	})
 })
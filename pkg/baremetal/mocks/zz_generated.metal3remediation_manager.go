@@ -56,6 +56,52 @@ func (m *MockRemediationManagerInterface) EXPECT() *MockRemediationManagerInterf
 	return m.recorder
 }
 
+// AdmitFencing mocks base method.
+func (m *MockRemediationManagerInterface) AdmitFencing(ctx context.Context) (bool, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdmitFencing", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AdmitFencing indicates an expected call of AdmitFencing.
+func (mr *MockRemediationManagerInterfaceMockRecorder) AdmitFencing(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdmitFencing", reflect.TypeOf((*MockRemediationManagerInterface)(nil).AdmitFencing), ctx)
+}
+
+// AdmitRemediation mocks base method.
+func (m *MockRemediationManagerInterface) AdmitRemediation(ctx context.Context) (bool, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdmitRemediation", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AdmitRemediation indicates an expected call of AdmitRemediation.
+func (mr *MockRemediationManagerInterfaceMockRecorder) AdmitRemediation(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdmitRemediation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).AdmitRemediation), ctx)
+}
+
+// AdvanceRemediationStep mocks base method.
+func (m *MockRemediationManagerInterface) AdvanceRemediationStep(result string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdvanceRemediationStep", result)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AdvanceRemediationStep indicates an expected call of AdvanceRemediationStep.
+func (mr *MockRemediationManagerInterfaceMockRecorder) AdvanceRemediationStep(result interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdvanceRemediationStep", reflect.TypeOf((*MockRemediationManagerInterface)(nil).AdvanceRemediationStep), result)
+}
+
 // CanReprovision mocks base method.
 func (m *MockRemediationManagerInterface) CanReprovision(arg0 context.Context) (bool, error) {
 	m.ctrl.T.Helper()
@@ -99,6 +145,34 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) DeleteNode(ctx, node inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNode", reflect.TypeOf((*MockRemediationManagerInterface)(nil).DeleteNode), ctx, node)
 }
 
+// DeprovisionHost mocks base method.
+func (m *MockRemediationManagerInterface) DeprovisionHost(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeprovisionHost", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeprovisionHost indicates an expected call of DeprovisionHost.
+func (mr *MockRemediationManagerInterfaceMockRecorder) DeprovisionHost(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeprovisionHost", reflect.TypeOf((*MockRemediationManagerInterface)(nil).DeprovisionHost), ctx)
+}
+
+// EndReprovisioning mocks base method.
+func (m *MockRemediationManagerInterface) EndReprovisioning(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EndReprovisioning", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EndReprovisioning indicates an expected call of EndReprovisioning.
+func (mr *MockRemediationManagerInterfaceMockRecorder) EndReprovisioning(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndReprovisioning", reflect.TypeOf((*MockRemediationManagerInterface)(nil).EndReprovisioning), ctx)
+}
+
 // GetLastRemediatedTime mocks base method.
 func (m *MockRemediationManagerInterface) GetLastRemediatedTime() *v10.Time {
 	m.ctrl.T.Helper()
@@ -143,6 +217,36 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) GetNodeBackupAnnotations(
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeBackupAnnotations", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetNodeBackupAnnotations))
 }
 
+// GetPowerOffMode mocks base method.
+func (m *MockRemediationManagerInterface) GetPowerOffMode() (v1alpha1.RebootMode, *v10.Time) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPowerOffMode")
+	ret0, _ := ret[0].(v1alpha1.RebootMode)
+	ret1, _ := ret[1].(*v10.Time)
+	return ret0, ret1
+}
+
+// GetPowerOffMode indicates an expected call of GetPowerOffMode.
+func (mr *MockRemediationManagerInterfaceMockRecorder) GetPowerOffMode() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPowerOffMode", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetPowerOffMode))
+}
+
+// GetProvisioningState mocks base method.
+func (m *MockRemediationManagerInterface) GetProvisioningState(ctx context.Context) (v1alpha1.ProvisioningState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProvisioningState", ctx)
+	ret0, _ := ret[0].(v1alpha1.ProvisioningState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProvisioningState indicates an expected call of GetProvisioningState.
+func (mr *MockRemediationManagerInterfaceMockRecorder) GetProvisioningState(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProvisioningState", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetProvisioningState), ctx)
+}
+
 // GetRemediationPhase mocks base method.
 func (m *MockRemediationManagerInterface) GetRemediationPhase() string {
 	m.ctrl.T.Helper()
@@ -171,6 +275,20 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) GetRemediationType() *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRemediationType", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetRemediationType))
 }
 
+// GetSoftPowerOffTimeout mocks base method.
+func (m *MockRemediationManagerInterface) GetSoftPowerOffTimeout() *v10.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSoftPowerOffTimeout")
+	ret0, _ := ret[0].(*v10.Duration)
+	return ret0
+}
+
+// GetSoftPowerOffTimeout indicates an expected call of GetSoftPowerOffTimeout.
+func (mr *MockRemediationManagerInterfaceMockRecorder) GetSoftPowerOffTimeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSoftPowerOffTimeout", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetSoftPowerOffTimeout))
+}
+
 // GetTimeout mocks base method.
 func (m *MockRemediationManagerInterface) GetTimeout() *v10.Duration {
 	m.ctrl.T.Helper()
@@ -311,6 +429,48 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) RemovePowerOffAnnotation(
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePowerOffAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RemovePowerOffAnnotation), ctx)
 }
 
+// RemoveReprovisioningAnnotation mocks base method.
+func (m *MockRemediationManagerInterface) RemoveReprovisioningAnnotation(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveReprovisioningAnnotation", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveReprovisioningAnnotation indicates an expected call of RemoveReprovisioningAnnotation.
+func (mr *MockRemediationManagerInterfaceMockRecorder) RemoveReprovisioningAnnotation(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReprovisioningAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RemoveReprovisioningAnnotation), ctx)
+}
+
+// ReprovisionHost mocks base method.
+func (m *MockRemediationManagerInterface) ReprovisionHost(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReprovisionHost", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReprovisionHost indicates an expected call of ReprovisionHost.
+func (mr *MockRemediationManagerInterfaceMockRecorder) ReprovisionHost(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReprovisionHost", reflect.TypeOf((*MockRemediationManagerInterface)(nil).ReprovisionHost), ctx)
+}
+
+// ResolveRemediationPolicy mocks base method.
+func (m *MockRemediationManagerInterface) ResolveRemediationPolicy(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveRemediationPolicy", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResolveRemediationPolicy indicates an expected call of ResolveRemediationPolicy.
+func (mr *MockRemediationManagerInterfaceMockRecorder) ResolveRemediationPolicy(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveRemediationPolicy", reflect.TypeOf((*MockRemediationManagerInterface)(nil).ResolveRemediationPolicy), ctx)
+}
+
 // RetryLimitIsSet mocks base method.
 func (m *MockRemediationManagerInterface) RetryLimitIsSet() bool {
 	m.ctrl.T.Helper()
@@ -364,17 +524,31 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) SetNodeBackupAnnotations(
 }
 
 // SetPowerOffAnnotation mocks base method.
-func (m *MockRemediationManagerInterface) SetPowerOffAnnotation(ctx context.Context) error {
+func (m *MockRemediationManagerInterface) SetPowerOffAnnotation(ctx context.Context, mode v1alpha1.RebootMode) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetPowerOffAnnotation", ctx)
+	ret := m.ctrl.Call(m, "SetPowerOffAnnotation", ctx, mode)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetPowerOffAnnotation indicates an expected call of SetPowerOffAnnotation.
-func (mr *MockRemediationManagerInterfaceMockRecorder) SetPowerOffAnnotation(ctx interface{}) *gomock.Call {
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetPowerOffAnnotation(ctx, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPowerOffAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetPowerOffAnnotation), ctx, mode)
+}
+
+// SetQuarantineAnnotation mocks base method.
+func (m *MockRemediationManagerInterface) SetQuarantineAnnotation(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetQuarantineAnnotation", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetQuarantineAnnotation indicates an expected call of SetQuarantineAnnotation.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetQuarantineAnnotation(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPowerOffAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetPowerOffAnnotation), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetQuarantineAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetQuarantineAnnotation), ctx)
 }
 
 // SetRemediationPhase mocks base method.
@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+)
+
+const (
+	// RemediationLimitsKey is the key of the ConfigMap data that holds the
+	// RemediationLimits, as YAML.
+	RemediationLimitsKey = "limits.yaml"
+
+	// DefaultFailureDomainLabel is the label of the BareMetalHosts that
+	// names their failure domain, if the RemediationLimits do not set one.
+	DefaultFailureDomainLabel = corev1.LabelTopologyZone
+
+	// reservationTimeout is how long an admitted host counts as remediated
+	// while the cache does not show that its remediation started yet.
+	reservationTimeout = 2 * time.Minute
+
+	// PhasePending is the phase of a Metal3Remediation that waits for other
+	// remediations to end before it powers off its host. The reason is
+	// recorded in its RemediationStatus.
+	PhasePending = "Pending"
+)
+
+// Decisions on whether a remediation may power off or fence its host,
+// recorded in its RemediationStatus.
+const (
+	// DecisionAdmitted means that the host may be powered off or fenced.
+	DecisionAdmitted = "Admitted"
+	// DecisionPending means that the remediation waits in PhasePending.
+	DecisionPending = "Pending"
+	// DecisionRefused means that the host is not fenced, and the
+	// remediation failed.
+	DecisionRefused = "Refused"
+)
+
+// inFlightRemediationPhases are the phases of a Metal3Remediation between
+// its start and its end.
+var inFlightRemediationPhases = []string{
+	infrav1.PhaseRunning,
+	infrav1.PhaseWaiting,
+	PhaseDeprovisioning,
+	PhaseReprovisioning,
+}
+
+// RemediationLimits are the cluster-wide limits on the number of hosts
+// remediated at once, by Metal3Remediations and by the MachineHealthCheck
+// annotation together. A limit of 0 means no limit.
+type RemediationLimits struct {
+	// MaxInFlight is the maximum number of hosts remediated at once.
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+
+	// MaxInFlightPerFailureDomain is the maximum number of hosts of a
+	// failure domain remediated at once. Hosts without a failure domain
+	// are only subject to MaxInFlight.
+	MaxInFlightPerFailureDomain int `json:"maxInFlightPerFailureDomain,omitempty"`
+
+	// FailureDomainLabel is the label of the BareMetalHosts that names
+	// their failure domain. It defaults to DefaultFailureDomainLabel.
+	FailureDomainLabel string `json:"failureDomainLabel,omitempty"`
+}
+
+// RemediationLimiter admits remediations within the RemediationLimits stored
+// in a ConfigMap.
+type RemediationLimiter struct {
+	// Client lists the Machines, Metal3Remediations and BareMetalHosts.
+	Client client.Reader
+	// APIReader reads the ConfigMap.
+	APIReader client.Reader
+	// ConfigMap holding the RemediationLimits. If it does not exist, there
+	// are no limits.
+	ConfigMap types.NamespacedName
+
+	// mu serializes the admissions, so that each one counts the hosts
+	// admitted before it.
+	mu sync.Mutex
+	// reserved are the Machines whose remediation was admitted, by the key
+	// of their host, until the cache shows that it started.
+	reserved map[client.ObjectKey]reservation
+}
+
+// reservation is a host admitted for the remediation of machine.
+type reservation struct {
+	machine *machinev1beta1.Machine
+	expires time.Time
+}
+
+// enforce implementation of interface.
+var _ actuator.RemediationAdmitter = &RemediationLimiter{}
+
+// limits returns the RemediationLimits, or nil if there are none.
+func (l *RemediationLimiter) limits(ctx context.Context) (*RemediationLimits, error) {
+	if l == nil || l.ConfigMap.Name == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	err := l.APIReader.Get(ctx, l.ConfigMap, cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ConfigMap %s", l.ConfigMap)
+	}
+	data, ok := cm.Data[RemediationLimitsKey]
+	if !ok {
+		return nil, nil
+	}
+	limits := &RemediationLimits{}
+	if err := yaml.UnmarshalStrict([]byte(data), limits); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s in ConfigMap %s", RemediationLimitsKey, l.ConfigMap)
+	}
+	if limits.MaxInFlight < 0 || limits.MaxInFlightPerFailureDomain < 0 {
+		return nil, errors.Errorf("invalid %s in ConfigMap %s: limits must not be negative", RemediationLimitsKey, l.ConfigMap)
+	}
+	if limits.FailureDomainLabel == "" {
+		limits.FailureDomainLabel = DefaultFailureDomainLabel
+	}
+	return limits, nil
+}
+
+// AdmitRemediation returns true if the remediation of the host of machine may
+// power it off within the RemediationLimits and, for a control-plane Machine,
+// without risking the quorum of the control plane. Otherwise the reason is
+// why it has to wait. The decisions on control-plane Machines always have a
+// reason.
+func (l *RemediationLimiter) AdmitRemediation(ctx context.Context, machine *machinev1beta1.Machine,
+	host *bmov1alpha1.BareMetalHost) (bool, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits, err := l.limits(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	limited := limits != nil && (limits.MaxInFlight > 0 || limits.MaxInFlightPerFailureDomain > 0)
+	if !limited && !isControlPlane(machine) {
+		return true, "", nil
+	}
+
+	machines, inFlight, err := l.inFlightHosts(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	delete(inFlight, client.ObjectKeyFromObject(host))
+
+	if limited {
+		reason, err := l.limitsReason(ctx, limits, host, inFlight)
+		if err != nil || reason != "" {
+			return false, reason, err
+		}
+	}
+	admitted, reason := true, ""
+	if isControlPlane(machine) {
+		admitted, reason, err = l.admitControlPlane(ctx, machine, machines, inFlight)
+		if err != nil || !admitted {
+			return false, reason, err
+		}
+	}
+	l.reserve(machine, host)
+	return admitted, reason, nil
+}
+
+// reserve counts host as remediated for machine until the cache shows that
+// its remediation started, so that the admissions in the meantime count it.
+// The caller holds mu.
+func (l *RemediationLimiter) reserve(machine *machinev1beta1.Machine, host *bmov1alpha1.BareMetalHost) {
+	if l.reserved == nil {
+		l.reserved = map[client.ObjectKey]reservation{}
+	}
+	if machine == nil {
+		machine = &machinev1beta1.Machine{}
+	}
+	l.reserved[client.ObjectKeyFromObject(host)] = reservation{
+		machine: machine.DeepCopy(),
+		expires: time.Now().Add(reservationTimeout),
+	}
+}
+
+// limitsReason returns why the remediation of host is beyond the limits, or
+// an empty reason if it is within them.
+func (l *RemediationLimiter) limitsReason(ctx context.Context, limits *RemediationLimits,
+	host *bmov1alpha1.BareMetalHost, inFlight map[client.ObjectKey]*machinev1beta1.Machine) (string, error) {
+	if limits.MaxInFlight > 0 && len(inFlight) >= limits.MaxInFlight {
+		return fmt.Sprintf("%d hosts are being remediated, the limit is %d",
+			len(inFlight), limits.MaxInFlight), nil
+	}
+
+	domain := host.Labels[limits.FailureDomainLabel]
+	if limits.MaxInFlightPerFailureDomain == 0 || domain == "" {
+		return "", nil
+	}
+	hosts := &bmov1alpha1.BareMetalHostList{}
+	if err := l.Client.List(ctx, hosts); err != nil {
+		return "", errors.Wrap(err, "failed to list hosts")
+	}
+	inDomain := 0
+	for i := range hosts.Items {
+		other := &hosts.Items[i]
+		if _, ok := inFlight[client.ObjectKeyFromObject(other)]; ok && other.Labels[limits.FailureDomainLabel] == domain {
+			inDomain++
+		}
+	}
+	if inDomain >= limits.MaxInFlightPerFailureDomain {
+		return fmt.Sprintf("%d hosts of failure domain %s are being remediated, the limit is %d",
+			inDomain, domain, limits.MaxInFlightPerFailureDomain), nil
+	}
+	return "", nil
+}
+
+// inFlightHosts returns the Machines, and the Machines whose remediation has
+// started and is not done yet or was admitted by the key of their host. The
+// reservations of the hosts the cache shows in flight are released. The
+// caller holds mu.
+func (l *RemediationLimiter) inFlightHosts(ctx context.Context) ([]machinev1beta1.Machine,
+	map[client.ObjectKey]*machinev1beta1.Machine, error) {
+	machines := &machinev1beta1.MachineList{}
+	if err := l.Client.List(ctx, machines); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list machines")
+	}
+	remediations := &infrav1.Metal3RemediationList{}
+	if err := l.Client.List(ctx, remediations); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list remediations")
+	}
+
+	remediated := map[client.ObjectKey]bool{}
+	for i := range remediations.Items {
+		remediation := &remediations.Items[i]
+		if !isRemediationInFlight(remediation) {
+			continue
+		}
+		for _, ownerRef := range remediation.OwnerReferences {
+			if ownerRef.Kind == "Machine" {
+				remediated[client.ObjectKey{Namespace: remediation.Namespace, Name: ownerRef.Name}] = true
+			}
+		}
+	}
+
+	inFlight := map[client.ObjectKey]*machinev1beta1.Machine{}
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if !remediated[client.ObjectKeyFromObject(machine)] && !actuator.RemediationInFlight(machine) {
+			continue
+		}
+		hostKey, err := actuator.HostKey(machine)
+		if err != nil || hostKey == nil {
+			continue
+		}
+		inFlight[*hostKey] = machine
+	}
+
+	now := time.Now()
+	for hostKey, r := range l.reserved {
+		if _, ok := inFlight[hostKey]; ok || now.After(r.expires) {
+			delete(l.reserved, hostKey)
+			continue
+		}
+		inFlight[hostKey] = r.machine
+	}
+	return machines.Items, inFlight, nil
+}
+
+// isRemediationInFlight returns true if the remediation has started and is
+// not done yet.
+func isRemediationInFlight(remediation *infrav1.Metal3Remediation) bool {
+	return slices.Contains(inFlightRemediationPhases, remediation.Status.Phase)
+}
+
+// limiter returns the RemediationLimiter of the manager. Without one, the
+// control plane is still protected, but there are no limits.
+func (r *RemediationManager) limiter() *RemediationLimiter {
+	if r.Limiter != nil {
+		return r.Limiter
+	}
+	return &RemediationLimiter{Client: r.Client}
+}
+
+// AdmitRemediation returns true if the host may be powered off within the
+// RemediationLimits and the quorum of the control plane. The decision is
+// recorded in the RemediationStatus with its reason, if it has one.
+func (r *RemediationManager) AdmitRemediation(ctx context.Context) (bool, string, error) {
+	host, _, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	if host == nil {
+		return false, "", errors.New("Unable to admit remediation, Host not found")
+	}
+
+	admitted, reason, err := r.limiter().AdmitRemediation(ctx, r.OCPMachine, host)
+	if err != nil {
+		return false, "", err
+	}
+	if admitted {
+		r.recordDecision(DecisionAdmitted, reason)
+	} else {
+		r.recordDecision(DecisionPending, reason)
+	}
+	return admitted, reason, nil
+}
+
+// recordDecision records a decision on the remediation and its reason in the
+// RemediationStatus. Admissions without a reason are only recorded if they
+// replace another decision.
+func (r *RemediationManager) recordDecision(decision, reason string) {
+	status := r.remediationStatus()
+	if status.Decision == decision && status.Reason == reason {
+		return
+	}
+	if decision == DecisionAdmitted && reason == "" && status.Decision == "" {
+		return
+	}
+	status.Decision = decision
+	status.Reason = reason
+	r.setRemediationStatus(status)
+}
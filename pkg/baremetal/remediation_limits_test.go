@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+)
+
+var _ = Describe("Remediation limits", func() {
+	configMap := types.NamespacedName{Namespace: namespaceName, Name: "remediation-limits"}
+
+	newHost := func(name, zone string) *bmov1alpha1.BareMetalHost {
+		return &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespaceName,
+				Labels:    map[string]string{corev1.LabelTopologyZone: zone},
+			},
+		}
+	}
+
+	newMachine := func(name string, host *bmov1alpha1.BareMetalHost, annotations map[string]string) *machinev1beta1.Machine {
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespaceName,
+				Annotations: map[string]string{HostAnnotation: namespaceName + "/" + host.Name},
+			},
+		}
+		for key, value := range annotations {
+			machine.Annotations[key] = value
+		}
+		return machine
+	}
+
+	newRemediation := func(machine *machinev1beta1.Machine, phase string) *infrav1.Metal3Remediation {
+		return &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      machine.Name,
+				Namespace: namespaceName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: machinev1beta1.GroupVersion.String(),
+					Kind:       "Machine",
+					Name:       machine.Name,
+				}},
+			},
+			Status: infrav1.Metal3RemediationStatus{Phase: phase},
+		}
+	}
+
+	newLimits := func(limits string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: configMap.Name, Namespace: configMap.Namespace},
+			Data:       map[string]string{RemediationLimitsKey: limits},
+		}
+	}
+
+	// host1 and host2 in zone a are remediated by a Metal3Remediation and by
+	// the MachineHealthCheck annotation; host3 in zone b is not; host4 in
+	// zone b has a pending remediation.
+	host1, host2, host3, host4 := newHost("host1", "a"), newHost("host2", "a"), newHost("host3", "b"), newHost("host4", "b")
+	machine1 := newMachine("machine1", host1, nil)
+	machine2 := newMachine("machine2", host2, map[string]string{
+		"host.metal3.io/external-remediation": "",
+		actuator.PowerOffModeAnnotation:       string(bmov1alpha1.RebootModeHard),
+	})
+	machine3 := newMachine("machine3", host3, nil)
+	machine4 := newMachine("machine4", host4, nil)
+	objects := []client.Object{
+		host1, host2, host3, host4, machine1, machine2, machine3, machine4,
+		newRemediation(machine1, infrav1.PhaseWaiting),
+		newRemediation(machine4, PhasePending),
+	}
+
+	admit := func(limits string, host *bmov1alpha1.BareMetalHost) string {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+			WithObjects(append(objects, newLimits(limits))...).Build()
+		limiter := &RemediationLimiter{Client: fakeClient, APIReader: fakeClient, ConfigMap: configMap}
+		admitted, reason, err := limiter.AdmitRemediation(context.TODO(), nil, host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(Equal(reason == ""))
+		return reason
+	}
+
+	It("should count the remediations of both kinds", func() {
+		Expect(admit("maxInFlight: 2", host3)).To(Equal("2 hosts are being remediated, the limit is 2"))
+		Expect(admit("maxInFlight: 3", host3)).To(BeEmpty())
+	})
+
+	It("should not count the remediation of the host itself", func() {
+		Expect(admit("maxInFlight: 2", host1)).To(BeEmpty())
+	})
+
+	It("should limit the remediations per failure domain", func() {
+		Expect(admit("maxInFlightPerFailureDomain: 2", host3)).To(BeEmpty())
+		host5 := newHost("host5", "a")
+		Expect(admit("maxInFlightPerFailureDomain: 2", host5)).To(Equal(
+			"2 hosts of failure domain a are being remediated, the limit is 2"))
+		Expect(admit("maxInFlightPerFailureDomain: 2\nfailureDomainLabel: rack", host5)).To(BeEmpty(),
+			"hosts without a failure domain should not be limited per failure domain")
+	})
+
+	It("should count the admitted remediations before they start", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+			WithObjects(append(objects, newLimits("maxInFlight: 3"))...).Build()
+		limiter := &RemediationLimiter{Client: fakeClient, APIReader: fakeClient, ConfigMap: configMap}
+		Expect(limiter.AdmitRemediation(context.TODO(), machine3, host3)).To(BeTrue())
+
+		host5 := newHost("host5", "b")
+		admitted, reason, err := limiter.AdmitRemediation(context.TODO(), nil, host5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).To(Equal("3 hosts are being remediated, the limit is 3"))
+
+		Expect(limiter.AdmitRemediation(context.TODO(), machine3, host3)).To(BeTrue(),
+			"the host admitted before should not count against itself")
+	})
+
+	It("should not limit without limits", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+		limiter := &RemediationLimiter{Client: fakeClient, APIReader: fakeClient, ConfigMap: configMap}
+		Expect(limiter.AdmitRemediation(context.TODO(), machine3, host3)).To(BeTrue())
+	})
+
+	It("should reject invalid limits", func() {
+		for _, limits := range []string{"maxInFlight: -1", "maxInFlight: 1\nmaxHosts: 2"} {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+				WithObjects(append(objects, newLimits(limits))...).Build()
+			limiter := &RemediationLimiter{Client: fakeClient, APIReader: fakeClient, ConfigMap: configMap}
+			_, _, err := limiter.AdmitRemediation(context.TODO(), machine3, host3)
+			Expect(err).To(HaveOccurred(), limits)
+		}
+	})
+
+	It("should record why the remediation is pending", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+			WithObjects(append(objects, newLimits("maxInFlight: 2"))...).Build()
+		remediation := newRemediation(machine3, "")
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, machine3, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		remediationMgr.Limiter = &RemediationLimiter{Client: fakeClient, APIReader: fakeClient, ConfigMap: configMap}
+
+		admitted, reason, err := remediationMgr.AdmitRemediation(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(admitted).To(BeFalse())
+		Expect(reason).NotTo(BeEmpty())
+		Expect(remediationMgr.remediationStatus().Decision).To(Equal(DecisionPending))
+		Expect(remediationMgr.remediationStatus().Reason).To(Equal(reason))
+	})
+})
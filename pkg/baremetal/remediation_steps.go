@@ -0,0 +1,325 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+)
+
+const (
+	// RemediationStepsAnnotation is the key for an optional annotation on a
+	// Metal3RemediationTemplate with the steps of an escalating remediation,
+	// as a JSON list of RemediationSteps. It is copied to the
+	// Metal3Remediations created from the template when they start, and
+	// replaces their strategy.
+	RemediationStepsAnnotation = "metal3.io/remediation-steps"
+
+	// RemediationStatusAnnotation is the key for the annotation in which the
+	// controller records the RemediationStatus of a Metal3Remediation, as
+	// JSON.
+	RemediationStatusAnnotation = "metal3.io/remediation-status"
+
+	// QuarantinedHostAnnotation is the key for an annotation that, when
+	// added to a BareMetalHost with any value, marks its hardware as
+	// suspect.
+	QuarantinedHostAnnotation = "metal3.io/quarantined"
+
+	// StepTimedOut is the result of a step that timed out and reached its
+	// retry limit.
+	StepTimedOut = "TimedOut"
+)
+
+// Final remediation steps, which can only end a list of RemediationSteps.
+const (
+	// DeleteMachineRemediationStep deletes the unhealthy Machine if it can be
+	// reprovisioned, and marks its host unhealthy so it is not claimed again.
+	DeleteMachineRemediationStep infrav1.RemediationType = "DeleteMachine"
+
+	// QuarantineRemediationStep keeps the unhealthy Machine, and marks its
+	// host unhealthy and quarantined.
+	QuarantineRemediationStep infrav1.RemediationType = "Quarantine"
+
+	// PhaseQuarantined is the final phase of a remediation that ended with
+	// the Quarantine step.
+	PhaseQuarantined = "Quarantined"
+)
+
+// RemediationStep is a step of an escalating remediation. The next step
+// starts when the step timed out RetryLimit+1 times.
+type RemediationStep struct {
+	// Type is the remediation strategy of the step, or one of the final
+	// steps.
+	Type infrav1.RemediationType `json:"type"`
+	// Timeout is how long to wait for the Node of the Machine to get
+	// healthy. The timeout of the strategy is used if it is not set.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// RetryLimit is how many times the step is retried after it timed out.
+	RetryLimit int `json:"retryLimit,omitempty"`
+	// SoftPowerOffTimeout is how long a soft power off of the host is tried
+	// before it is powered off hard. The host is powered off hard right
+	// away if it is not set.
+	SoftPowerOffTimeout *metav1.Duration `json:"softPowerOffTimeout,omitempty"`
+}
+
+// RemediationStepResult records how a step of a remediation ended.
+type RemediationStepResult struct {
+	// Type is the type of the step.
+	Type infrav1.RemediationType `json:"type"`
+	// Retries is how many times the step was retried.
+	Retries int `json:"retries"`
+	// Started is when the step started.
+	Started *metav1.Time `json:"started,omitempty"`
+	// Ended is when the step ended.
+	Ended metav1.Time `json:"ended"`
+	// Result is why the step ended.
+	Result string `json:"result"`
+}
+
+// RemediationStatus describes the progress of a Metal3Remediation through its
+// RemediationSteps, how its host was powered off, and whether it may be.
+type RemediationStatus struct {
+	// Step is the index of the current step.
+	Step int `json:"step,omitempty"`
+	// Type is the type of the current step.
+	Type infrav1.RemediationType `json:"type,omitempty"`
+	// Retries is how many times the current step was retried.
+	Retries int `json:"retries,omitempty"`
+	// Started is when the current step started.
+	Started *metav1.Time `json:"started,omitempty"`
+	// History are the results of the previous steps.
+	History []RemediationStepResult `json:"history,omitempty"`
+	// PowerOffMode is the mode of the last power off requested for the host.
+	PowerOffMode bmov1alpha1.RebootMode `json:"powerOffMode,omitempty"`
+	// PowerOffRequested is when the last power off was requested.
+	PowerOffRequested *metav1.Time `json:"powerOffRequested,omitempty"`
+	// Decision is the last decision on whether the host may be powered off
+	// or fenced.
+	Decision string `json:"decision,omitempty"`
+	// Reason explains the Decision.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RemediationStepsFromAnnotations returns the RemediationSteps set by the
+// annotations of a Metal3Remediation or Metal3RemediationTemplate, or nil if
+// there are none.
+func RemediationStepsFromAnnotations(annotations map[string]string) ([]RemediationStep, error) {
+	value, ok := annotations[RemediationStepsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	steps := []RemediationStep{}
+	if err := json.Unmarshal([]byte(value), &steps); err != nil {
+		return nil, errors.Wrapf(err, "annotation %s must be a JSON list of steps", RemediationStepsAnnotation)
+	}
+	return steps, nil
+}
+
+// remediationSteps returns the steps of the remediation, or nil if it follows
+// its strategy. Steps that cannot be read are ignored; the webhook rejects
+// them.
+func (r *RemediationManager) remediationSteps() []RemediationStep {
+	steps, err := RemediationStepsFromAnnotations(r.Metal3Remediation.Annotations)
+	if err != nil {
+		r.Log.Error(err, "ignoring remediation steps")
+		return nil
+	}
+	return steps
+}
+
+// currentStep returns the current step of the remediation, or nil if it
+// follows its strategy.
+func (r *RemediationManager) currentStep() *RemediationStep {
+	steps := r.remediationSteps()
+	if len(steps) == 0 {
+		return nil
+	}
+	status := r.remediationStatus()
+	if status.Step >= len(steps) {
+		return &steps[len(steps)-1]
+	}
+	return &steps[status.Step]
+}
+
+// remediationStatus returns the RemediationStatus recorded on the
+// remediation, or an empty one if there is none or it cannot be read.
+func (r *RemediationManager) remediationStatus() *RemediationStatus {
+	status := &RemediationStatus{}
+	value, ok := r.Metal3Remediation.Annotations[RemediationStatusAnnotation]
+	if !ok {
+		return status
+	}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return &RemediationStatus{}
+	}
+	return status
+}
+
+// setRemediationStatus records the status on the remediation.
+func (r *RemediationManager) setRemediationStatus(status *RemediationStatus) {
+	raw, err := json.Marshal(status)
+	if err != nil {
+		// cannot happen with a struct of strings, integers and times
+		return
+	}
+	if r.Metal3Remediation.Annotations == nil {
+		r.Metal3Remediation.Annotations = map[string]string{}
+	}
+	r.Metal3Remediation.Annotations[RemediationStatusAnnotation] = string(raw)
+}
+
+// remediationPolicyAnnotations are the annotations of a
+// Metal3RemediationTemplate that configure the remediations created from it.
+var remediationPolicyAnnotations = []string{
+	RemediationStepsAnnotation,
+	actuator.SoftPowerOffTimeoutAnnotation,
+}
+
+// ResolveRemediationPolicy copies the policy annotations, such as the steps,
+// of the Metal3RemediationTemplate the remediation was created from that the
+// remediation does not have yet, so that later changes to the template do not
+// affect it. Then the first step is started, if there are steps.
+func (r *RemediationManager) ResolveRemediationPolicy(ctx context.Context) error {
+	if err := r.copyTemplatePolicy(ctx); err != nil {
+		return err
+	}
+
+	steps := r.remediationSteps()
+	if len(steps) == 0 {
+		return nil
+	}
+	now := metav1.Now()
+	r.setRemediationStatus(&RemediationStatus{Type: steps[0].Type, Started: &now})
+	return nil
+}
+
+// copyTemplatePolicy copies the policy annotations of the template of the
+// remediation, if it was created from a Metal3RemediationTemplate.
+func (r *RemediationManager) copyTemplatePolicy(ctx context.Context) error {
+	rem := r.Metal3Remediation
+	templateName := rem.Annotations[machinev1beta1.TemplateClonedFromNameAnnotation]
+	groupKind := infrav1.GroupVersion.WithKind("Metal3RemediationTemplate").GroupKind()
+	if templateName == "" || rem.Annotations[machinev1beta1.TemplateClonedFromGroupKindAnnotation] != groupKind.String() {
+		return nil
+	}
+
+	missing := false
+	for _, key := range remediationPolicyAnnotations {
+		if _, ok := rem.Annotations[key]; !ok {
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	template := &infrav1.Metal3RemediationTemplate{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: templateName, Namespace: rem.Namespace}, template)
+	if apierrors.IsNotFound(err) {
+		r.Log.Info("Remediation template not found, using the remediation strategy", "template", templateName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, key := range remediationPolicyAnnotations {
+		value, ok := template.Annotations[key]
+		if _, set := rem.Annotations[key]; !ok || set {
+			continue
+		}
+		rem.Annotations[key] = value
+	}
+	return nil
+}
+
+// AdvanceRemediationStep records the result of the current step in the
+// history and starts the next one. It returns false, without recording
+// anything, if the remediation has no steps or is at its last one.
+func (r *RemediationManager) AdvanceRemediationStep(result string) bool {
+	steps := r.remediationSteps()
+	status := r.remediationStatus()
+	if status.Step+1 >= len(steps) {
+		return false
+	}
+
+	status.History = append(status.History, RemediationStepResult{
+		Type:    steps[status.Step].Type,
+		Retries: status.Retries,
+		Started: status.Started,
+		Ended:   metav1.Now(),
+		Result:  result,
+	})
+	status.Step++
+	status.Type = steps[status.Step].Type
+	status.Retries = 0
+	now := metav1.Now()
+	status.Started = &now
+	r.setRemediationStatus(status)
+	r.Log.Info("Escalating remediation", "step", status.Step, "type", status.Type, "result", result)
+	return true
+}
+
+// SetQuarantineAnnotation marks the host of the unhealthy Machine as
+// quarantined and unhealthy, so that it is not claimed again and its Machine
+// is deleted first when its MachineSet is scaled down.
+func (r *RemediationManager) SetQuarantineAnnotation(ctx context.Context) error {
+	host, helper, err := r.GetUnhealthyHost(ctx)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return errors.New("Unable to quarantine, Host not found")
+	}
+
+	r.Log.Info("Quarantining host", "host", host.Name)
+	if host.Annotations == nil {
+		host.Annotations = make(map[string]string, 2)
+	}
+	host.Annotations[QuarantinedHostAnnotation] = "capm3/RemediationFailed"
+	host.Annotations[infrav1.UnhealthyAnnotation] = "capm3/UnhealthyNode"
+	return helper.Patch(ctx, host)
+}
+
+// GetSoftPowerOffTimeout returns how long a soft power off of the host is
+// tried before it is powered off hard, or nil if it should be powered off
+// hard right away. With steps, it is set by the current step; otherwise by
+// the SoftPowerOffTimeoutAnnotation of the remediation.
+func (r *RemediationManager) GetSoftPowerOffTimeout() *metav1.Duration {
+	if step := r.currentStep(); step != nil {
+		return step.SoftPowerOffTimeout
+	}
+	timeout, err := actuator.SoftPowerOffTimeout(r.Metal3Remediation.Annotations)
+	if err != nil {
+		r.Log.Error(err, "ignoring soft power off timeout")
+		return nil
+	}
+	return timeout
+}
+
+// GetPowerOffMode returns the mode of the last power off requested for the
+// host and when it was requested, as recorded in the remediation status.
+func (r *RemediationManager) GetPowerOffMode() (bmov1alpha1.RebootMode, *metav1.Time) {
+	status := r.remediationStatus()
+	return status.PowerOffMode, status.PowerOffRequested
+}
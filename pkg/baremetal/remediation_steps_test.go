@@ -0,0 +1,203 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+)
+
+var _ = Describe("Metal3Remediation steps", func() {
+	newRemediation := func(annotations ...map[string]string) *infrav1.Metal3Remediation {
+		merged := map[string]string{}
+		for _, a := range annotations {
+			for key, value := range a {
+				merged[key] = value
+			}
+		}
+		return &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myremediation",
+				Namespace:   namespaceName,
+				Annotations: merged,
+			},
+			Spec: infrav1.Metal3RemediationSpec{
+				Strategy: &infrav1.RemediationStrategy{
+					Type:       infrav1.RebootRemediationStrategy,
+					RetryLimit: 2,
+					Timeout:    &metav1.Duration{Duration: 600 * time.Second},
+				},
+			},
+		}
+	}
+
+	template := &infrav1.Metal3RemediationTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mytemplate",
+			Namespace: namespaceName,
+			Annotations: map[string]string{
+				RemediationStepsAnnotation: `[{"type":"Reboot","timeout":"5m","retryLimit":1},` +
+					`{"type":"Reprovision"},{"type":"Quarantine"}]`,
+			},
+		},
+	}
+
+	clonedFrom := map[string]string{
+		machinev1beta1.TemplateClonedFromNameAnnotation:      "mytemplate",
+		machinev1beta1.TemplateClonedFromGroupKindAnnotation: "Metal3RemediationTemplate.infrastructure.cluster.x-k8s.io",
+	}
+
+	It("should copy the steps of the template and walk through them", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(template).Build()
+		remediation := newRemediation(clonedFrom)
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, nil, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.ResolveRemediationPolicy(context.TODO())).To(Succeed())
+		Expect(remediation.Annotations).To(HaveKeyWithValue(RemediationStepsAnnotation,
+			template.Annotations[RemediationStepsAnnotation]), "steps should be copied from the template")
+		Expect(remediationMgr.remediationStatus().Started).NotTo(BeNil(), "first step should be started")
+
+		By("Following the first step")
+		Expect(remediationMgr.GetRemediationType()).To(Equal(infrav1.RebootRemediationStrategy))
+		Expect(remediationMgr.GetTimeout().Duration).To(Equal(5 * time.Minute))
+		Expect(remediationMgr.RetryLimitIsSet()).To(BeTrue())
+		Expect(remediationMgr.HasReachRetryLimit()).To(BeFalse())
+		remediationMgr.IncreaseRetryCount()
+		Expect(remediationMgr.HasReachRetryLimit()).To(BeTrue())
+		Expect(remediation.Status.RetryCount).To(Equal(1))
+
+		By("Escalating to the second step")
+		Expect(remediationMgr.AdvanceRemediationStep(StepTimedOut)).To(BeTrue())
+		Expect(remediationMgr.GetRemediationType()).To(Equal(ReprovisionRemediationStrategy))
+		Expect(remediationMgr.GetTimeout().Duration).To(Equal(600*time.Second), "strategy timeout should be used")
+		Expect(remediationMgr.RetryLimitIsSet()).To(BeFalse())
+		Expect(remediationMgr.HasReachRetryLimit()).To(BeTrue())
+		Expect(remediation.Status.RetryCount).To(Equal(1), "total retry count should be kept")
+
+		status := remediationMgr.remediationStatus()
+		Expect(status.Step).To(Equal(1))
+		Expect(status.Type).To(Equal(ReprovisionRemediationStrategy))
+		Expect(status.Retries).To(BeZero())
+		Expect(status.History).To(HaveLen(1))
+		Expect(status.History[0].Type).To(Equal(infrav1.RebootRemediationStrategy))
+		Expect(status.History[0].Retries).To(Equal(1))
+		Expect(status.History[0].Result).To(Equal(StepTimedOut))
+
+		By("Escalating to the last step")
+		Expect(remediationMgr.AdvanceRemediationStep(StepTimedOut)).To(BeTrue())
+		Expect(remediationMgr.GetRemediationType()).To(Equal(QuarantineRemediationStep))
+		Expect(remediationMgr.AdvanceRemediationStep(StepTimedOut)).To(BeFalse())
+		Expect(remediationMgr.remediationStatus().History).To(HaveLen(2))
+	})
+
+	It("should keep the steps of the remediation", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(template).Build()
+		remediation := newRemediation(clonedFrom, map[string]string{
+			RemediationStepsAnnotation: `[{"type":"PowerOff"}]`,
+		})
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, nil, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.ResolveRemediationPolicy(context.TODO())).To(Succeed())
+		Expect(remediation.Annotations).To(HaveKeyWithValue(RemediationStepsAnnotation, `[{"type":"PowerOff"}]`))
+		Expect(remediationMgr.GetRemediationType()).To(Equal(PowerOffRemediationStrategy))
+	})
+
+	It("should follow the strategy without steps", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+		remediation := newRemediation(clonedFrom)
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, nil, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.ResolveRemediationPolicy(context.TODO())).To(Succeed(), "missing template should be ignored")
+		Expect(remediation.Annotations).NotTo(HaveKey(RemediationStepsAnnotation))
+		Expect(remediation.Annotations).NotTo(HaveKey(RemediationStatusAnnotation))
+		Expect(remediationMgr.GetRemediationType()).To(Equal(infrav1.RebootRemediationStrategy))
+		Expect(remediationMgr.GetTimeout().Duration).To(Equal(600 * time.Second))
+		Expect(remediationMgr.AdvanceRemediationStep(StepTimedOut)).To(BeFalse())
+		remediationMgr.IncreaseRetryCount()
+		Expect(remediation.Annotations).NotTo(HaveKey(RemediationStatusAnnotation))
+	})
+
+	It("should copy the soft power off timeout of the template", func() {
+		softTemplate := template.DeepCopy()
+		softTemplate.Annotations = map[string]string{actuator.SoftPowerOffTimeoutAnnotation: "2m"}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(softTemplate).Build()
+		remediation := newRemediation(clonedFrom)
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, nil, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.GetSoftPowerOffTimeout()).To(BeNil())
+		Expect(remediationMgr.ResolveRemediationPolicy(context.TODO())).To(Succeed())
+		Expect(remediation.Annotations).To(HaveKeyWithValue(actuator.SoftPowerOffTimeoutAnnotation, "2m"))
+		Expect(remediation.Annotations).NotTo(HaveKey(RemediationStepsAnnotation))
+		Expect(remediationMgr.GetSoftPowerOffTimeout().Duration).To(Equal(2 * time.Minute))
+	})
+
+	It("should use the soft power off timeout of the current step", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+		remediation := newRemediation(map[string]string{
+			actuator.SoftPowerOffTimeoutAnnotation: "2m",
+			RemediationStepsAnnotation:             `[{"type":"Reboot","softPowerOffTimeout":"30s"},{"type":"PowerOff"}]`,
+		})
+		remediationMgr, err := NewRemediationManager(fakeClient, remediation, nil, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.ResolveRemediationPolicy(context.TODO())).To(Succeed())
+		Expect(remediationMgr.GetSoftPowerOffTimeout().Duration).To(Equal(30 * time.Second))
+		Expect(remediationMgr.AdvanceRemediationStep(StepTimedOut)).To(BeTrue())
+		Expect(remediationMgr.GetSoftPowerOffTimeout()).To(BeNil(), "step without grace period should power off hard")
+	})
+
+	It("should quarantine the host", func() {
+		bmhost := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myhost",
+				Namespace: namespaceName,
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mymachine",
+				Namespace: namespaceName,
+				Annotations: map[string]string{
+					HostAnnotation: namespaceName + "/myhost",
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(bmhost, machine).Build()
+		remediationMgr, err := NewRemediationManager(fakeClient, newRemediation(), machine, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(remediationMgr.SetQuarantineAnnotation(context.TODO())).To(Succeed())
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(bmhost), bmhost)).To(Succeed())
+		Expect(bmhost.Annotations).To(HaveKey(QuarantinedHostAnnotation))
+		Expect(bmhost.Annotations).To(HaveKey(infrav1.UnhealthyAnnotation))
+	})
+})
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package celselector compiles and evaluates the CEL expressions used to
+// select BareMetalHosts by their spec, status and hardware details.
+package celselector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// HostVariable is the name of the variable holding the BareMetalHost,
+	// in its JSON representation, in an expression.
+	HostVariable = "host"
+
+	// costLimit bounds the work done evaluating an expression against one
+	// host, so that a bad expression cannot stall the controllers.
+	costLimit = 1000000
+
+	// cacheSize is the number of compiled expressions kept.
+	cacheSize = 256
+)
+
+var (
+	environment = sync.OnceValues(func() (*cel.Env, error) {
+		return cel.NewEnv(
+			cel.Variable(HostVariable, cel.MapType(cel.StringType, cel.DynType)),
+			ext.Strings(),
+		)
+	})
+
+	// programs caches compiled Programs by expression, so that the
+	// expression of each MachineSet is only compiled once, however many
+	// hosts it is evaluated against.
+	programs = lru.New(cacheSize)
+)
+
+// Program is a compiled expression.
+type Program struct {
+	expression string
+	program    cel.Program
+}
+
+// Compile returns the Program for the expression, which must evaluate to a
+// bool. The error describes the problems with the expression.
+func Compile(expression string) (*Program, error) {
+	if cached, ok := programs.Get(expression); ok {
+		return cached.(*Program), nil
+	}
+
+	env, err := environment()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	outputType := ast.OutputType()
+	if !outputType.IsExactType(cel.BoolType) && !outputType.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %s", outputType)
+	}
+	program, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Program{expression: expression, program: program}
+	programs.Add(expression, p)
+	return p, nil
+}
+
+// String returns the expression.
+func (p *Program) String() string {
+	return p.expression
+}
+
+// Matches evaluates the expression with the JSON representation of obj as
+// the host variable. Selecting a field the object does not have is an
+// error, which the has() macro can guard against.
+func (p *Program) Matches(obj interface{}) (bool, error) {
+	host, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := p.program.Eval(map[string]interface{}{HostVariable: host})
+	if err != nil {
+		return false, err
+	}
+	matches, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %s, not a bool", out.Type())
+	}
+	return matches, nil
+}
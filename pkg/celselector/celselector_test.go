@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package celselector
+
+import (
+	"testing"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fastStorageSelector = `host.status.hardware.nics.filter(n, n.speedGbps >= 25).size() >= 2 &&
+	host.status.hardware.storage.exists(d, d.name == host.spec.rootDeviceHints.deviceName &&
+		d.type == 'NVME' && !(d.serialNumber in ['BAD1', 'BAD2']))`
+
+func hostWithHardware(nicSpeeds []int, rootSerial string) *bmh.BareMetalHost {
+	host := &bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{Name: "host1", Namespace: "default"},
+		Spec: bmh.BareMetalHostSpec{
+			RootDeviceHints: &bmh.RootDeviceHints{DeviceName: "/dev/nvme0n1"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			HardwareDetails: &bmh.HardwareDetails{
+				Storage: []bmh.Storage{
+					{Name: "/dev/sda", Type: bmh.HDD, SerialNumber: "HDD1"},
+					{Name: "/dev/nvme0n1", Type: bmh.NVME, SerialNumber: rootSerial},
+				},
+			},
+		},
+	}
+	for _, speed := range nicSpeeds {
+		host.Status.HardwareDetails.NIC = append(host.Status.HardwareDetails.NIC, bmh.NIC{SpeedGbps: speed})
+	}
+	return host
+}
+
+func TestCompile(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario      string
+		Expression    string
+		ExpectedError string
+	}{
+		{
+			Scenario:   "valid",
+			Expression: fastStorageSelector,
+		},
+		{
+			Scenario:   "dynamic result",
+			Expression: "host.spec.online",
+		},
+		{
+			Scenario:      "syntax error",
+			Expression:    "host.spec.online &&",
+			ExpectedError: "Syntax error",
+		},
+		{
+			Scenario:      "undeclared variable",
+			Expression:    "machine.spec.online",
+			ExpectedError: "undeclared reference to 'machine'",
+		},
+		{
+			Scenario:      "not a bool",
+			Expression:    "size(host.metadata.name)",
+			ExpectedError: "must evaluate to a bool, not int",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			program, err := Compile(tc.Expression)
+			if tc.ExpectedError == "" {
+				if assert.NoError(t, err) {
+					assert.Equal(t, tc.Expression, program.String())
+				}
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestCompileCached(t *testing.T) {
+	first, err := Compile(fastStorageSelector)
+	assert.NoError(t, err)
+	second, err := Compile(fastStorageSelector)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestMatches(t *testing.T) {
+	program, err := Compile(fastStorageSelector)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tc := range []struct {
+		Scenario      string
+		Host          *bmh.BareMetalHost
+		Matches       bool
+		ExpectedError string
+	}{
+		{
+			Scenario: "two fast NICs and an NVMe root disk",
+			Host:     hostWithHardware([]int{25, 100, 1}, "GOOD1"),
+			Matches:  true,
+		},
+		{
+			Scenario: "one fast NIC",
+			Host:     hostWithHardware([]int{25, 10}, "GOOD1"),
+			Matches:  false,
+		},
+		{
+			Scenario: "blocked root disk",
+			Host:     hostWithHardware([]int{25, 25}, "BAD2"),
+			Matches:  false,
+		},
+		{
+			Scenario:      "not inspected",
+			Host:          &bmh.BareMetalHost{ObjectMeta: metav1.ObjectMeta{Name: "host1"}},
+			ExpectedError: "no such key: hardware",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			matches, err := program.Matches(tc.Host)
+			if tc.ExpectedError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.ExpectedError)
+				}
+				assert.False(t, matches)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Matches, matches)
+		})
+	}
+
+	// has() guards against hosts that were not inspected
+	program, err = Compile("has(host.status.hardware) && host.status.hardware.nics.size() > 0")
+	if assert.NoError(t, err) {
+		matches, err := program.Matches(&bmh.BareMetalHost{})
+		assert.NoError(t, err)
+		assert.False(t, matches)
+	}
+}
@@ -30,16 +30,22 @@ import (
 	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	bmv1beta1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1beta1"
 	machineapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
 	gherrors "github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
@@ -48,7 +54,25 @@ const (
 	ProviderName = "baremetal"
 	// HostAnnotation is the key for an annotation that should go on a Machine to
 	// reference what BareMetalHost it corresponds to.
-	HostAnnotation                   = "metal3.io/BareMetalHost"
+	HostAnnotation = "metal3.io/BareMetalHost"
+	// DeletionPolicyAnnotation is the key for an optional annotation on a
+	// Machine that overrides the DeletionPolicy from its ProviderSpec.
+	DeletionPolicyAnnotation = "metal3.io/deletion-policy"
+	// RetainedHostAnnotation is the key for an annotation that is put on a
+	// BareMetalHost released with the Retain deletion policy. The value is the
+	// key of the Machine that released it. The host will not be claimed by
+	// another Machine until an administrator removes the annotation.
+	RetainedHostAnnotation = "metal3.io/retained-by-machine"
+	// AdoptHostAnnotation is the key for an annotation that, when added to a
+	// Machine with any value, makes it adopt an externally provisioned
+	// BareMetalHost as if AdoptExternallyProvisioned were set in its
+	// ProviderSpec.
+	AdoptHostAnnotation = "metal3.io/adopt-externally-provisioned-host"
+	// ReprovisioningAnnotation is the key for an annotation that is put on a
+	// BareMetalHost while a remediation deprovisions it and provisions it
+	// again for the same Machine. The value is the name of the Machine, which
+	// keeps existing until the host is provisioned again.
+	ReprovisioningAnnotation         = "remediation.metal3.io/reprovisioning"
 	requeueAfter                     = time.Second * 30
 	externalRemediationAnnotation    = "host.metal3.io/external-remediation"
 	poweredOffForRemediation         = "remediation.metal3.io/powered-off-for-remediation"
@@ -61,6 +85,13 @@ const (
 	annotationTimestampFormat        = time.RFC3339
 	remediationPowerOnDefaultTimeout = 20 * time.Minute
 	powerOnWillTimeoutAtAnnotation   = "remediation.metal3.io/power-on-will-timeout-at"
+
+	// hostManagedCondition is set to False on a Machine while its
+	// BareMetalHost is detached or paused, and changes to the host are on
+	// hold.
+	hostManagedCondition machinev1beta1.ConditionType = "BareMetalHostManaged"
+	hostDetachedReason                                = "HostDetached"
+	hostPausedReason                                  = "HostPaused"
 )
 
 // Add RBAC rules to access cluster-api resources
@@ -73,18 +104,26 @@ const (
 
 // Actuator is responsible for performing machine reconciliation
 type Actuator struct {
-	client client.Client
+	client              client.Client
+	eventRecorder       record.EventRecorder
+	remediationAdmitter RemediationAdmitter
 }
 
 // ActuatorParams holds parameter information for Actuator
 type ActuatorParams struct {
-	Client client.Client
+	Client        client.Client
+	EventRecorder record.EventRecorder
+	// RemediationAdmitter limits how many hosts are remediated at once. If
+	// it is nil, there is no limit.
+	RemediationAdmitter RemediationAdmitter
 }
 
 // NewActuator creates a new Actuator
 func NewActuator(params ActuatorParams) (*Actuator, error) {
 	return &Actuator{
-		client: params.Client,
+		client:              params.Client,
+		eventRecorder:       params.EventRecorder,
+		remediationAdmitter: params.RemediationAdmitter,
 	}, nil
 }
 
@@ -103,11 +142,20 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1beta1.Machine)
 		log.Printf("Error reading ProviderSpec for machine %s: %s", machine.Name, err.Error())
 		return err
 	}
+	config.AdoptExternallyProvisioned = adoptsExternallyProvisionedHost(machine, config)
 	err = config.IsValid()
+	if err == nil {
+		err = ValidateCELSelector(config.HostSelector.CELSelector,
+			field.NewPath("hostSelector", "celSelector")).ToAggregate()
+	}
 	if err != nil {
 		return a.setError(ctx, machine, err.Error())
 	}
 
+	if config.UseHostClaim {
+		return a.createWithHostClaim(ctx, machine, config)
+	}
+
 	// look for associated BMH
 	host, err := a.getHost(ctx, machine)
 	if err != nil {
@@ -116,22 +164,13 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1beta1.Machine)
 
 	// none found, so try to choose one
 	if host == nil {
-		host, err = a.chooseHost(ctx, machine)
+		var rejections *RejectionSummary
+		host, rejections, err = a.chooseHost(ctx, machine)
 		if err != nil {
 			return err
 		}
 		if host == nil {
-			errorReason := machinev1beta1.InsufficientResourcesMachineError
-			msg := "No available BareMetalHost found"
-			log.Printf("%s", msg)
-			if machine.Status.ErrorReason == nil || *machine.Status.ErrorReason != errorReason {
-				machine.Status.ErrorReason = &errorReason
-				machine.Status.ErrorMessage = &msg
-				if err := a.client.Status().Update(ctx, machine); err != nil {
-					return gherrors.Wrap(err, "failed to set insufficient resources error")
-				}
-			}
-			return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+			return a.setNoHostAvailableError(ctx, machine, rejections)
 		}
 		log.Printf("Associating machine %s with host %s", machine.Name, host.Name)
 	} else {
@@ -162,6 +201,14 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1beta1.Machine)
 		return err
 	}
 
+	if usesHostClaim(machine) {
+		if policy := deletionPolicy(machine); policy != bmv1alpha1.DeletionPolicyDeprovision {
+			log.Printf("Ignoring deletion policy %s on machine %s, the host of its HostClaim is always deprovisioned",
+				policy, machine.Name)
+		}
+		return a.deleteHostClaim(ctx, machine)
+	}
+
 	host, err := a.getHost(ctx, machine)
 	if err != nil {
 		return err
@@ -173,16 +220,38 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1beta1.Machine)
 	}
 
 	log.Printf("deleting machine %v using host %v", machine.Name, host.Name)
+	originalHost := host.DeepCopy()
+
+	// Even releasing the host writes to it, so nothing is done until the
+	// hold is lifted, whoever consumes the host
+	if err := a.updateHostManagedCondition(ctx, machine, host); err != nil {
+		return err
+	}
+	if reason, _ := hostHoldReason(host); reason != "" {
+		log.Printf("not releasing host %v while it is on hold (%s)", host.Name, reason)
+		return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+	}
 
 	if host.Spec.ConsumerRef == nil {
-		return a.releaseHost(ctx, host, machine)
+		return a.releaseHost(ctx, host, originalHost, machine)
 	}
 
 	// Don't deprovision the Host if it is consumed by some other machine
 	if !consumerRefMatches(host.Spec.ConsumerRef, machine) {
 		log.Printf("host associated with %v, not machine %v.",
 			host.Spec.ConsumerRef.Name, machine.Name)
-		return a.releaseHost(ctx, host, machine)
+		return a.releaseHost(ctx, host, originalHost, machine)
+	}
+
+	if policy := deletionPolicy(machine); policy != bmv1alpha1.DeletionPolicyDeprovision {
+		log.Printf("releasing host %v without deprovisioning (deletion policy %s)", host.Name, policy)
+		if policy == bmv1alpha1.DeletionPolicyRetain {
+			if host.Annotations == nil {
+				host.Annotations = make(map[string]string)
+			}
+			host.Annotations[RetainedHostAnnotation] = machine.Namespace + "/" + machine.Name
+		}
+		return a.releaseHost(ctx, host, originalHost, machine)
 	}
 
 	if host.Spec.Image != nil ||
@@ -192,7 +261,7 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1beta1.Machine)
 		host.Spec.CustomDeploy = nil
 		host.Spec.Online = host.Spec.DisablePowerOff
 		host.Spec.UserData = nil
-		err = a.client.Update(ctx, host)
+		err = a.patch(ctx, host, originalHost)
 		if err != nil && !errors.IsNotFound(err) {
 			return gherrors.Wrap(err, "failed to deprovision host")
 		}
@@ -214,7 +283,7 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1beta1.Machine)
 		return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
 	}
 
-	return a.releaseHost(ctx, host, machine)
+	return a.releaseHost(ctx, host, originalHost, machine)
 }
 
 // Update updates a machine and is invoked by the Machine Controller
@@ -233,6 +302,10 @@ func (a *Actuator) Update(ctx context.Context, machine *machinev1beta1.Machine)
 		return fmt.Errorf("host not found for machine %s", machine.Name)
 	}
 
+	if err := a.updateHostManagedCondition(ctx, machine, host); err != nil {
+		return err
+	}
+
 	// Provisioning Phase
 
 	if err := a.ensureMachineProviderID(ctx, machine, host); err != nil {
@@ -254,6 +327,12 @@ func (a *Actuator) Update(ctx context.Context, machine *machinev1beta1.Machine)
 
 	// Running phase
 
+	if adoptsExternallyProvisionedHost(machine, machineConfig(machine)) {
+		if err := a.linkAdoptedNode(ctx, machine, host); err != nil {
+			return err
+		}
+	}
+
 	if err := a.ensureNodeProviderID(ctx, machine); err != nil {
 		return err
 	}
@@ -286,7 +365,8 @@ func (a *Actuator) Exists(ctx context.Context, machine *machinev1beta1.Machine)
 		return false, a.clearMachineAddresses(ctx, machine)
 	}
 
-	if !consumerRefMatches(host.Spec.ConsumerRef, machine) {
+	if !consumerRefMatches(host.Spec.ConsumerRef, machine) &&
+		!hostClaimRefMatches(host.Spec.ConsumerRef, machine) {
 		log.Printf("Machine %v does not have provisioned Host (%v is owned by %v).",
 			machine.Name, host.Name, host.Spec.ConsumerRef)
 		// Clear machine addresses so that a new Node provisioned on a new Host
@@ -295,6 +375,13 @@ func (a *Actuator) Exists(ctx context.Context, machine *machinev1beta1.Machine)
 		return false, a.clearMachineAddresses(ctx, machine)
 	}
 
+	if reason, _ := hostHoldReason(host); reason != "" {
+		// The host status is not kept up to date while the host is on hold,
+		// so trust that the instance still exists.
+		log.Printf("Machine %v exists but Host is on hold (%s).", machine.Name, reason)
+		return true, nil
+	}
+
 	// FIXME(rdo): This is a temporary workaround to handle states that were removed by
 	// metal3-io/baremetal-operator/pull/388. If a 4.6 cluster is upgraded with nodes
 	// in a state that isn't handled by the BMO, we may experience unexpected behaviour.
@@ -306,6 +393,14 @@ func (a *Actuator) Exists(ctx context.Context, machine *machinev1beta1.Machine)
 		}
 	}
 
+	if host.Annotations[ReprovisioningAnnotation] == machine.Name {
+		// The host is reimaged for this Machine by a remediation, so the
+		// instance will exist again and its addresses are kept.
+		log.Printf("Machine %v exists but Host is being reprovisioned (%s).",
+			machine.Name, host.Status.Provisioning.State)
+		return true, nil
+	}
+
 	switch host.Status.Provisioning.State {
 	case bmh.StateProvisioned, bmh.StateExternallyProvisioned, bmh.StateUnmanaged:
 		log.Printf("Machine %v exists.", machine.Name)
@@ -339,6 +434,13 @@ func (a *Actuator) GetKubeConfig(controlPlaneMachine *machinev1beta1.Machine) (s
 	return "", fmt.Errorf("TODO: Not yet implemented")
 }
 
+// HostKey returns the namespace and name of the BareMetalHost of the Machine,
+// from its ProviderID or HostAnnotation, or nil if it has not claimed one.
+func HostKey(machine *machinev1beta1.Machine) (*client.ObjectKey, error) {
+	_, key, _, err := getHostKey(context.TODO(), machine)
+	return key, err
+}
+
 func getHostKey(ctx context.Context, machine *machinev1beta1.Machine) (provider string, key *client.ObjectKey, uid *types.UID, err error) {
 	if machine.Spec.ProviderID != nil {
 		provider = *machine.Spec.ProviderID
@@ -391,8 +493,8 @@ func getHostKey(ctx context.Context, machine *machinev1beta1.Machine) (provider
 }
 
 // getHost gets the associated host by looking for an annotation on the machine
-// that contains a reference to the host. Returns nil if not found. Assumes the
-// host is in the same namespace as the machine.
+// that contains a reference to the host. Returns nil if not found. The host
+// may be in a different namespace from the machine.
 func (a *Actuator) getHost(ctx context.Context, machine *machinev1beta1.Machine) (*bmh.BareMetalHost, error) {
 	provider, key, uid, err := getHostKey(ctx, machine)
 	if err != nil {
@@ -436,8 +538,7 @@ func SelectorFromProviderSpec(providerspec *machinev1beta1.ProviderSpec) (labels
 		reqs = append(reqs, *r)
 	}
 	for _, req := range config.HostSelector.MatchExpressions {
-		lowercaseOperator := selection.Operator(strings.ToLower(string(req.Operator)))
-		r, err := labels.NewRequirement(req.Key, lowercaseOperator, req.Values)
+		r, err := req.Requirement()
 		if err != nil {
 			log.Printf("Failed to create MatchExpression requirement: %v", err)
 			return nil, err
@@ -451,80 +552,51 @@ func SelectorFromProviderSpec(providerspec *machinev1beta1.ProviderSpec) (labels
 // chooseHost iterates through known hosts and returns one that can be
 // associated with the machine. It searches all hosts in case one already has an
 // association with this machine.
-func (a *Actuator) chooseHost(ctx context.Context, machine *machinev1beta1.Machine) (*bmh.BareMetalHost, error) {
-	// get list of BMH
-	hosts := bmh.BareMetalHostList{}
-	opts := &client.ListOptions{
-		Namespace: machine.Namespace,
-	}
-
-	err := a.client.List(ctx, &hosts, opts)
+func (a *Actuator) chooseHost(ctx context.Context, machine *machinev1beta1.Machine) (*bmh.BareMetalHost, *RejectionSummary, error) {
+	// get list of BMH from every namespace this machine may use
+	hosts, err := ListHosts(ctx, a.client, &machine.Spec.ProviderSpec, machine.Namespace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Using the label selector on ListOptions above doesn't seem to work.
-	// I think it's because we have a local cache of all BareMetalHosts.
-	labelSelector, err := SelectorFromProviderSpec(&machine.Spec.ProviderSpec)
+	filter, err := NewHostFilter(machine)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	availableHosts := []*bmh.BareMetalHost{}
-	for i, host := range hosts.Items {
+	rejections := NewRejectionSummary()
+	for i, host := range hosts {
 
 		if consumerRefMatches(host.Spec.ConsumerRef, machine) {
 			// if we see a host that thinks this machine is consuming
 			// it, we should oblige it
 			log.Printf("found host %s with existing ConsumerRef", host.Name)
-			return &hosts.Items[i], nil
+			return &hosts[i], rejections, nil
 		}
 
-		if host.Spec.ConsumerRef != nil {
-			// consumerRef is set and does not match, so the host is
-			// in use
-			continue
-		}
-		if host.GetDeletionTimestamp() != nil {
-			// the host is being deleted
-			continue
-		}
-		switch host.Status.Provisioning.State {
-		case bmh.StateReady, bmh.StateAvailable:
-			// the host is available to be provisioned
-		default:
-			// the host has not completed introspection or has an error
-			continue
-		}
-		if host.Status.ErrorMessage != "" {
-			// the host has some sort of error
-			continue
-		}
-		if host.Spec.ExternallyProvisioned {
-			// the host was provisioned by something else, we should
-			// not overwrite it
-			continue
-		}
-
-		if labelSelector.Matches(labels.Set(host.ObjectMeta.Labels)) {
+		reason, _ := filter.Reject(&hosts[i])
+		rejections.Add(&hosts[i], reason)
+		switch reason {
+		case "":
 			log.Printf("Host '%s' matched hostSelector for Machine '%s'",
 				host.Name, machine.Name)
-			availableHosts = append(availableHosts, &hosts.Items[i])
-		} else {
+			availableHosts = append(availableHosts, &hosts[i])
+		case HostSelectorMismatch:
 			log.Printf("Host '%s' did not match hostSelector for Machine '%s'",
 				host.Name, machine.Name)
 		}
 	}
 	log.Printf("%d hosts available while choosing host for machine '%s'", len(availableHosts), machine.Name)
 	if len(availableHosts) == 0 {
-		return nil, nil
+		return nil, rejections, nil
 	}
 
 	// choose a host at random from available hosts
 	rand.Seed(time.Now().Unix())
 	chosenHost := availableHosts[rand.Intn(len(availableHosts))]
 
-	return chosenHost, nil
+	return chosenHost, rejections, nil
 }
 
 // consumerRefMatches returns a boolean based on whether the consumer
@@ -548,6 +620,17 @@ func consumerRefMatches(consumer *corev1.ObjectReference, machine *machinev1beta
 	return true
 }
 
+// consumerRefForMachine returns the ConsumerRef a BareMetalHost claimed by
+// the Machine should have.
+func consumerRefForMachine(machine *machinev1beta1.Machine) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:       "Machine",
+		Name:       machine.Name,
+		Namespace:  machine.Namespace,
+		APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+	}
+}
+
 // provisionHost claims the BareMetalHost for this Machine and provisions it
 // according to the ProviderSpec.
 func (a *Actuator) provisionHost(ctx context.Context, host *bmh.BareMetalHost,
@@ -555,11 +638,57 @@ func (a *Actuator) provisionHost(ctx context.Context, host *bmh.BareMetalHost,
 	config *bmv1alpha1.BareMetalMachineProviderSpec) error {
 	originalHost := host.DeepCopy()
 
+	if config.AdoptExternallyProvisioned {
+		// The host is already running an OS that we did not provision, so
+		// only claim it.
+		host.Spec.ConsumerRef = consumerRefForMachine(machine)
+		if equality.Semantic.DeepEqual(originalHost, host) {
+			return nil
+		}
+		log.Printf("adopting externally provisioned host %v", host.Name)
+		if err := a.patchWithLock(ctx, host, originalHost); err != nil {
+			return gherrors.Wrap(err, "failed to adopt host")
+		}
+		return nil
+	}
+
+	setProvisioningSpec(host, machine, config)
+
+	host.Spec.ConsumerRef = consumerRefForMachine(machine)
+
+	host.Spec.Online = true
+
+	if equality.Semantic.DeepEqual(originalHost, host) {
+		return nil
+	}
+
+	log.Printf("updating host %v with deployment information", host.Name)
+	if err := a.patchWithLock(ctx, host, originalHost); err != nil {
+		return gherrors.Wrap(err, "failed to provision host")
+	}
+	return nil
+}
+
+// SetProvisioningSpec sets the image, custom deploy method and userData from
+// the ProviderSpec of the Machine on the BareMetalHost, as when the Machine
+// claimed it, so that it can be provisioned again.
+func SetProvisioningSpec(host *bmh.BareMetalHost, machine *machinev1beta1.Machine) error {
+	config, err := configFromProviderSpec(machine.Spec.ProviderSpec)
+	if err != nil {
+		return err
+	}
+	setProvisioningSpec(host, machine, config)
+	return nil
+}
+
+func setProvisioningSpec(host *bmh.BareMetalHost, machine *machinev1beta1.Machine,
+	config *bmv1alpha1.BareMetalMachineProviderSpec) {
 	// Set the host image if it is specified.
 	if config.Image.URL != "" && config.Image.Checksum != "" {
 		host.Spec.Image = &bmh.Image{
-			URL:      config.Image.URL,
-			Checksum: config.Image.Checksum,
+			URL:          config.Image.URL,
+			Checksum:     config.Image.Checksum,
+			ChecksumType: bmh.ChecksumType(config.Image.ChecksumType),
 		}
 	}
 
@@ -580,35 +709,15 @@ func (a *Actuator) provisionHost(ctx context.Context, host *bmh.BareMetalHost,
 			host.Spec.UserData.Namespace = machine.Namespace
 		}
 	}
-
-	host.Spec.ConsumerRef = &corev1.ObjectReference{
-		Kind:       "Machine",
-		Name:       machine.Name,
-		Namespace:  machine.Namespace,
-		APIVersion: machinev1beta1.SchemeGroupVersion.String(),
-	}
-
-	host.Spec.Online = true
-
-	if equality.Semantic.DeepEqual(originalHost, host) {
-		return nil
-	}
-
-	log.Printf("updating host %v with deployment information", host.Name)
-	if err := a.client.Update(ctx, host); err != nil {
-		return gherrors.Wrap(err, "failed to provision host")
-	}
-	return nil
 }
 
 // releaseHost removes the ConsumerRef and the actuator's finalizer from the
-// BareMetalHost.
-func (a *Actuator) releaseHost(ctx context.Context, host *bmh.BareMetalHost, machine *machinev1beta1.Machine) error {
-	dirty := false
+// BareMetalHost, along with any other changes made since original was copied
+// from it.
+func (a *Actuator) releaseHost(ctx context.Context, host *bmh.BareMetalHost, original *bmh.BareMetalHost, machine *machinev1beta1.Machine) error {
 	if consumerRefMatches(host.Spec.ConsumerRef, machine) {
 		log.Printf("clearing consumer reference for host %v", host.Name)
 		host.Spec.ConsumerRef = nil
-		dirty = true
 	} else {
 		if host.Spec.ConsumerRef != nil &&
 			host.Spec.ConsumerRef.Kind == "Machine" &&
@@ -618,22 +727,26 @@ func (a *Actuator) releaseHost(ctx context.Context, host *bmh.BareMetalHost, mac
 			return nil
 		}
 	}
+	if _, ok := host.Annotations[ReprovisioningAnnotation]; ok && host.Spec.ConsumerRef == nil {
+		delete(host.Annotations, ReprovisioningAnnotation)
+	}
+	if !equality.Semantic.DeepEqual(original, host) {
+		err := a.patch(ctx, host, original)
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return gherrors.Wrap(err, "failed to release host")
+		}
+	}
+
 	// We don't add a finalizer any more, but remove it if present in case it was
 	// added by a previous version of the actuator.
 	if slices.Contains(host.Finalizers, machinev1beta1.MachineFinalizer) {
 		log.Printf("clearing machine finalizer for host %v", host.Name)
-		host.Finalizers = slices.DeleteFunc(host.Finalizers, func(s string) bool {
-			return s == machinev1beta1.MachineFinalizer
-		})
-		dirty = true
-	}
-	if !dirty {
-		return nil
-	}
-
-	err := a.client.Update(ctx, host)
-	if err != nil && !errors.IsNotFound(err) {
-		return gherrors.Wrap(err, "failed to release host")
+		err := a.removeFinalizer(ctx, host, machinev1beta1.MachineFinalizer)
+		if err != nil && !errors.IsNotFound(err) {
+			return gherrors.Wrap(err, "failed to release host")
+		}
 	}
 	return nil
 }
@@ -642,6 +755,7 @@ func (a *Actuator) releaseHost(ctx context.Context, host *bmh.BareMetalHost, mac
 // host and uses the API to update the machine if necessary. Returns a RequeueAfterError
 // if the Machine is modified.
 func (a *Actuator) ensureAnnotation(ctx context.Context, machine *machinev1beta1.Machine, host *bmh.BareMetalHost) error {
+	original := machine.DeepCopy()
 	annotations := machine.ObjectMeta.GetAnnotations()
 	if annotations == nil {
 		annotations = make(map[string]string)
@@ -670,7 +784,7 @@ func (a *Actuator) ensureAnnotation(ctx context.Context, machine *machinev1beta1
 	}
 
 	machine.ObjectMeta.SetAnnotations(annotations)
-	if err := a.client.Update(ctx, machine); err != nil {
+	if err := a.patch(ctx, machine, original); err != nil {
 		return gherrors.Wrap(err, "failed to update machine annotation")
 	}
 
@@ -694,8 +808,9 @@ func (a *Actuator) ensureMachineProviderID(ctx context.Context, machine *machine
 	if existingProviderID == nil || *existingProviderID == "" {
 		providerID := providerIDForHost(host)
 		log.Printf("Setting ProviderID %s for machine %s.", providerID, machine.Name)
+		original := machine.DeepCopy()
 		machine.Spec.ProviderID = &providerID
-		err := a.client.Update(ctx, machine)
+		err := a.patch(ctx, machine, original)
 		if err != nil {
 			log.Printf("Failed to set machine ProviderID, error: %s", err.Error())
 			return gherrors.Wrap(err, "failed to set machine provider id")
@@ -725,8 +840,9 @@ func (a *Actuator) ensureNodeProviderID(ctx context.Context, machine *machinev1b
 
 	if node.Spec.ProviderID != providerID {
 		log.Printf("Setting ProviderID %s for node %s.", providerID, node.Name)
+		original := node.DeepCopy()
 		node.Spec.ProviderID = providerID
-		err = a.client.Update(ctx, node)
+		err = a.patch(ctx, node, original)
 		if err != nil {
 			log.Printf("Failed to update node ProviderID, error: %s", err.Error())
 			return gherrors.Wrap(err, "failed to set node provider id")
@@ -737,6 +853,57 @@ func (a *Actuator) ensureNodeProviderID(ctx context.Context, machine *machinev1b
 	return nil
 }
 
+// linkAdoptedNode sets the ProviderID on the Node that was already running on
+// an adopted BareMetalHost, so that the Machine controller can link it to the
+// Machine. Nodes are matched by their addresses and the host's hardware
+// details, and a Node that already has a ProviderID is left alone.
+func (a *Actuator) linkAdoptedNode(ctx context.Context, machine *machinev1beta1.Machine, host *bmh.BareMetalHost) error {
+	if machine.Status.NodeRef != nil || machine.Spec.ProviderID == nil {
+		return nil
+	}
+
+	hostAddrs, err := a.nodeAddresses(host)
+	if err != nil || len(hostAddrs) == 0 {
+		return err
+	}
+
+	nodes := corev1.NodeList{}
+	if err := a.client.List(ctx, &nodes); err != nil {
+		return gherrors.Wrap(err, "failed to list nodes")
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !addressesOverlap(node.Status.Addresses, hostAddrs) {
+			continue
+		}
+		if node.Spec.ProviderID != "" {
+			// Node provider IDs are immutable
+			return nil
+		}
+		log.Printf("Setting ProviderID %s for node %s of adopted host %s.",
+			*machine.Spec.ProviderID, node.Name, host.Name)
+		original := node.DeepCopy()
+		node.Spec.ProviderID = *machine.Spec.ProviderID
+		if err := a.patch(ctx, node, original); err != nil {
+			return gherrors.Wrap(err, "failed to set node provider id")
+		}
+		return &machineapierrors.RequeueAfterError{}
+	}
+	return nil
+}
+
+// addressesOverlap returns true if any address appears in both lists with
+// the same type.
+func addressesOverlap(addrs []corev1.NodeAddress, others []corev1.NodeAddress) bool {
+	for _, addr := range addrs {
+		if slices.Contains(others, addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // removeNodeFinalizer removes the finalizer from the Node
 // We don't add a finalizer any more, but a previous version of the actuator
 // may have left one behind.
@@ -752,10 +919,7 @@ func (a *Actuator) removeNodeFinalizer(ctx context.Context, machine *machinev1be
 	}
 
 	if slices.Contains(node.Finalizers, nodeFinalizer) {
-		node.Finalizers = slices.DeleteFunc(node.Finalizers, func(s string) bool {
-			return s == nodeFinalizer
-		})
-		if err := a.client.Update(ctx, node); err != nil {
+		if err := a.removeFinalizer(ctx, node, nodeFinalizer); err != nil {
 			log.Printf("Failed to remove Node finalizer from %s, error: %s", node.Name, err.Error())
 			return err
 		}
@@ -769,11 +933,108 @@ func (a *Actuator) removeNodeFinalizer(ctx context.Context, machine *machinev1be
 // the message. It assumes the reason is invalid configuration, since that is
 // currently the only relevant MachineStatusError choice.
 func (a *Actuator) setError(ctx context.Context, machine *machinev1beta1.Machine, message string) error {
+	original := machine.DeepCopy()
 	machine.Status.ErrorMessage = &message
 	reason := machinev1beta1.InvalidConfigurationMachineError
 	machine.Status.ErrorReason = &reason
 	log.Printf("Machine %s: %s", machine.Name, message)
-	return a.client.Status().Update(ctx, machine)
+	return a.patchStatus(ctx, machine, original)
+}
+
+// hostHoldReason returns a condition reason and message if the BareMetalHost
+// is detached or paused, in which case the actuator must not make changes to
+// it. The reason is empty if the host can be changed.
+func hostHoldReason(host *bmh.BareMetalHost) (reason string, message string) {
+	if _, detached := host.Annotations[bmh.DetachedAnnotation]; detached {
+		return hostDetachedReason, fmt.Sprintf("BareMetalHost %s/%s has the %s annotation; changes to the host are on hold until it is removed",
+			host.Namespace, host.Name, bmh.DetachedAnnotation)
+	}
+	if _, paused := host.Annotations[bmh.PausedAnnotation]; paused {
+		return hostPausedReason, fmt.Sprintf("BareMetalHost %s/%s has the %s annotation; changes to the host are on hold until it is removed",
+			host.Namespace, host.Name, bmh.PausedAnnotation)
+	}
+	return "", ""
+}
+
+// updateHostManagedCondition records on the Machine whether changes to its
+// BareMetalHost are on hold. The condition is only added once the host has
+// been seen on hold, and it is set back to True when the hold is lifted.
+func (a *Actuator) updateHostManagedCondition(ctx context.Context, machine *machinev1beta1.Machine, host *bmh.BareMetalHost) error {
+	existing := conditions.Get(machine, hostManagedCondition)
+
+	var desired *machinev1beta1.Condition
+	if reason, message := hostHoldReason(host); reason != "" {
+		desired = conditions.FalseCondition(hostManagedCondition, reason, machinev1beta1.ConditionSeverityWarning, "%s", message)
+	} else if existing != nil {
+		desired = conditions.TrueCondition(hostManagedCondition)
+	} else {
+		return nil
+	}
+
+	if conditions.IsEquivalentTo(existing, desired) {
+		return nil
+	}
+
+	log.Printf("Setting %s condition of machine %s to %s %s", hostManagedCondition, machine.Name, desired.Status, desired.Reason)
+	updated := machine.DeepCopy()
+	conditions.Set(updated, desired)
+	if err := a.setCondition(ctx, machine, *conditions.Get(updated, hostManagedCondition)); err != nil {
+		return gherrors.Wrap(err, "failed to update machine conditions")
+	}
+	return nil
+}
+
+// setInsufficientResourcesError sets an InsufficientResources error with the
+// given message in the machine's Status, unless one is already set. Always
+// returns a RequeueAfterError unless updating the machine fails.
+func (a *Actuator) setInsufficientResourcesError(ctx context.Context, machine *machinev1beta1.Machine, msg string) error {
+	errorReason := machinev1beta1.InsufficientResourcesMachineError
+	log.Printf("%s", msg)
+	if machine.Status.ErrorReason == nil || *machine.Status.ErrorReason != errorReason {
+		original := machine.DeepCopy()
+		machine.Status.ErrorReason = &errorReason
+		machine.Status.ErrorMessage = &msg
+		if err := a.patchStatus(ctx, machine, original); err != nil {
+			return gherrors.Wrap(err, "failed to set insufficient resources error")
+		}
+	}
+	return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+}
+
+// setNoHostAvailableError sets an InsufficientResources error on the Machine
+// that summarizes why each BareMetalHost could not be claimed, records the
+// counts in the provider status, and emits an event when the summary
+// changes. Returns a RequeueAfterError.
+func (a *Actuator) setNoHostAvailableError(ctx context.Context, machine *machinev1beta1.Machine, rejections *RejectionSummary) error {
+	msg := "No available BareMetalHost found"
+	if summary := rejections.String(); summary != "" {
+		msg = fmt.Sprintf("%s: %s", msg, summary)
+	}
+	log.Printf("%s for machine %s", msg, machine.Name)
+
+	providerStatus, err := providerStatusFromMachine(machine)
+	if err != nil {
+		return err
+	}
+	errorReason := machinev1beta1.InsufficientResourcesMachineError
+	if machine.Status.ErrorReason != nil && *machine.Status.ErrorReason == errorReason &&
+		machine.Status.ErrorMessage != nil && *machine.Status.ErrorMessage == msg &&
+		equality.Semantic.DeepEqual(providerStatus.HostRejections, rejections.Counts()) {
+		return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+	}
+
+	original := machine.DeepCopy()
+	providerStatus.HostRejections = rejections.Counts()
+	if err := setProviderStatus(machine, providerStatus); err != nil {
+		return err
+	}
+	machine.Status.ErrorReason = &errorReason
+	machine.Status.ErrorMessage = &msg
+	if err := a.patchStatus(ctx, machine, original); err != nil {
+		return gherrors.Wrap(err, "failed to set insufficient resources error")
+	}
+	a.recordEvent(machine, corev1.EventTypeWarning, "NoHostAvailable", msg)
+	return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
 }
 
 // clearInsufficientResourcesError removes the ErrorMessage from the machine's
@@ -781,12 +1042,19 @@ func (a *Actuator) setError(ctx context.Context, machine *machinev1beta1.Machine
 // was already nil. Returns a RequeueAfterError if the machine was updated.
 func (a *Actuator) clearInsufficientResourcesError(ctx context.Context, machine *machinev1beta1.Machine) error {
 	if machine.Status.ErrorReason != nil && *machine.Status.ErrorReason == machinev1beta1.InsufficientResourcesMachineError {
+		original := machine.DeepCopy()
 		now := metav1.Now()
 		machine.Status.LastUpdated = &now // Restart the clock for MachineHealthCheck
 		machine.Status.ErrorMessage = nil
 		machine.Status.ErrorReason = nil
+		if providerStatus, err := providerStatusFromMachine(machine); err == nil && providerStatus.HostRejections != nil {
+			providerStatus.HostRejections = nil
+			if err := setProviderStatus(machine, providerStatus); err != nil {
+				return err
+			}
+		}
 		log.Printf("Clearing insufficient resources error from machine %s", machine.Name)
-		err := a.client.Status().Update(ctx, machine)
+		err := a.patchStatus(ctx, machine, original)
 		if err != nil {
 			return gherrors.Wrap(err, "failed to clear machine error")
 		}
@@ -795,14 +1063,52 @@ func (a *Actuator) clearInsufficientResourcesError(ctx context.Context, machine
 	return nil
 }
 
+// IsBareMetalProviderSpec returns false if the apiVersion or kind of the
+// ProviderSpec show that it belongs to another provider. Like the actuator,
+// it assumes a ProviderSpec without them is a BareMetalMachineProviderSpec.
+func IsBareMetalProviderSpec(providerSpec *machinev1beta1.ProviderSpec) bool {
+	if providerSpec.Value == nil {
+		return false
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(providerSpec.Value.Raw, &typeMeta); err != nil {
+		return false
+	}
+	if typeMeta.Kind != "" && typeMeta.Kind != "BareMetalMachineProviderSpec" {
+		return false
+	}
+	if typeMeta.APIVersion == "" {
+		return true
+	}
+	gv, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	return err == nil && gv.Group == bmv1alpha1.SchemeGroupVersion.Group
+}
+
 // configFromProviderSpec returns a BareMetalMachineProviderSpec by
-// deserializing the contents of a ProviderSpec
+// deserializing the contents of a ProviderSpec. A v1beta1 ProviderSpec is
+// converted to v1alpha1; any other apiVersion is read as v1alpha1.
 func configFromProviderSpec(providerSpec machinev1beta1.ProviderSpec) (*bmv1alpha1.BareMetalMachineProviderSpec, error) {
 	if providerSpec.Value == nil {
 		return nil, fmt.Errorf("ProviderSpec missing")
 	}
 
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(providerSpec.Value.Raw, &typeMeta); err != nil {
+		return nil, err
+	}
+
 	var config bmv1alpha1.BareMetalMachineProviderSpec
+	if typeMeta.APIVersion == bmv1beta1.SchemeGroupVersion.String() {
+		var spec bmv1beta1.BareMetalMachineProviderSpec
+		if err := yaml.UnmarshalStrict(providerSpec.Value.Raw, &spec); err != nil {
+			return nil, err
+		}
+		if err := spec.ConvertTo(&config); err != nil {
+			return nil, err
+		}
+		return &config, nil
+	}
+
 	err := yaml.UnmarshalStrict(providerSpec.Value.Raw, &config)
 	if err != nil {
 		return nil, err
@@ -811,6 +1117,89 @@ func configFromProviderSpec(providerSpec machinev1beta1.ProviderSpec) (*bmv1alph
 	return &config, nil
 }
 
+// providerStatusFromMachine returns the BareMetalMachineProviderStatus of the
+// Machine, or an empty one if the Machine has none.
+func providerStatusFromMachine(machine *machinev1beta1.Machine) (*bmv1alpha1.BareMetalMachineProviderStatus, error) {
+	status := &bmv1alpha1.BareMetalMachineProviderStatus{}
+	if machine.Status.ProviderStatus == nil || len(machine.Status.ProviderStatus.Raw) == 0 {
+		return status, nil
+	}
+	if err := yaml.Unmarshal(machine.Status.ProviderStatus.Raw, status); err != nil {
+		return nil, gherrors.Wrap(err, "failed to read ProviderStatus")
+	}
+	return status, nil
+}
+
+// setProviderStatus stores the BareMetalMachineProviderStatus in the
+// Machine's Status.
+func setProviderStatus(machine *machinev1beta1.Machine, status *bmv1alpha1.BareMetalMachineProviderStatus) error {
+	status.APIVersion = bmv1alpha1.SchemeGroupVersion.String()
+	status.Kind = "BareMetalMachineProviderStatus"
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return gherrors.Wrap(err, "failed to marshal ProviderStatus")
+	}
+	machine.Status.ProviderStatus = &runtime.RawExtension{Raw: raw}
+	return nil
+}
+
+// recordEvent emits an event for the Machine, if the Actuator was given an
+// EventRecorder.
+func (a *Actuator) recordEvent(machine *machinev1beta1.Machine, eventType, reason, message string) {
+	if a.eventRecorder == nil {
+		return
+	}
+	a.eventRecorder.Event(machine, eventType, reason, message)
+}
+
+// machineConfig returns the BareMetalMachineProviderSpec of the Machine, or
+// nil if it is missing or cannot be read.
+func machineConfig(machine *machinev1beta1.Machine) *bmv1alpha1.BareMetalMachineProviderSpec {
+	if machine.Spec.ProviderSpec.Value == nil {
+		return nil
+	}
+	config, err := configFromProviderSpec(machine.Spec.ProviderSpec)
+	if err != nil {
+		log.Printf("Error reading ProviderSpec for machine %s: %s", machine.Name, err.Error())
+		return nil
+	}
+	return config
+}
+
+// adoptsExternallyProvisionedHost returns true if the Machine claims an
+// externally provisioned BareMetalHost instead of provisioning one.
+func adoptsExternallyProvisionedHost(machine *machinev1beta1.Machine, config *bmv1alpha1.BareMetalMachineProviderSpec) bool {
+	if _, ok := machine.Annotations[AdoptHostAnnotation]; ok {
+		return true
+	}
+	return config != nil && config.AdoptExternallyProvisioned
+}
+
+// deletionPolicy returns the DeletionPolicy to apply when deleting the
+// Machine. The DeletionPolicyAnnotation takes precedence over the
+// ProviderSpec. Anything that cannot be read falls back to Deprovision,
+// except for adopted hosts, which are never deprovisioned.
+func deletionPolicy(machine *machinev1beta1.Machine) bmv1alpha1.DeletionPolicy {
+	config := machineConfig(machine)
+
+	policy := bmv1alpha1.DeletionPolicyDeprovision
+	if config != nil && config.DeletionPolicy != "" && config.DeletionPolicy.IsValid() == nil {
+		policy = config.DeletionPolicy
+	}
+	if value, ok := machine.Annotations[DeletionPolicyAnnotation]; ok {
+		if annotationPolicy := bmv1alpha1.DeletionPolicy(value); annotationPolicy != "" && annotationPolicy.IsValid() == nil {
+			policy = annotationPolicy
+		} else {
+			log.Printf("Ignoring invalid %s annotation %q on machine %s", DeletionPolicyAnnotation, value, machine.Name)
+		}
+	}
+
+	if policy == bmv1alpha1.DeletionPolicyDeprovision && adoptsExternallyProvisionedHost(machine, config) {
+		policy = bmv1alpha1.DeletionPolicyDetach
+	}
+	return policy
+}
+
 // clearMachineAddresses clears the Host IP addresses in the Machine status, so
 // that another Host using the same IP can later be used by another Machine.
 func (a *Actuator) clearMachineAddresses(ctx context.Context, machine *machinev1beta1.Machine) error {
@@ -854,7 +1243,7 @@ func (a *Actuator) applyMachineStatus(ctx context.Context, machine *machinev1bet
 	machineCopy.Status.LastUpdated = &now
 
 	log.Printf("Updating addresses for machine %s", machine.Name)
-	if err := a.client.Status().Update(ctx, machineCopy); err != nil {
+	if err := a.patchStatus(ctx, machineCopy, machine); err != nil {
 		return gherrors.Wrap(err, "failed to update machine status")
 	}
 	return &machineapierrors.RequeueAfterError{}
@@ -898,11 +1287,15 @@ func (a *Actuator) deleteRemediationAnnotations(ctx context.Context, machine *ma
 		return nil
 	}
 
+	original := machine.DeepCopy()
 	delete(machine.Annotations, poweredOffForRemediation)
 	delete(machine.Annotations, externalRemediationAnnotation)
 	delete(machine.Annotations, powerOnWillTimeoutAtAnnotation)
+	delete(machine.Annotations, PowerOffModeAnnotation)
+	delete(machine.Annotations, softPowerOffWillTimeoutAtAnnotation)
+	delete(machine.Annotations, RemediationPendingAnnotation)
 
-	if err := a.client.Update(ctx, machine); err != nil {
+	if err := a.patch(ctx, machine, original); err != nil {
 		log.Printf("Failed to delete annotations of Machine: %s", machine.Name)
 		return err
 	}
@@ -920,13 +1313,14 @@ func hasPowerOffRequestAnnotation(baremetalhost *bmh.BareMetalHost) (exists bool
 
 // addPoweredOffForRemediationAnnotation adds a powered-off-for-remediation annotation to the machine
 func (a *Actuator) addPoweredOffForRemediationAnnotation(ctx context.Context, machine *machinev1beta1.Machine) error {
+	original := machine.DeepCopy()
 	if machine.Annotations == nil {
 		machine.Annotations = make(map[string]string)
 	}
 
 	machine.Annotations[poweredOffForRemediation] = ""
 
-	err := a.client.Update(ctx, machine)
+	err := a.patch(ctx, machine, original)
 	if err != nil {
 		log.Printf("Failed to add remediation in progess annotation to %s: %s", machine.Name, err.Error())
 	}
@@ -934,8 +1328,10 @@ func (a *Actuator) addPoweredOffForRemediationAnnotation(ctx context.Context, ma
 	return err
 }
 
-// requestPowerOff adds requestPowerOffAnnotation on baremetalhost which signals BMO to power off the machine
-func (a *Actuator) requestPowerOff(ctx context.Context, baremetalhost *bmh.BareMetalHost) error {
+// requestPowerOff adds requestPowerOffAnnotation on baremetalhost which signals BMO to power off the machine.
+// The power off is soft if the MHC of the machine sets a soft power off timeout, and hard otherwise.
+func (a *Actuator) requestPowerOff(ctx context.Context, machine *machinev1beta1.Machine, baremetalhost *bmh.BareMetalHost) error {
+	original := baremetalhost.DeepCopy()
 	if baremetalhost.Annotations == nil {
 		baremetalhost.Annotations = make(map[string]string)
 	}
@@ -944,14 +1340,25 @@ func (a *Actuator) requestPowerOff(ctx context.Context, baremetalhost *bmh.BareM
 		return &machineapierrors.RequeueAfterError{RequeueAfter: time.Second * 5}
 	}
 
-	// Issue a hard reboot for immediate remediation purposes
-	remediationRebootAnnotation := bmh.RebootAnnotationArguments{Mode: bmh.RebootModeHard}
-	remediationString, err := json.Marshal(remediationRebootAnnotation)
-	if err == nil {
-		baremetalhost.Annotations[requestPowerOffAnnotation] = string(remediationString[:])
+	// Issue a hard reboot for immediate remediation purposes, unless a soft one is allowed first
+	mode := bmh.RebootModeHard
+	originalMachine := machine.DeepCopy()
+	if machine.Annotations == nil {
+		machine.Annotations = make(map[string]string)
+	}
+	if timeout := a.softPowerOffTimeoutForMachine(machine); timeout != nil {
+		mode = bmh.RebootModeSoft
+		machine.Annotations[softPowerOffWillTimeoutAtAnnotation] = time.Now().Add(timeout.Duration).Format(annotationTimestampFormat)
 	}
+	machine.Annotations[PowerOffModeAnnotation] = string(mode)
+	delete(machine.Annotations, RemediationPendingAnnotation)
+	if err := a.patch(ctx, machine, originalMachine); err != nil {
+		return gherrors.Wrapf(err, "failed to record power off mode on %s", machine.Name)
+	}
+
+	baremetalhost.Annotations[requestPowerOffAnnotation] = PowerOffRequest(mode)
 
-	err = a.client.Update(ctx, baremetalhost)
+	err := a.patch(ctx, baremetalhost, original)
 	if err != nil {
 		log.Printf("failed to add power off request annotation to %s: %s", baremetalhost.Name, err.Error())
 	}
@@ -961,6 +1368,7 @@ func (a *Actuator) requestPowerOff(ctx context.Context, baremetalhost *bmh.BareM
 
 // requestPowerOn removes requestPowerOffAnnotation from baremetalhost which signals BMO to power on the machine
 func (a *Actuator) requestPowerOn(ctx context.Context, machine *machinev1beta1.Machine, baremetalhost *bmh.BareMetalHost) error {
+	originalMachine := machine.DeepCopy()
 	if machine.Annotations == nil {
 		machine.Annotations = make(map[string]string)
 	}
@@ -971,17 +1379,14 @@ func (a *Actuator) requestPowerOn(ctx context.Context, machine *machinev1beta1.M
 	}
 	machine.Annotations[powerOnWillTimeoutAtAnnotation] = time.Now().Add(timeout).Format(annotationTimestampFormat)
 
-	if err := a.client.Update(ctx, machine); err != nil {
+	if err := a.patch(ctx, machine, originalMachine); err != nil {
 		return gherrors.Wrapf(err, "failed to add remediation power on timestamp annotation to %s", machine.Name)
 	}
 
-	if baremetalhost.Annotations == nil {
-		baremetalhost.Annotations = make(map[string]string)
-	}
-
+	originalHost := baremetalhost.DeepCopy()
 	delete(baremetalhost.Annotations, requestPowerOffAnnotation)
 
-	if err := a.client.Update(ctx, baremetalhost); err != nil {
+	if err := a.patch(ctx, baremetalhost, originalHost); err != nil {
 		log.Printf("failed to power-off request annotation from %s: %s", baremetalhost.Name, err.Error())
 		return err
 	}
@@ -1111,6 +1516,11 @@ func (a *Actuator) remediateIfNeeded(ctx context.Context, machine *machinev1beta
 		return nil
 	}
 
+	if reason, _ := hostHoldReason(baremetalhost); reason != "" {
+		log.Printf("Not remediating Machine %s while Host %s is on hold (%s)", machine.Name, baremetalhost.Name, reason)
+		return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+	}
+
 	node, err := a.getNodeByMachine(ctx, machine)
 
 	if err != nil {
@@ -1122,12 +1532,24 @@ func (a *Actuator) remediateIfNeeded(ctx context.Context, machine *machinev1beta
 
 	if _, poweredOffForRemediation := machine.Annotations[poweredOffForRemediation]; !poweredOffForRemediation {
 		if !hasPowerOffRequestAnnotation(baremetalhost) {
+			if err := a.admitRemediation(ctx, machine, baremetalhost); err != nil {
+				return err
+			}
 			log.Printf("Found an unhealthy machine, requesting power off. Machine name: %s", machine.Name)
-			return a.requestPowerOff(ctx, baremetalhost)
+			return a.requestPowerOff(ctx, machine, baremetalhost)
 		}
 
 		//hold remediation until the power off request is fulfilled
 		if baremetalhost.Status.PoweredOn {
+			// escalate a soft power off to a hard one once it timed out
+			if remaining, soft := softPowerOffRemaining(machine); soft {
+				if remaining > 0 {
+					return &machineapierrors.RequeueAfterError{RequeueAfter: remaining}
+				}
+				log.Printf("Soft power off of Host %s timed out, requesting hard power off for Machine %s",
+					baremetalhost.Name, machine.Name)
+				return a.forcePowerOff(ctx, machine, baremetalhost)
+			}
 			return nil
 		}
 
@@ -1198,10 +1620,11 @@ func (a *Actuator) storeAnnotationsAndLabels(ctx context.Context, node *corev1.N
 
 	if machine.Annotations[nodeAnnotationsBackupAnnotation] != marshaledAnnotations ||
 		machine.Annotations[nodeLabelsBackupAnnotation] != marshaledLabels {
+		original := machine.DeepCopy()
 		machine.Annotations[nodeAnnotationsBackupAnnotation] = marshaledAnnotations
 		machine.Annotations[nodeLabelsBackupAnnotation] = marshaledLabels
 
-		err = a.client.Update(ctx, machine)
+		err = a.patch(ctx, machine, original)
 		if err != nil {
 			log.Printf("Failed to update machine with node's annotations and labels %s: %s", machine.Name, err.Error())
 			return err
@@ -1230,19 +1653,21 @@ func (a *Actuator) restoreAnnotationsAndLabels(ctx context.Context, node *corev1
 	}
 
 	if len(nodeLabels) > 0 || len(nodeAnn) > 0 {
+		originalNode := node.DeepCopy()
 		node.Annotations = a.mergeMaps(node.Annotations, nodeAnn)
 		node.Labels = a.mergeMaps(node.Labels, nodeLabels)
 
-		if err := a.client.Update(ctx, node); err != nil {
+		if err := a.patch(ctx, node, originalNode); err != nil {
 			log.Printf("failed to update machine with node's annotations %s: %s", machine.Name, err.Error())
 			return err
 		}
 	}
 
+	originalMachine := machine.DeepCopy()
 	delete(machine.Annotations, nodeAnnotationsBackupAnnotation)
 	delete(machine.Annotations, nodeLabelsBackupAnnotation)
 
-	err = a.client.Update(ctx, machine)
+	err = a.patch(ctx, machine, originalMachine)
 	if err != nil {
 		log.Printf("failed to remove node %s annotations backup from machine %s: %s",
 			node.Name, machine.Name, err.Error())
@@ -15,14 +15,19 @@ import (
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
 	machineapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	gherrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
@@ -39,6 +44,7 @@ const (
 func TestChooseHost(t *testing.T) {
 	scheme := runtime.NewScheme()
 	bmoapis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
 
 	host1 := bmh.BareMetalHost{
 		ObjectMeta: metav1.ObjectMeta{
@@ -159,6 +165,19 @@ func TestChooseHost(t *testing.T) {
 		},
 	}
 
+	retainedHost := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "retained-host",
+			Namespace:   "myns",
+			Annotations: map[string]string{RetainedHostAnnotation: "myns/oldmachine"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{
+				State: bmh.StateReady,
+			},
+		},
+	}
+
 	config, providerSpec := newConfig(t, "", map[string]string{}, []bmv1alpha1.HostSelectorRequirement{})
 	config2, providerSpec2 := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
 	config3, providerSpec3 := newConfig(t, "", map[string]string{"boguskey": "value"}, []bmv1alpha1.HostSelectorRequirement{})
@@ -178,6 +197,19 @@ func TestChooseHost(t *testing.T) {
 				Values:   []string{"abc", "value1", "123"},
 			},
 		})
+	adoptConfig := &bmv1alpha1.BareMetalMachineProviderSpec{AdoptExternallyProvisioned: true}
+	hostNamespacesConfig := &bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			HostNamespaces: []string{"someotherns"},
+		},
+	}
+	namespace := func(name, allowed string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if allowed != "" {
+			ns.Annotations = map[string]string{AllowedConsumerNamespacesAnnotation: allowed}
+		}
+		return ns
+	}
 
 	testCases := []struct {
 		Scenario         string
@@ -412,6 +444,122 @@ func TestChooseHost(t *testing.T) {
 			ExpectedHostName: "externally-provisioned-and-consumed-host",
 			Config:           config,
 		},
+		{
+			Scenario: "No host chosen given only a retained host",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine1",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: providerSpec,
+				},
+			},
+			Hosts:            []runtime.Object{&retainedHost},
+			ExpectedHostName: "",
+			Config:           config,
+		},
+		{
+			Scenario: "Adopt the externally provisioned host",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine2",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host2, &externallyProvisionedHost},
+			ExpectedHostName: externallyProvisionedHost.Name,
+			Config:           adoptConfig,
+		},
+		{
+			Scenario: "Adopt the externally provisioned host when requested by annotation",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machine2",
+					Namespace:   "myns",
+					Annotations: map[string]string{AdoptHostAnnotation: ""},
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host2, &externallyProvisionedHost},
+			ExpectedHostName: externallyProvisionedHost.Name,
+			Config:           config,
+		},
+		{
+			Scenario: "No host adopted given only hosts that are not externally provisioned",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine2",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host2, &hostWithLabel},
+			ExpectedHostName: "",
+			Config:           adoptConfig,
+		},
+		{
+			Scenario: "should pick host4 from a host namespace that allows myns",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine2",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host2, &host4, namespace("someotherns", "otherns, myns")},
+			ExpectedHostName: host4.Name,
+			Config:           hostNamespacesConfig,
+		},
+		{
+			Scenario: "should pick host4 from a host namespace that allows any namespace",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine2",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host4, namespace("someotherns", "*")},
+			ExpectedHostName: host4.Name,
+			Config:           hostNamespacesConfig,
+		},
+		{
+			Scenario: "No host chosen from a host namespace that does not allow myns",
+			Machine: machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine2",
+					Namespace: "myns",
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+			},
+			Hosts:            []runtime.Object{&host2, &host4, namespace("someotherns", "otherns")},
+			ExpectedHostName: "",
+			Config:           hostNamespacesConfig,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -430,7 +578,7 @@ func TestChooseHost(t *testing.T) {
 				t.FailNow()
 			}
 			tc.Machine.Spec.ProviderSpec = machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: pspec}}
-			result, err := actuator.chooseHost(context.TODO(), &tc.Machine)
+			result, _, err := actuator.chooseHost(context.TODO(), &tc.Machine)
 			if tc.ExpectedHostName == "" {
 				if result != nil {
 					t.Error("found host when none should have been available")
@@ -450,6 +598,270 @@ func TestChooseHost(t *testing.T) {
 	}
 }
 
+func TestHostFilterReject(t *testing.T) {
+	now := metav1.Now()
+	_, providerSpec := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
+
+	for _, tc := range []struct {
+		Scenario         string
+		Adopt            bool
+		Host             bmh.BareMetalHost
+		ExpectedReason   HostRejection
+		ExpectedContains string
+	}{
+		{
+			Scenario: "available",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+		},
+		{
+			Scenario: "consumed",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{Kind: "Machine", Namespace: "myns", Name: "other"},
+				},
+				Status: bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateProvisioned}},
+			},
+			ExpectedReason:   HostConsumed,
+			ExpectedContains: "Machine myns/other",
+		},
+		{
+			Scenario: "deleting",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:            map[string]string{"key1": "value1"},
+					DeletionTimestamp: &now,
+				},
+				Status: bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+			ExpectedReason: HostDeleting,
+		},
+		{
+			Scenario: "retained",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"key1": "value1"},
+					Annotations: map[string]string{RetainedHostAnnotation: "myns/oldmachine"},
+				},
+				Status: bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+			ExpectedReason:   HostRetained,
+			ExpectedContains: "myns/oldmachine",
+		},
+		{
+			Scenario: "inspecting",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateInspecting}},
+			},
+			ExpectedReason:   HostWrongState,
+			ExpectedContains: "inspecting",
+		},
+		{
+			Scenario: "error",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Status: bmh.BareMetalHostStatus{
+					ErrorMessage: "BMC unreachable",
+					Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+				},
+			},
+			ExpectedReason:   HostHasError,
+			ExpectedContains: "BMC unreachable",
+		},
+		{
+			Scenario: "externally provisioned",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Spec:       bmh.BareMetalHostSpec{ExternallyProvisioned: true},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+			ExpectedReason: HostExternallyProvisioned,
+		},
+		{
+			Scenario: "not externally provisioned when adopting",
+			Adopt:    true,
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+			ExpectedReason: HostNotExternallyProvisioned,
+		},
+		{
+			Scenario: "adopting",
+			Adopt:    true,
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+				Spec:       bmh.BareMetalHostSpec{ExternallyProvisioned: true},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateExternallyProvisioned}},
+			},
+		},
+		{
+			Scenario: "selector mismatch",
+			Host: bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value2"}},
+				Status:     bmh.BareMetalHostStatus{Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable}},
+			},
+			ExpectedReason: HostSelectorMismatch,
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			machine, _ := getMachine("machine1")
+			machine.Spec.ProviderSpec = providerSpec
+			if tc.Adopt {
+				machine.Annotations = map[string]string{AdoptHostAnnotation: ""}
+			}
+
+			filter, err := NewHostFilter(machine)
+			if !assert.NoError(t, err) {
+				return
+			}
+			reason, detail := filter.Reject(&tc.Host)
+			assert.Equal(t, tc.ExpectedReason, reason)
+			assert.Contains(t, detail, tc.ExpectedContains)
+		})
+	}
+}
+
+func TestMachineSetHostFilter(t *testing.T) {
+	_, providerSpec := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset1", Namespace: "myns"},
+	}
+	ms.Spec.Template.Spec.ProviderSpec = providerSpec
+
+	for _, tc := range []struct {
+		State           bmh.ProvisioningState
+		ErrorMessage    string
+		ExpectedReason  HostRejection
+		ExpectedPending HostRejection
+	}{
+		{State: bmh.StateAvailable},
+		{State: bmh.StateNone, ExpectedReason: HostWrongState},
+		{State: bmh.StateRegistering, ExpectedReason: HostWrongState},
+		{State: bmh.StateInspecting, ExpectedReason: HostWrongState},
+		{State: bmh.StatePreparing, ExpectedReason: HostWrongState},
+		{State: bmh.StateUnmanaged, ExpectedReason: HostWrongState, ExpectedPending: HostWrongState},
+		{State: bmh.StateProvisioned, ExpectedReason: HostWrongState, ExpectedPending: HostWrongState},
+		{
+			State:           bmh.StateRegistering,
+			ErrorMessage:    "BMC unreachable",
+			ExpectedReason:  HostWrongState,
+			ExpectedPending: HostHasError,
+		},
+	} {
+		host := bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"key1": "value1"}},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: tc.State},
+				ErrorMessage: tc.ErrorMessage,
+			},
+		}
+
+		filter, err := NewMachineSetHostFilter(ms, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+		reason, _ := filter.Reject(&host)
+		assert.Equal(t, tc.ExpectedReason, reason, "state %q", tc.State)
+
+		filter, err = NewMachineSetHostFilter(ms, true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		reason, _ = filter.Reject(&host)
+		assert.Equal(t, tc.ExpectedPending, reason, "state %q with pending hosts", tc.State)
+	}
+}
+
+func TestHostFilterCELSelector(t *testing.T) {
+	config, _ := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
+	config.HostSelector.CELSelector = "host.status.hardware.cpu.count >= 64"
+	raw, err := json.Marshal(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tc := range []struct {
+		Scenario         string
+		Labels           map[string]string
+		Hardware         *bmh.HardwareDetails
+		ExpectedReason   HostRejection
+		ExpectedContains string
+	}{
+		{
+			Scenario: "matching host",
+			Labels:   map[string]string{"key1": "value1"},
+			Hardware: &bmh.HardwareDetails{CPU: bmh.CPU{Count: 96}},
+		},
+		{
+			Scenario:         "labels do not match",
+			Labels:           map[string]string{"key1": "value2"},
+			Hardware:         &bmh.HardwareDetails{CPU: bmh.CPU{Count: 96}},
+			ExpectedReason:   HostSelectorMismatch,
+			ExpectedContains: "labels do not match hostSelector",
+		},
+		{
+			Scenario:         "expression is false",
+			Labels:           map[string]string{"key1": "value1"},
+			Hardware:         &bmh.HardwareDetails{CPU: bmh.CPU{Count: 8}},
+			ExpectedReason:   HostSelectorMismatch,
+			ExpectedContains: "does not match celSelector",
+		},
+		{
+			Scenario:         "expression fails",
+			Labels:           map[string]string{"key1": "value1"},
+			ExpectedReason:   HostSelectorMismatch,
+			ExpectedContains: "celSelector failed: no such key: hardware",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			machine, _ := getMachine("machine1")
+			machine.Spec.ProviderSpec = machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: raw}}
+			filter, err := NewHostFilter(machine)
+			if !assert.NoError(t, err) {
+				return
+			}
+			host := bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Labels: tc.Labels},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning:    bmh.ProvisionStatus{State: bmh.StateAvailable},
+					HardwareDetails: tc.Hardware,
+				},
+			}
+			reason, detail := filter.Reject(&host)
+			assert.Equal(t, tc.ExpectedReason, reason)
+			assert.Contains(t, detail, tc.ExpectedContains)
+		})
+	}
+
+	config.HostSelector.CELSelector = "host.spec.online &&"
+	raw, err = json.Marshal(config)
+	if assert.NoError(t, err) {
+		machine, _ := getMachine("machine1")
+		machine.Spec.ProviderSpec = machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: raw}}
+		_, err = NewHostFilter(machine)
+		assert.ErrorContains(t, err, "invalid celSelector")
+
+		// Create reports it as an invalid configuration
+		scheme := runtime.NewScheme()
+		machinev1beta1.AddToScheme(scheme)
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(machine).
+			WithRuntimeObjects(machine).Build()
+		actuator, err := NewActuator(ActuatorParams{Client: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, actuator.Create(context.TODO(), machine))
+		if assert.NotNil(t, machine.Status.ErrorMessage) {
+			assert.Contains(t, *machine.Status.ErrorMessage, `hostSelector.celSelector: Invalid value: "host.spec.online &&"`)
+		}
+	}
+}
+
 func TestProvisionHost(t *testing.T) {
 	for _, tc := range []struct {
 		Scenario                  string
@@ -637,6 +1049,117 @@ func TestProvisionHost(t *testing.T) {
 	}
 }
 
+func TestProvisionHostAdopt(t *testing.T) {
+	host := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host1",
+			Namespace: "myns",
+		},
+		Spec: bmh.BareMetalHostSpec{
+			ExternallyProvisioned: true,
+			Image: &bmh.Image{
+				URL:      testImageURL + "installer",
+				Checksum: testImageChecksumURL + "installer",
+			},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{
+				State: bmh.StateExternallyProvisioned,
+			},
+		},
+	}
+	config, providerSpec := newConfig(t, "", map[string]string{}, []bmv1alpha1.HostSelectorRequirement{})
+	config.AdoptExternallyProvisioned = true
+	machine := machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine1",
+			Namespace: "myns",
+		},
+		Spec: machinev1beta1.MachineSpec{
+			ProviderSpec: providerSpec,
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(&host).Build()
+
+	actuator, err := NewActuator(ActuatorParams{
+		Client: c,
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	err = actuator.provisionHost(context.TODO(), &host, &machine, config)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	savedHost := bmh.BareMetalHost{}
+	err = c.Get(context.TODO(), client.ObjectKey{Name: host.Name, Namespace: host.Namespace}, &savedHost)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !consumerRefMatches(savedHost.Spec.ConsumerRef, &machine) {
+		t.Errorf("found consumer ref %v", savedHost.Spec.ConsumerRef)
+	}
+	if savedHost.Spec.Image == nil || savedHost.Spec.Image.URL != testImageURL+"installer" {
+		t.Errorf("image of adopted host changed to %v", savedHost.Spec.Image)
+	}
+	if savedHost.Spec.UserData != nil {
+		t.Errorf("did not expect user data, got %v", savedHost.Spec.UserData)
+	}
+	if savedHost.Spec.Online {
+		t.Errorf("power state of adopted host changed")
+	}
+}
+
+func TestLinkAdoptedNode(t *testing.T) {
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+
+	host, _ := getBareMetalHost("host1")
+	host.Status.HardwareDetails = &bmh.HardwareDetails{
+		NIC: []bmh.NIC{{IP: "192.168.1.1"}},
+	}
+	providerID := providerIDForHost(host)
+
+	otherNode, otherNodeName := getNode("node0")
+	otherNode.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.1.2"},
+	}
+	node, nodeName := getNode("node1")
+	node.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.1.1"},
+	}
+
+	machine, _ := getMachine("machine1")
+	machine.Spec.ProviderID = &providerID
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(otherNode, node).Build()
+	actuator, err := NewActuator(ActuatorParams{
+		Client: c,
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	err = actuator.linkAdoptedNode(context.TODO(), machine, host)
+	expectRequeueAfterError(err, t)
+
+	savedNode := corev1.Node{}
+	c.Get(context.TODO(), nodeName, &savedNode)
+	assert.Equal(t, providerID, savedNode.Spec.ProviderID)
+	c.Get(context.TODO(), otherNodeName, &savedNode)
+	assert.Empty(t, savedNode.Spec.ProviderID)
+
+	// The Node's ProviderID is set now, so nothing else should happen
+	err = actuator.linkAdoptedNode(context.TODO(), machine, host)
+	assert.NoError(t, err)
+}
+
 func TestExists(t *testing.T) {
 	scheme := runtime.NewScheme()
 	bmoapis.AddToScheme(scheme)
@@ -722,6 +1245,97 @@ func TestExists(t *testing.T) {
 		}
 	}
 }
+
+func TestExistsReprovisioning(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	const machineName = "somemachine"
+	addresses := []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.1.1"}}
+
+	testCases := []struct {
+		Name           string
+		State          bmh.ProvisioningState
+		ReprovisionFor string
+		Expected       bool
+	}{
+		{Name: "deprovisioning", State: bmh.StateDeprovisioning, ReprovisionFor: machineName, Expected: true},
+		{Name: "available", State: bmh.StateAvailable, ReprovisionFor: machineName, Expected: true},
+		{Name: "provisioning", State: bmh.StateProvisioning, ReprovisionFor: machineName, Expected: true},
+		{Name: "not reprovisioning", State: bmh.StateDeprovisioning, Expected: false},
+		{Name: "reprovisioning for another machine", State: bmh.StateDeprovisioning, ReprovisionFor: "othermachine", Expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			host := &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "somehost",
+					Namespace:   "myns",
+					Annotations: map[string]string{},
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       machineName,
+						Namespace:  "myns",
+						Kind:       "Machine",
+						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{
+						State: tc.State,
+					},
+				},
+			}
+			if tc.ReprovisionFor != "" {
+				host.Annotations[ReprovisioningAnnotation] = tc.ReprovisionFor
+			}
+			machine := &machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      machineName,
+					Namespace: "myns",
+					Annotations: map[string]string{
+						HostAnnotation: "myns/somehost",
+					},
+				},
+				Status: machinev1beta1.MachineStatus{
+					Addresses: addresses,
+				},
+			}
+			c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(host, machine).
+				WithStatusSubresource(machine).Build()
+
+			actuator, err := NewActuator(ActuatorParams{
+				Client: c,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := actuator.Exists(context.TODO(), machine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tc.Expected {
+				t.Errorf("expected Exists to return %v, got %v", tc.Expected, result)
+			}
+
+			savedMachine := &machinev1beta1.Machine{}
+			if err := c.Get(context.TODO(), client.ObjectKeyFromObject(machine), savedMachine); err != nil {
+				t.Fatal(err)
+			}
+			if tc.Expected && !reflect.DeepEqual(savedMachine.Status.Addresses, addresses) {
+				t.Errorf("expected the addresses to be kept, got %v", savedMachine.Status.Addresses)
+			}
+			if !tc.Expected && len(savedMachine.Status.Addresses) != 0 {
+				t.Errorf("expected the addresses to be cleared, got %v", savedMachine.Status.Addresses)
+			}
+		})
+	}
+}
+
 func TestGetHost(t *testing.T) {
 	scheme := runtime.NewScheme()
 	bmoapis.AddToScheme(scheme)
@@ -1060,6 +1674,8 @@ func TestDelete(t *testing.T) {
 		ExpectedConsumerRef *corev1.ObjectReference
 		ExpectedResult      error
 		ExpectHostFinalizer bool
+		ExpectHostImage     bool
+		ExpectHostRetained  bool
 	}{
 		{
 			CaseName: "deprovisioning required",
@@ -1226,9 +1842,181 @@ func TestDelete(t *testing.T) {
 				},
 				Status: bmh.BareMetalHostStatus{
 					Provisioning: bmh.ProvisionStatus{
-						State: bmh.StateExternallyProvisioned,
+						State: bmh.StateExternallyProvisioned,
+					},
+					PoweredOn: false,
+				},
+			},
+			Machine: machinev1beta1.Machine{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: "myns",
+					Annotations: map[string]string{
+						HostAnnotation: "myns/myhost",
+					},
+				},
+			},
+			ExpectHostFinalizer: false,
+		},
+
+		{
+			CaseName: "consumer ref should be removed",
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+					Finalizers: []string{
+						machinev1beta1.MachineFinalizer,
+					},
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       "mymachine",
+						Namespace:  "myns",
+						Kind:       "Machine",
+						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{
+						State: bmh.StateReady,
+					},
+				},
+			},
+			Machine: machinev1beta1.Machine{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: "myns",
+					Annotations: map[string]string{
+						HostAnnotation: "myns/myhost",
+					},
+				},
+			},
+			ExpectHostFinalizer: false,
+		},
+
+		{
+			CaseName: "consumer ref does not match, so it should not be removed",
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+					Finalizers: []string{
+						machinev1beta1.MachineFinalizer,
+					},
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       "someoneelsesmachine",
+						Namespace:  "myns",
+						Kind:       "Machine",
+						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					},
+					Image: &bmh.Image{
+						URL: "someoneelsesimage",
+					},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{
+						State: bmh.StateProvisioned,
+					},
+				},
+			},
+			Machine: machinev1beta1.Machine{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: "myns",
+					Annotations: map[string]string{
+						HostAnnotation: "myns/myhost",
+					},
+				},
+			},
+			ExpectedConsumerRef: &corev1.ObjectReference{
+				Name:       "someoneelsesmachine",
+				Namespace:  "myns",
+				Kind:       "Machine",
+				APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+			},
+			ExpectHostFinalizer: true,
+			ExpectHostImage:     true,
+		},
+
+		{
+			CaseName: "retain policy releases the host without deprovisioning",
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       "mymachine",
+						Namespace:  "myns",
+						Kind:       "Machine",
+						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					},
+					Image: &bmh.Image{
+						URL: "myimage",
+					},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{
+						State: bmh.StateProvisioned,
+					},
+				},
+			},
+			Machine: machinev1beta1.Machine{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: "myns",
+					Annotations: map[string]string{
+						HostAnnotation:           "myns/myhost",
+						DeletionPolicyAnnotation: string(bmv1alpha1.DeletionPolicyRetain),
+					},
+				},
+			},
+			ExpectHostImage:    true,
+			ExpectHostRetained: true,
+		},
+
+		{
+			CaseName: "detach policy from the provider spec releases the host without deprovisioning",
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       "mymachine",
+						Namespace:  "myns",
+						Kind:       "Machine",
+						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					},
+					Image: &bmh.Image{
+						URL: "myimage",
+					},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{
+						State: bmh.StateProvisioned,
 					},
-					PoweredOn: false,
 				},
 			},
 			Machine: machinev1beta1.Machine{
@@ -1243,32 +2031,41 @@ func TestDelete(t *testing.T) {
 						HostAnnotation: "myns/myhost",
 					},
 				},
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: machinev1beta1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"deletionPolicy":"Detach"}`),
+						},
+					},
+				},
 			},
-			ExpectHostFinalizer: false,
+			ExpectHostImage: true,
 		},
 
 		{
-			CaseName: "consumer ref should be removed",
+			CaseName: "adopted host is released without deprovisioning",
 			Host: &bmh.BareMetalHost{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "myhost",
 					Namespace: "myns",
-					Finalizers: []string{
-						machinev1beta1.MachineFinalizer,
-					},
 				},
 				Spec: bmh.BareMetalHostSpec{
+					ExternallyProvisioned: true,
 					ConsumerRef: &corev1.ObjectReference{
 						Name:       "mymachine",
 						Namespace:  "myns",
 						Kind:       "Machine",
 						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
 					},
+					Image: &bmh.Image{
+						URL: "myimage",
+					},
 				},
 				Status: bmh.BareMetalHostStatus{
 					Provisioning: bmh.ProvisionStatus{
-						State: bmh.StateReady,
+						State: bmh.StateExternallyProvisioned,
 					},
+					PoweredOn: true,
 				},
 			},
 			Machine: machinev1beta1.Machine{
@@ -1280,32 +2077,30 @@ func TestDelete(t *testing.T) {
 					Name:      "mymachine",
 					Namespace: "myns",
 					Annotations: map[string]string{
-						HostAnnotation: "myns/myhost",
+						HostAnnotation:      "myns/myhost",
+						AdoptHostAnnotation: "",
 					},
 				},
 			},
-			ExpectHostFinalizer: false,
+			ExpectHostImage: true,
 		},
 
 		{
-			CaseName: "consumer ref does not match, so it should not be removed",
+			CaseName: "invalid deletion policy annotation falls back to deprovisioning",
 			Host: &bmh.BareMetalHost{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "myhost",
 					Namespace: "myns",
-					Finalizers: []string{
-						machinev1beta1.MachineFinalizer,
-					},
 				},
 				Spec: bmh.BareMetalHostSpec{
 					ConsumerRef: &corev1.ObjectReference{
-						Name:       "someoneelsesmachine",
+						Name:       "mymachine",
 						Namespace:  "myns",
 						Kind:       "Machine",
 						APIVersion: machinev1beta1.SchemeGroupVersion.String(),
 					},
 					Image: &bmh.Image{
-						URL: "someoneelsesimage",
+						URL: "myimage",
 					},
 				},
 				Status: bmh.BareMetalHostStatus{
@@ -1323,17 +2118,18 @@ func TestDelete(t *testing.T) {
 					Name:      "mymachine",
 					Namespace: "myns",
 					Annotations: map[string]string{
-						HostAnnotation: "myns/myhost",
+						HostAnnotation:           "myns/myhost",
+						DeletionPolicyAnnotation: "Shred",
 					},
 				},
 			},
 			ExpectedConsumerRef: &corev1.ObjectReference{
-				Name:       "someoneelsesmachine",
+				Name:       "mymachine",
 				Namespace:  "myns",
 				Kind:       "Machine",
 				APIVersion: machinev1beta1.SchemeGroupVersion.String(),
 			},
-			ExpectHostFinalizer: true,
+			ExpectedResult: &machineapierrors.RequeueAfterError{},
 		},
 
 		{
@@ -1449,9 +2245,408 @@ func TestDelete(t *testing.T) {
 			t.Errorf("%s: did not expect host to have finalizer and it does %v",
 				tc.CaseName, host.Finalizers)
 		}
+
+		if tc.ExpectHostImage != (host.Spec.Image != nil) {
+			t.Errorf("%s: expected host image to be kept: %v, found %v",
+				tc.CaseName, tc.ExpectHostImage, host.Spec.Image)
+		}
+
+		_, retained := host.Annotations[RetainedHostAnnotation]
+		if tc.ExpectHostRetained != retained {
+			t.Errorf("%s: expected host to be retained: %v, found annotations %v",
+				tc.CaseName, tc.ExpectHostRetained, host.Annotations)
+		}
+	}
+}
+
+func TestHostOnHold(t *testing.T) {
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	machinev1beta1.AddToScheme(scheme)
+
+	for _, annotation := range []string{bmh.DetachedAnnotation, bmh.PausedAnnotation} {
+		t.Run(annotation, func(t *testing.T) {
+			machine, machineName := getMachine("machine1")
+			host, hostName := getBareMetalHost("host1")
+			machine.Annotations = map[string]string{
+				HostAnnotation:                host.Namespace + "/" + host.Name,
+				externalRemediationAnnotation: "",
+			}
+			host.Annotations = map[string]string{annotation: ""}
+			host.Spec.ConsumerRef = consumerRefForMachine(machine)
+			host.Spec.Image = &bmh.Image{URL: testImageURL, Checksum: testImageChecksumURL}
+			// A host on hold does not get its status updated
+			host.Status.Provisioning.State = bmh.StateDeprovisioning
+
+			c := fakeclient.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(machine).
+				WithRuntimeObjects(machine, host).Build()
+			actuator, err := NewActuator(ActuatorParams{
+				Client: c,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exists, err := actuator.Exists(context.TODO(), machine)
+			assert.NoError(t, err)
+			assert.True(t, exists, "a host on hold should be assumed to exist")
+
+			// Update keeps requeueing until the hold is lifted
+			for i := 0; i < 5; i++ {
+				err = actuator.Update(context.TODO(), machine)
+				expectRequeueAfterError(err, t)
+			}
+
+			c.Get(context.TODO(), machineName, machine)
+			assert.True(t, conditions.IsFalse(machine, hostManagedCondition))
+			c.Get(context.TODO(), hostName, host)
+			assert.False(t, hasPowerOffRequestAnnotation(host), "host on hold should not be remediated")
+
+			err = actuator.Delete(context.TODO(), machine)
+			expectRequeueAfterError(err, t)
+			c.Get(context.TODO(), hostName, host)
+			assert.NotNil(t, host.Spec.Image, "host on hold should not be deprovisioned")
+			assert.True(t, consumerRefMatches(host.Spec.ConsumerRef, machine), "host on hold should not be released")
+
+			// Lifting the hold resumes remediation
+			delete(host.Annotations, annotation)
+			host.Status.Provisioning.State = bmh.StateProvisioned
+			c.Update(context.TODO(), host)
+
+			c.Get(context.TODO(), machineName, machine)
+			err = updateUntilDone(actuator, machine)
+			assert.NoError(t, err)
+
+			c.Get(context.TODO(), machineName, machine)
+			assert.True(t, conditions.IsTrue(machine, hostManagedCondition))
+			c.Get(context.TODO(), hostName, host)
+			assert.True(t, hasPowerOffRequestAnnotation(host), "remediation should resume")
+		})
+	}
+}
+
+func TestDeleteReleasedHostOnHold(t *testing.T) {
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	machinev1beta1.AddToScheme(scheme)
+
+	for _, consumerRef := range []*corev1.ObjectReference{
+		nil,
+		{Kind: "Pod", APIVersion: "v1", Name: "pod1", Namespace: "myns"},
+	} {
+		machine, _ := getMachine("machine1")
+		host, hostName := getBareMetalHost("host1")
+		machine.Annotations = map[string]string{HostAnnotation: host.Namespace + "/" + host.Name}
+		host.Annotations = map[string]string{bmh.DetachedAnnotation: "", ReprovisioningAnnotation: ""}
+		host.Finalizers = []string{machinev1beta1.MachineFinalizer}
+		host.Spec.ConsumerRef = consumerRef
+
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(machine).
+			WithRuntimeObjects(machine, host).Build()
+		actuator, err := NewActuator(ActuatorParams{
+			Client: c,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The host is not written to until the hold is lifted, even when
+		// the Machine no longer consumes it
+		err = actuator.Delete(context.TODO(), machine)
+		expectRequeueAfterError(err, t)
+		c.Get(context.TODO(), hostName, host)
+		assert.Contains(t, host.Annotations, ReprovisioningAnnotation)
+		assert.Contains(t, host.Finalizers, machinev1beta1.MachineFinalizer)
+
+		delete(host.Annotations, bmh.DetachedAnnotation)
+		c.Update(context.TODO(), host)
+		err = actuator.Delete(context.TODO(), machine)
+		assert.NoError(t, err)
+		c.Get(context.TODO(), hostName, host)
+		assert.NotContains(t, host.Finalizers, machinev1beta1.MachineFinalizer)
+	}
+}
+
+func TestHostClaim(t *testing.T) {
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	machinev1beta1.AddToScheme(scheme)
+
+	config, _ := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
+	config.UseHostClaim = true
+	pspec, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, machineName := getMachine("machine1")
+	machine.Spec.ProviderSpec = machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: pspec}}
+	host, _ := getBareMetalHost("host1")
+	host.Spec.ConsumerRef = &corev1.ObjectReference{
+		Kind:       hostClaimKind,
+		APIVersion: bmh.GroupVersion.String(),
+		Name:       machine.Name,
+		Namespace:  machine.Namespace,
+	}
+	host.Status.Provisioning.State = bmh.StateProvisioned
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(machine).
+		WithRuntimeObjects(machine, host).Build()
+	actuator, err := NewActuator(ActuatorParams{
+		Client: c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HostClaim is created and the Machine waits for it to be associated
+	err = actuator.Create(context.TODO(), machine)
+	expectRequeueAfterError(err, t)
+
+	claim := &bmh.HostClaim{}
+	err = c.Get(context.TODO(), machineName, claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, consumerRefForMachine(machine), claim.Spec.ConsumerRef)
+	assert.Equal(t, config.HostSelector.MatchLabels, claim.Spec.HostSelector.MatchLabels)
+	assert.Equal(t, machine.Namespace, claim.Spec.HostSelector.InNamespace)
+	assert.Equal(t, testImageURL, claim.Spec.Image.URL)
+	assert.Equal(t, machine.Namespace, claim.Spec.UserData.Namespace)
+	assert.True(t, claim.Spec.PoweredOn)
+
+	// No host is available for the HostClaim
+	meta.SetStatusCondition(&claim.Status.Conditions, metav1.Condition{
+		Type:   bmh.AssociatedCondition,
+		Status: metav1.ConditionFalse,
+		Reason: bmh.NoBareMetalHostReason,
+	})
+	assert.NoError(t, c.Update(context.TODO(), claim))
+	err = actuator.Create(context.TODO(), machine)
+	expectRequeueAfterError(err, t)
+	assert.NoError(t, c.Get(context.TODO(), machineName, machine))
+	if assert.NotNil(t, machine.Status.ErrorReason) {
+		assert.Equal(t, machinev1beta1.InsufficientResourcesMachineError, *machine.Status.ErrorReason)
+	}
+
+	// The HostClaim is associated with the host
+	meta.SetStatusCondition(&claim.Status.Conditions, metav1.Condition{
+		Type:   bmh.AssociatedCondition,
+		Status: metav1.ConditionTrue,
+		Reason: bmh.BareMetalHostAssociatedReason,
+	})
+	claim.Annotations = map[string]string{HostAnnotation: host.Namespace + "/" + host.Name}
+	assert.NoError(t, c.Update(context.TODO(), claim))
+	for i := 0; i < 5; i++ {
+		err = actuator.Create(context.TODO(), machine)
+		if _, isRequeue := err.(*machineapierrors.RequeueAfterError); !isRequeue {
+			break
+		}
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, host.Namespace+"/"+host.Name, machine.Annotations[HostAnnotation])
+	assert.Nil(t, machine.Status.ErrorReason)
+
+	exists, err := actuator.Exists(context.TODO(), machine)
+	assert.NoError(t, err)
+	assert.True(t, exists, "host consumed by the Machine's HostClaim should exist")
+
+	// Deleting the Machine deletes the HostClaim and leaves the host alone
+	err = actuator.Delete(context.TODO(), machine)
+	expectRequeueAfterError(err, t)
+	err = c.Get(context.TODO(), machineName, claim)
+	assert.True(t, errors.IsNotFound(err), "expected HostClaim to be deleted")
+	assert.NoError(t, actuator.Delete(context.TODO(), machine))
+
+	savedHost := &bmh.BareMetalHost{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost))
+	assert.Equal(t, host.Spec.ConsumerRef, savedHost.Spec.ConsumerRef)
+}
+
+func TestNoHostAvailable(t *testing.T) {
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	machinev1beta1.AddToScheme(scheme)
+
+	config, _ := newConfig(t, "", map[string]string{"key1": "value1"}, []bmv1alpha1.HostSelectorRequirement{})
+	pspec, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, machineName := getMachine("machine1")
+	machine.Spec.ProviderSpec = machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: pspec}}
+
+	hosts := []runtime.Object{machine}
+	for _, h := range []struct {
+		name   string
+		state  bmh.ProvisioningState
+		labels map[string]string
+		modify func(*bmh.BareMetalHost)
+	}{
+		{"consumed1", bmh.StateProvisioned, map[string]string{"key1": "value1"}, func(host *bmh.BareMetalHost) {
+			host.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "Machine", Namespace: machine.Namespace, Name: "other1"}
+		}},
+		{"consumed2", bmh.StateProvisioned, map[string]string{"key1": "value1"}, func(host *bmh.BareMetalHost) {
+			host.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "Machine", Namespace: machine.Namespace, Name: "other2"}
+		}},
+		{"inspecting", bmh.StateInspecting, map[string]string{"key1": "value1"}, nil},
+		{"error", bmh.StateAvailable, map[string]string{"key1": "value1"}, func(host *bmh.BareMetalHost) {
+			host.Status.ErrorMessage = "BMC unreachable"
+		}},
+		{"mismatch", bmh.StateAvailable, map[string]string{"key1": "value2"}, nil},
+	} {
+		host, _ := getBareMetalHost(h.name)
+		host.Namespace = machine.Namespace
+		host.Labels = h.labels
+		host.Status.Provisioning.State = h.state
+		if h.modify != nil {
+			h.modify(host)
+		}
+		hosts = append(hosts, host)
+	}
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(machine).
+		WithRuntimeObjects(hosts...).Build()
+	recorder := record.NewFakeRecorder(10)
+	actuator, err := NewActuator(ActuatorParams{
+		Client:        c,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejection reasons are summarized in the ErrorMessage, the provider
+	// status and an event
+	expectedMessage := "No available BareMetalHost found: 2 consumed, 1 in inspecting, 1 with errors, 1 selector mismatch"
+	err = actuator.Create(context.TODO(), machine)
+	expectRequeueAfterError(err, t)
+	assert.NoError(t, c.Get(context.TODO(), machineName, machine))
+	if assert.NotNil(t, machine.Status.ErrorReason) {
+		assert.Equal(t, machinev1beta1.InsufficientResourcesMachineError, *machine.Status.ErrorReason)
+	}
+	if assert.NotNil(t, machine.Status.ErrorMessage) {
+		assert.Equal(t, expectedMessage, *machine.Status.ErrorMessage)
+	}
+	providerStatus, err := providerStatusFromMachine(machine)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]int{
+			"consumed":          2,
+			"in inspecting":     1,
+			"with errors":       1,
+			"selector mismatch": 1,
+		}, providerStatus.HostRejections)
+	}
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Equal(t, "Warning NoHostAvailable "+expectedMessage, <-recorder.Events)
+	}
+
+	// No new event is emitted while the summary is unchanged
+	err = actuator.Create(context.TODO(), machine)
+	expectRequeueAfterError(err, t)
+	assert.Len(t, recorder.Events, 0)
+
+	// Once a host is available, the error and the rejections are cleared
+	host := &bmh.BareMetalHost{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Name: "inspecting", Namespace: machine.Namespace}, host))
+	host.Status.Provisioning.State = bmh.StateAvailable
+	assert.NoError(t, c.Update(context.TODO(), host))
+	for i := 0; i < 5; i++ {
+		err = actuator.Create(context.TODO(), machine)
+		if _, isRequeue := err.(*machineapierrors.RequeueAfterError); !isRequeue {
+			break
+		}
+	}
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), machineName, machine))
+	assert.Nil(t, machine.Status.ErrorReason)
+	assert.Nil(t, machine.Status.ErrorMessage)
+	providerStatus, err = providerStatusFromMachine(machine)
+	if assert.NoError(t, err) {
+		assert.Nil(t, providerStatus.HostRejections)
 	}
 }
 
+func TestPatchStaleObjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	bmoapis.AddToScheme(scheme)
+	machinev1beta1.AddToScheme(scheme)
+
+	machine, machineName := getMachine("machine1")
+	machine.Status.Conditions = []machinev1beta1.Condition{{Type: "First", Status: corev1.ConditionTrue}}
+	host, hostName := getBareMetalHost("host1")
+	host.Status.Provisioning.State = bmh.StateAvailable
+	host.Finalizers = []string{machinev1beta1.MachineFinalizer}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(machine, host).
+		WithStatusSubresource(machine).Build()
+	actuator, err := NewActuator(ActuatorParams{
+		Client: c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staleMachine := &machinev1beta1.Machine{}
+	assert.NoError(t, c.Get(context.TODO(), machineName, staleMachine))
+	staleHost := &bmh.BareMetalHost{}
+	assert.NoError(t, c.Get(context.TODO(), hostName, staleHost))
+	staleConditionsMachine := staleMachine.DeepCopy()
+	staleAddressesMachine := staleMachine.DeepCopy()
+	staleReleasedHost := staleHost.DeepCopy()
+
+	// Other controllers change unrelated fields in the meantime
+	current := &machinev1beta1.Machine{}
+	assert.NoError(t, c.Get(context.TODO(), machineName, current))
+	current.Labels = map[string]string{"other": "controller"}
+	assert.NoError(t, c.Update(context.TODO(), current))
+	current.Status.Conditions = append(current.Status.Conditions,
+		machinev1beta1.Condition{Type: "Second", Status: corev1.ConditionTrue})
+	assert.NoError(t, c.Status().Update(context.TODO(), current))
+	currentHost := &bmh.BareMetalHost{}
+	assert.NoError(t, c.Get(context.TODO(), hostName, currentHost))
+	currentHost.Labels = map[string]string{"other": "controller"}
+	currentHost.Finalizers = append(currentHost.Finalizers, "other/finalizer")
+	assert.NoError(t, c.Update(context.TODO(), currentHost))
+
+	// Writes to the Machine only touch the fields owned by the actuator
+	err = actuator.ensureMachineProviderID(context.TODO(), staleMachine, host)
+	expectRequeueAfterError(err, t)
+	assert.NoError(t, c.Get(context.TODO(), machineName, current))
+	assert.Equal(t, providerIDForHost(host), *current.Spec.ProviderID)
+	assert.Equal(t, "controller", current.Labels["other"])
+
+	// Claiming a host that changed since it was chosen still conflicts
+	config, _ := newConfig(t, "", map[string]string{}, []bmv1alpha1.HostSelectorRequirement{})
+	err = actuator.provisionHost(context.TODO(), staleHost, staleMachine, config)
+	assert.True(t, errors.IsConflict(gherrors.Cause(err)), "expected a conflict, got %v", err)
+
+	// Setting a condition keeps the conditions set in the meantime
+	detachedHost := host.DeepCopy()
+	detachedHost.Annotations = map[string]string{bmh.DetachedAnnotation: ""}
+	assert.NoError(t, actuator.updateHostManagedCondition(context.TODO(), staleConditionsMachine, detachedHost))
+	assert.NoError(t, c.Get(context.TODO(), machineName, current))
+	var conditionTypes []machinev1beta1.ConditionType
+	for _, condition := range current.Status.Conditions {
+		conditionTypes = append(conditionTypes, condition.Type)
+	}
+	assert.Equal(t, []machinev1beta1.ConditionType{"First", "Second", hostManagedCondition}, conditionTypes)
+
+	// The addresses are owned by the actuator alone
+	addresses := []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.1.1"}}
+	expectRequeueAfterError(actuator.applyMachineStatus(context.TODO(), staleAddressesMachine, addresses), t)
+	assert.NoError(t, c.Get(context.TODO(), machineName, current))
+	assert.Equal(t, addresses, current.Status.Addresses)
+	assert.Len(t, current.Status.Conditions, 3)
+
+	// Releasing a host keeps the finalizers added in the meantime
+	staleReleasedHost.Spec.ConsumerRef = consumerRefForMachine(machine)
+	original := staleReleasedHost.DeepCopy()
+	assert.NoError(t, actuator.releaseHost(context.TODO(), staleReleasedHost, original, machine))
+	assert.NoError(t, c.Get(context.TODO(), hostName, currentHost))
+	assert.Nil(t, currentHost.Spec.ConsumerRef)
+	assert.Equal(t, []string{"other/finalizer"}, currentHost.Finalizers)
+	assert.Equal(t, "controller", currentHost.Labels["other"])
+}
+
 func TestConfigFromProviderSpec(t *testing.T) {
 	ps := machinev1beta1.ProviderSpec{
 		Value: &runtime.RawExtension{
@@ -1486,6 +2681,129 @@ func TestConfigFromProviderSpec(t *testing.T) {
 	}
 }
 
+func TestSelectorOperators(t *testing.T) {
+	hostLabels := labels.Set{"ram-gb": "512", "role": "worker"}
+	testCases := []struct {
+		Scenario    string
+		Requirement bmv1alpha1.HostSelectorRequirement
+		Matches     bool
+		ExpectError bool
+	}{
+		{
+			Scenario:    "In",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "role", Operator: "In", Values: []string{"worker", "storage"}},
+			Matches:     true,
+		},
+		{
+			Scenario:    "NotIn",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "role", Operator: "NotIn", Values: []string{"worker"}},
+			Matches:     false,
+		},
+		{
+			Scenario:    "Exists",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "role", Operator: "Exists"},
+			Matches:     true,
+		},
+		{
+			Scenario:    "DoesNotExist",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "maintenance", Operator: "DoesNotExist"},
+			Matches:     true,
+		},
+		{
+			Scenario:    "lowercase doesnotexist",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "role", Operator: "doesnotexist"},
+			Matches:     false,
+		},
+		{
+			Scenario:    "Gt",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "ram-gb", Operator: "Gt", Values: []string{"256"}},
+			Matches:     true,
+		},
+		{
+			Scenario:    "Lt",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "ram-gb", Operator: "Lt", Values: []string{"256"}},
+			Matches:     false,
+		},
+		{
+			Scenario:    "Gt on a missing label",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "cpus", Operator: "Gt", Values: []string{"0"}},
+			Matches:     false,
+		},
+		{
+			Scenario:    "Exists with values",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "role", Operator: "Exists", Values: []string{"worker"}},
+			ExpectError: true,
+		},
+		{
+			Scenario:    "Gt with two values",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "ram-gb", Operator: "Gt", Values: []string{"256", "512"}},
+			ExpectError: true,
+		},
+		{
+			Scenario:    "Lt with a non-integer value",
+			Requirement: bmv1alpha1.HostSelectorRequirement{Key: "ram-gb", Operator: "Lt", Values: []string{"256Gi"}},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			_, providerSpec := newConfig(t, "", map[string]string{},
+				[]bmv1alpha1.HostSelectorRequirement{tc.Requirement})
+			selector, err := SelectorFromProviderSpec(&providerSpec)
+			if tc.ExpectError {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.Matches, selector.Matches(hostLabels))
+			}
+		})
+	}
+}
+
+func TestConfigFromProviderSpecV1beta1(t *testing.T) {
+	ps := machinev1beta1.ProviderSpec{
+		Value: &runtime.RawExtension{
+			Raw: []byte(`apiVersion: baremetal.cluster.k8s.io/v1beta1
+kind: BareMetalMachineProviderSpec
+image:
+  url: http://172.22.0.1/images/rhcos-ootpa-latest.qcow2
+  checksum: http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.md5sum
+dataSecrets:
+  userData:
+    name: worker-user-data
+    namespace: myns
+selection:
+  matchLabels:
+    key1: value1
+  claimMode: HostClaim
+lifecycle:
+  deletionPolicy: Deprovision
+`),
+		},
+	}
+	config, err := configFromProviderSpec(ps)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, bmv1alpha1.Image{URL: testImageURL, Checksum: testImageChecksumURL}, config.Image)
+	assert.Equal(t, &corev1.SecretReference{Name: testUserDataSecretName, Namespace: testUserDataSecretNamespace}, config.UserData)
+	assert.Equal(t, map[string]string{"key1": "value1"}, config.HostSelector.MatchLabels)
+	assert.True(t, config.UseHostClaim)
+	assert.Equal(t, bmv1alpha1.DeletionPolicyDeprovision, config.DeletionPolicy)
+
+	selector, err := SelectorFromProviderSpec(&ps)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "key1=value1", selector.String())
+	}
+
+	// Fields of v1alpha1 are unknown in v1beta1
+	ps.Value.Raw = []byte(`{"apiVersion":"baremetal.cluster.k8s.io/v1beta1","kind":"BareMetalMachineProviderSpec","hostSelector":{}}`)
+	_, err = configFromProviderSpec(ps)
+	assert.Error(t, err)
+}
+
 func newConfig(t *testing.T, UserDataNamespace string, labels map[string]string, reqs []bmv1alpha1.HostSelectorRequirement) (*bmv1alpha1.BareMetalMachineProviderSpec, machinev1beta1.ProviderSpec) {
 	config := bmv1alpha1.BareMetalMachineProviderSpec{
 		Image: bmv1alpha1.Image{
@@ -2168,6 +3486,149 @@ func TestIsPowerOnTimedOut(t *testing.T) {
 	}
 }
 
+func TestSoftPowerOffRemediation(t *testing.T) {
+	machine, machineNamespacedName := getMachine("machine1")
+	host, hostNamespacedName := getBareMetalHost("host1")
+	host.Status.PoweredOn = true
+	machine.Labels = map[string]string{"role": "worker"}
+	machine.Annotations = map[string]string{
+		HostAnnotation:                host.Namespace + "/" + host.Name,
+		externalRemediationAnnotation: "",
+	}
+	mhc := &machinev1beta1.MachineHealthCheck{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   machine.Namespace,
+			Annotations: map[string]string{SoftPowerOffTimeoutAnnotation: "2m"},
+		},
+		Spec: machinev1beta1.MachineHealthCheckSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(machine, host, mhc).Build()
+	actuator, err := NewActuator(ActuatorParams{Client: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reload := func() {
+		machine = &machinev1beta1.Machine{}
+		assert.NoError(t, c.Get(context.TODO(), machineNamespacedName, machine))
+		host = &bmh.BareMetalHost{}
+		assert.NoError(t, c.Get(context.TODO(), hostNamespacedName, host))
+	}
+
+	// a soft power off is requested first
+	reload()
+	assert.NoError(t, actuator.remediateIfNeeded(context.TODO(), machine, host))
+	reload()
+	assert.Equal(t, PowerOffRequest(bmh.RebootModeSoft), host.Annotations[requestPowerOffAnnotation])
+	assert.Equal(t, string(bmh.RebootModeSoft), machine.Annotations[PowerOffModeAnnotation])
+	assert.Contains(t, machine.Annotations, softPowerOffWillTimeoutAtAnnotation)
+
+	// the host is still powered on within the grace period
+	err = actuator.remediateIfNeeded(context.TODO(), machine, host)
+	expectRequeueAfterError(err, t)
+	reload()
+	assert.Equal(t, PowerOffRequest(bmh.RebootModeSoft), host.Annotations[requestPowerOffAnnotation])
+
+	// the host is still powered on after the grace period
+	machine.Annotations[softPowerOffWillTimeoutAtAnnotation] = time.Now().Add(-time.Second).Format(time.RFC3339)
+	assert.NoError(t, c.Update(context.TODO(), machine))
+	reload()
+	assert.NoError(t, actuator.remediateIfNeeded(context.TODO(), machine, host))
+	reload()
+	assert.Equal(t, PowerOffRequest(bmh.RebootModeHard), host.Annotations[requestPowerOffAnnotation])
+	assert.Equal(t, string(bmh.RebootModeHard), machine.Annotations[PowerOffModeAnnotation])
+	assert.NotContains(t, machine.Annotations, softPowerOffWillTimeoutAtAnnotation)
+
+	// nothing more to do until the host is powered off
+	assert.NoError(t, actuator.remediateIfNeeded(context.TODO(), machine, host))
+}
+
+func TestSoftPowerOffTimeout(t *testing.T) {
+	testCases := []struct {
+		Annotations map[string]string
+		Expected    *metav1.Duration
+		Error       bool
+	}{
+		{Annotations: map[string]string{}},
+		{Annotations: map[string]string{SoftPowerOffTimeoutAnnotation: "90s"}, Expected: &metav1.Duration{Duration: 90 * time.Second}},
+		{Annotations: map[string]string{SoftPowerOffTimeoutAnnotation: "0s"}, Error: true},
+		{Annotations: map[string]string{SoftPowerOffTimeoutAnnotation: "-1m"}, Error: true},
+		{Annotations: map[string]string{SoftPowerOffTimeoutAnnotation: "soon"}, Error: true},
+	}
+
+	for _, tc := range testCases {
+		timeout, err := SoftPowerOffTimeout(tc.Annotations)
+		if tc.Error {
+			assert.Error(t, err, tc.Annotations)
+			continue
+		}
+		assert.NoError(t, err, tc.Annotations)
+		assert.Equal(t, tc.Expected, timeout, tc.Annotations)
+	}
+}
+
+// fakeRemediationAdmitter admits remediations once reason is empty.
+type fakeRemediationAdmitter struct {
+	reason string
+}
+
+func (f *fakeRemediationAdmitter) AdmitRemediation(_ context.Context, _ *machinev1beta1.Machine, _ *bmh.BareMetalHost) (bool, string, error) {
+	return f.reason == "", f.reason, nil
+}
+
+func TestPendingRemediation(t *testing.T) {
+	machine, machineNamespacedName := getMachine("machine1")
+	host, hostNamespacedName := getBareMetalHost("host1")
+	host.Status.PoweredOn = true
+	machine.Annotations = map[string]string{
+		HostAnnotation:                host.Namespace + "/" + host.Name,
+		externalRemediationAnnotation: "",
+	}
+
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(machine, host).Build()
+	admitter := &fakeRemediationAdmitter{reason: "2 hosts are being remediated, the limit is 2"}
+	actuator, err := NewActuator(ActuatorParams{Client: c, RemediationAdmitter: admitter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reload := func() {
+		machine = &machinev1beta1.Machine{}
+		assert.NoError(t, c.Get(context.TODO(), machineNamespacedName, machine))
+		host = &bmh.BareMetalHost{}
+		assert.NoError(t, c.Get(context.TODO(), hostNamespacedName, host))
+	}
+
+	// the remediation waits beyond the limits
+	reload()
+	assert.False(t, RemediationInFlight(machine))
+	err = actuator.remediateIfNeeded(context.TODO(), machine, host)
+	expectRequeueAfterError(err, t)
+	reload()
+	assert.Equal(t, admitter.reason, machine.Annotations[RemediationPendingAnnotation])
+	assert.NotContains(t, host.Annotations, requestPowerOffAnnotation)
+
+	// the remediation starts once admitted
+	admitter.reason = ""
+	assert.NoError(t, actuator.remediateIfNeeded(context.TODO(), machine, host))
+	reload()
+	assert.NotContains(t, machine.Annotations, RemediationPendingAnnotation)
+	assert.Contains(t, host.Annotations, requestPowerOffAnnotation)
+	assert.True(t, RemediationInFlight(machine))
+}
+
 func TestGetMhcByMachine(t *testing.T) {
 	scheme := runtime.NewScheme()
 	machinev1beta1.AddToScheme(scheme)
@@ -0,0 +1,243 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	machineapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	gherrors "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const hostClaimKind = "HostClaim"
+
+// RBAC to claim BareMetalHosts through HostClaims
+//+kubebuilder:rbac:groups=metal3.io,resources=hostclaims,verbs=get;list;watch;create;delete
+
+// usesHostClaim returns true if the Machine claims its BareMetalHost through
+// a HostClaim.
+func usesHostClaim(machine *machinev1beta1.Machine) bool {
+	config := machineConfig(machine)
+	return config != nil && config.UseHostClaim
+}
+
+// UsesHostClaim returns true if the Machines with the ProviderSpec claim their
+// BareMetalHost through a HostClaim.
+func UsesHostClaim(providerSpec *machinev1beta1.ProviderSpec) bool {
+	config, err := configFromProviderSpec(*providerSpec)
+	return err == nil && config.UseHostClaim
+}
+
+// hostClaimRefMatches returns true if the consumer reference points to the
+// HostClaim of the Machine, which has the same name and namespace.
+func hostClaimRefMatches(consumer *corev1.ObjectReference, machine *machinev1beta1.Machine) bool {
+	return consumer != nil &&
+		consumer.Kind == hostClaimKind &&
+		consumer.APIVersion == bmh.GroupVersion.String() &&
+		consumer.Name == machine.Name &&
+		consumer.Namespace == machine.Namespace
+}
+
+// hostClaimForMachine returns the HostClaim to create for the Machine.
+func hostClaimForMachine(machine *machinev1beta1.Machine, config *bmv1alpha1.BareMetalMachineProviderSpec) *bmh.HostClaim {
+	claim := &bmh.HostClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machine.Name,
+			Namespace: machine.Namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				Kind:       "Machine",
+				Name:       machine.Name,
+				UID:        machine.UID,
+			}},
+		},
+		Spec: bmh.HostClaimSpec{
+			PoweredOn:   true,
+			ConsumerRef: consumerRefForMachine(machine),
+			HostSelector: bmh.HostSelector{
+				MatchLabels: config.HostSelector.MatchLabels,
+			},
+		},
+	}
+	for i := range config.HostSelector.MatchExpressions {
+		req := &config.HostSelector.MatchExpressions[i]
+		claim.Spec.HostSelector.MatchExpressions = append(claim.Spec.HostSelector.MatchExpressions,
+			bmh.HostSelectorRequirement{
+				Key:      req.Key,
+				Operator: req.SelectorOperator(),
+				Values:   req.Values,
+			})
+	}
+	if len(config.HostSelector.HostNamespaces) == 1 {
+		claim.Spec.HostSelector.InNamespace = config.HostSelector.HostNamespaces[0]
+	} else {
+		claim.Spec.HostSelector.InNamespace = machine.Namespace
+	}
+
+	if config.Image.URL != "" && config.Image.Checksum != "" {
+		claim.Spec.Image = &bmh.Image{
+			URL:          config.Image.URL,
+			Checksum:     config.Image.Checksum,
+			ChecksumType: bmh.ChecksumType(config.Image.ChecksumType),
+		}
+	}
+	if config.CustomDeploy.Method != "" {
+		claim.Spec.CustomDeploy = &bmh.CustomDeploy{
+			Method: config.CustomDeploy.Method,
+		}
+	}
+	if config.UserData != nil {
+		claim.Spec.UserData = config.UserData.DeepCopy()
+		// If UserData does not include a Namespace, default to the Machine's
+		// namespace.
+		if claim.Spec.UserData.Namespace == "" {
+			claim.Spec.UserData.Namespace = machine.Namespace
+		}
+	}
+	return claim
+}
+
+// ensureHostClaim returns the HostClaim of the Machine, creating it if it
+// does not exist yet.
+func (a *Actuator) ensureHostClaim(ctx context.Context, machine *machinev1beta1.Machine,
+	config *bmv1alpha1.BareMetalMachineProviderSpec) (*bmh.HostClaim, error) {
+	claim := &bmh.HostClaim{}
+	key := client.ObjectKey{Name: machine.Name, Namespace: machine.Namespace}
+	err := a.client.Get(ctx, key, claim)
+	if err == nil {
+		return claim, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	claim = hostClaimForMachine(machine, config)
+	allowed, err := ConsumerNamespaceAllowed(ctx, a.client, claim.Spec.HostSelector.InNamespace, machine.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, a.setInsufficientResourcesError(ctx, machine,
+			fmt.Sprintf("Namespace %s does not allow Machines in namespace %s to claim its hosts",
+				claim.Spec.HostSelector.InNamespace, machine.Namespace))
+	}
+	log.Printf("Creating HostClaim for machine %s", machine.Name)
+	if err := a.client.Create(ctx, claim); err != nil {
+		return nil, gherrors.Wrap(err, "failed to create HostClaim")
+	}
+	return claim, nil
+}
+
+// hostForClaim returns the BareMetalHost bound to the HostClaim, or nil if
+// the HostClaim is not associated with a host yet. The bound host is recorded
+// in the HostAnnotation of the HostClaim.
+func (a *Actuator) hostForClaim(ctx context.Context, claim *bmh.HostClaim) (*bmh.BareMetalHost, error) {
+	if !meta.IsStatusConditionTrue(claim.Status.Conditions, bmh.AssociatedCondition) {
+		return nil, nil
+	}
+	hostKey, ok := claim.Annotations[HostAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	hostNamespace, hostName, err := cache.SplitMetaNamespaceKey(hostKey)
+	if err != nil {
+		return nil, fmt.Errorf("HostClaim %s has invalid %s annotation %q: %w",
+			claim.Name, HostAnnotation, hostKey, err)
+	}
+
+	host := &bmh.BareMetalHost{}
+	err = a.client.Get(ctx, client.ObjectKey{Name: hostName, Namespace: hostNamespace}, host)
+	if errors.IsNotFound(err) {
+		log.Printf("Host %s bound to HostClaim %s not found", hostKey, claim.Name)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// createWithHostClaim claims a BareMetalHost for the Machine through a
+// HostClaim. Returns a RequeueAfterError until the HostClaim is associated
+// with a host.
+func (a *Actuator) createWithHostClaim(ctx context.Context, machine *machinev1beta1.Machine,
+	config *bmv1alpha1.BareMetalMachineProviderSpec) error {
+	claim, err := a.ensureHostClaim(ctx, machine, config)
+	if err != nil {
+		return err
+	}
+
+	host, err := a.hostForClaim(ctx, claim)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		cond := meta.FindStatusCondition(claim.Status.Conditions, bmh.AssociatedCondition)
+		if cond != nil && cond.Reason == bmh.NoBareMetalHostReason {
+			return a.setInsufficientResourcesError(ctx, machine, "No available BareMetalHost found for HostClaim")
+		}
+		log.Printf("Waiting for HostClaim of machine %s to be associated with a host", machine.Name)
+		return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+	}
+	log.Printf("Machine %s associated with host %s by HostClaim", machine.Name, host.Name)
+
+	if err := a.ensureAnnotation(ctx, machine, host); err != nil {
+		return err
+	}
+
+	if err := a.clearInsufficientResourcesError(ctx, machine); err != nil {
+		return err
+	}
+
+	log.Printf("Finished creating machine %v", machine.Name)
+	return nil
+}
+
+// deleteHostClaim deletes the HostClaim of the Machine. Returns a
+// RequeueAfterError until the HostClaim is gone, which happens once its host
+// has been deprovisioned and released.
+func (a *Actuator) deleteHostClaim(ctx context.Context, machine *machinev1beta1.Machine) error {
+	claim := &bmh.HostClaim{}
+	key := client.ObjectKey{Name: machine.Name, Namespace: machine.Namespace}
+	err := a.client.Get(ctx, key, claim)
+	if errors.IsNotFound(err) {
+		log.Printf("finished deleting machine %v.", machine.Name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if claim.DeletionTimestamp.IsZero() {
+		log.Printf("deleting HostClaim of machine %v", machine.Name)
+		err = a.client.Delete(ctx, claim)
+		if err != nil && !errors.IsNotFound(err) {
+			return gherrors.Wrap(err, "failed to delete HostClaim")
+		}
+	}
+	log.Printf("waiting for HostClaim of machine %v to be deleted", machine.Name)
+	return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+}
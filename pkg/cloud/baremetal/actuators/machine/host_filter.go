@@ -0,0 +1,329 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/celselector"
+	gherrors "github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// HostRejection is the reason a BareMetalHost cannot be claimed by a Machine.
+type HostRejection string
+
+const (
+	// HostConsumed means the host is already claimed by something else.
+	HostConsumed HostRejection = "consumed"
+	// HostDeleting means the host is being deleted.
+	HostDeleting HostRejection = "deleting"
+	// HostRetained means the host was retained when its previous Machine
+	// was deleted.
+	HostRetained HostRejection = "retained"
+	// HostWrongState means the host is not in a provisioning state from
+	// which it can be claimed.
+	HostWrongState HostRejection = "wrong state"
+	// HostHasError means the host reports an error.
+	HostHasError HostRejection = "error"
+	// HostExternallyProvisioned means the host was provisioned by something
+	// else and must not be overwritten.
+	HostExternallyProvisioned HostRejection = "externally provisioned"
+	// HostNotExternallyProvisioned means the Machine adopts externally
+	// provisioned hosts and this host is not one.
+	HostNotExternallyProvisioned HostRejection = "not externally provisioned"
+	// HostSelectorMismatch means the host labels or CEL expression do not
+	// match the HostSelector of the Machine.
+	HostSelectorMismatch HostRejection = "selector mismatch"
+)
+
+// rejectionOrder is the order in which the reasons are listed in a
+// RejectionSummary.
+var rejectionOrder = []HostRejection{
+	HostConsumed,
+	HostDeleting,
+	HostRetained,
+	HostWrongState,
+	HostHasError,
+	HostExternallyProvisioned,
+	HostNotExternallyProvisioned,
+	HostSelectorMismatch,
+}
+
+// pendingStates are the provisioning states a host passes through after it
+// is enrolled and before it can be claimed.
+var pendingStates = map[bmh.ProvisioningState]bool{
+	bmh.StateNone:         true,
+	bmh.StateRegistering:  true,
+	bmh.StateInspecting:   true,
+	bmh.StateMatchProfile: true,
+	bmh.StatePreparing:    true,
+}
+
+// HostMatcher decides whether a BareMetalHost matches the hostSelector of a
+// ProviderSpec, using both its labels and its CEL expression.
+type HostMatcher struct {
+	selector labels.Selector
+	program  *celselector.Program
+}
+
+// NewHostMatcher returns a HostMatcher for a label selector and a compiled
+// CEL expression, which may be nil.
+func NewHostMatcher(selector labels.Selector, program *celselector.Program) *HostMatcher {
+	return &HostMatcher{selector: selector, program: program}
+}
+
+// HostMatcherFromProviderSpec returns the HostMatcher for the hostSelector
+// of the ProviderSpec. Compiled CEL expressions are cached, so this is cheap
+// to call for each reconcile of a MachineSet.
+func HostMatcherFromProviderSpec(providerSpec *machinev1beta1.ProviderSpec) (*HostMatcher, error) {
+	selector, err := SelectorFromProviderSpec(providerSpec)
+	if err != nil {
+		return nil, err
+	}
+	config, err := configFromProviderSpec(*providerSpec)
+	if err != nil {
+		return nil, err
+	}
+	var program *celselector.Program
+	if config.HostSelector.CELSelector != "" {
+		program, err = celselector.Compile(config.HostSelector.CELSelector)
+		if err != nil {
+			return nil, gherrors.Wrap(err, "invalid celSelector")
+		}
+	}
+	return NewHostMatcher(selector, program), nil
+}
+
+// ValidateCELSelector returns the problems compiling the celSelector of a
+// hostSelector, at fldPath.
+func ValidateCELSelector(expression string, fldPath *field.Path) field.ErrorList {
+	if expression == "" {
+		return nil
+	}
+	if _, err := celselector.Compile(expression); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, expression, err.Error())}
+	}
+	return nil
+}
+
+// Mismatch returns why the host does not match the hostSelector, or an
+// empty string if it does. A CEL expression that fails to evaluate for the
+// host does not match it.
+func (m *HostMatcher) Mismatch(host *bmh.BareMetalHost) string {
+	if !m.selector.Matches(labels.Set(host.ObjectMeta.Labels)) {
+		return "labels do not match hostSelector"
+	}
+	if m.program == nil {
+		return ""
+	}
+	matches, err := m.program.Matches(host)
+	if err != nil {
+		return fmt.Sprintf("celSelector failed: %v", err)
+	}
+	if !matches {
+		return "does not match celSelector"
+	}
+	return ""
+}
+
+// Matches returns true if the host matches the hostSelector.
+func (m *HostMatcher) Matches(host *bmh.BareMetalHost) bool {
+	return m.Mismatch(host) == ""
+}
+
+// HostFilter applies the rules used to decide whether a Machine may claim a
+// BareMetalHost.
+type HostFilter struct {
+	machine        *machinev1beta1.Machine
+	matcher        *HostMatcher
+	adopt          bool
+	includePending bool
+}
+
+// NewHostFilter returns a HostFilter for the Machine, based on its
+// ProviderSpec and annotations.
+func NewHostFilter(machine *machinev1beta1.Machine) (*HostFilter, error) {
+	// Using the label selector on ListOptions doesn't seem to work.
+	// I think it's because we have a local cache of all BareMetalHosts.
+	matcher, err := HostMatcherFromProviderSpec(&machine.Spec.ProviderSpec)
+	if err != nil {
+		return nil, err
+	}
+	config, err := configFromProviderSpec(machine.Spec.ProviderSpec)
+	if err != nil {
+		return nil, err
+	}
+	return &HostFilter{
+		machine: machine,
+		matcher: matcher,
+		adopt:   adoptsExternallyProvisionedHost(machine, config),
+	}, nil
+}
+
+// NewMachineSetHostFilter returns a HostFilter for the Machines created from
+// the template of the MachineSet. If includePending is true, hosts that are
+// still being registered, inspected or prepared are accepted too, since they
+// are expected to become available.
+func NewMachineSetHostFilter(ms *machinev1beta1.MachineSet, includePending bool) (*HostFilter, error) {
+	machine := &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ms.Name + "-template",
+			Namespace:   ms.Namespace,
+			Labels:      ms.Spec.Template.Labels,
+			Annotations: ms.Spec.Template.Annotations,
+		},
+		Spec: ms.Spec.Template.Spec,
+	}
+	filter, err := NewHostFilter(machine)
+	if err != nil {
+		return nil, err
+	}
+	filter.includePending = includePending
+	return filter, nil
+}
+
+// Reject returns the reason the Machine cannot claim the host, along with a
+// human readable detail, or an empty reason if the host is available. A host
+// already claimed by the Machine itself is reported as consumed.
+func (f *HostFilter) Reject(host *bmh.BareMetalHost) (HostRejection, string) {
+	if host.Spec.ConsumerRef != nil {
+		// consumerRef is set, so the host is in use
+		return HostConsumed, fmt.Sprintf("consumed by %s %s/%s", host.Spec.ConsumerRef.Kind,
+			host.Spec.ConsumerRef.Namespace, host.Spec.ConsumerRef.Name)
+	}
+	if host.GetDeletionTimestamp() != nil {
+		// the host is being deleted
+		return HostDeleting, "being deleted"
+	}
+	if retainedBy, retained := host.Annotations[RetainedHostAnnotation]; retained {
+		// the host was retained when its previous Machine was deleted
+		return HostRetained, fmt.Sprintf("retained by %s", retainedBy)
+	}
+	state := host.Status.Provisioning.State
+	if f.adopt {
+		if !host.Spec.ExternallyProvisioned {
+			// only hosts provisioned by something else can be adopted
+			return HostNotExternallyProvisioned, "not externally provisioned"
+		}
+		if state != bmh.StateExternallyProvisioned && !(f.includePending && pendingStates[state]) {
+			return HostWrongState, fmt.Sprintf("in state %q", state)
+		}
+	} else {
+		switch {
+		case state == bmh.StateReady, state == bmh.StateAvailable:
+			// the host is available to be provisioned
+		case f.includePending && pendingStates[state]:
+			// the host will be available once introspection completes
+		default:
+			// the host has not completed introspection or has an error
+			return HostWrongState, fmt.Sprintf("in state %q", state)
+		}
+		if host.Spec.ExternallyProvisioned {
+			// the host was provisioned by something else, we should
+			// not overwrite it
+			return HostExternallyProvisioned, "externally provisioned"
+		}
+	}
+	if host.Status.ErrorMessage != "" {
+		// the host has some sort of error
+		return HostHasError, fmt.Sprintf("error: %s", host.Status.ErrorMessage)
+	}
+	if mismatch := f.matcher.Mismatch(host); mismatch != "" {
+		return HostSelectorMismatch, mismatch
+	}
+	return "", ""
+}
+
+// RejectionSummary counts the BareMetalHosts a HostFilter rejected, by
+// reason. Hosts in the wrong state are counted by provisioning state.
+type RejectionSummary struct {
+	counts  map[string]int
+	reasons map[string]HostRejection
+}
+
+// NewRejectionSummary returns an empty RejectionSummary.
+func NewRejectionSummary() *RejectionSummary {
+	return &RejectionSummary{
+		counts:  map[string]int{},
+		reasons: map[string]HostRejection{},
+	}
+}
+
+// Add counts a host rejected for the reason. Hosts that were not rejected
+// are ignored.
+func (s *RejectionSummary) Add(host *bmh.BareMetalHost, reason HostRejection) {
+	var label string
+	switch reason {
+	case "":
+		return
+	case HostWrongState:
+		state := host.Status.Provisioning.State
+		if state == bmh.StateNone {
+			state = "unknown state"
+		}
+		label = fmt.Sprintf("in %s", state)
+	case HostHasError:
+		label = "with errors"
+	default:
+		label = string(reason)
+	}
+	s.counts[label]++
+	s.reasons[label] = reason
+}
+
+// Counts returns the number of hosts rejected for each reason, keyed by the
+// labels used in String.
+func (s *RejectionSummary) Counts() map[string]int {
+	counts := make(map[string]int, len(s.counts))
+	for label, count := range s.counts {
+		counts[label] = count
+	}
+	return counts
+}
+
+// String returns a human readable summary such as
+// "12 consumed, 3 in inspecting, 2 with errors, 5 selector mismatch".
+func (s *RejectionSummary) String() string {
+	rank := map[HostRejection]int{}
+	for i, reason := range rejectionOrder {
+		rank[reason] = i
+	}
+	labels := make([]string, 0, len(s.counts))
+	for label := range s.counts {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		ri, rj := rank[s.reasons[labels[i]]], rank[s.reasons[labels[j]]]
+		if ri != rj {
+			return ri < rj
+		}
+		return labels[i] < labels[j]
+	})
+
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf("%d %s", s.counts[label], label))
+	}
+	return strings.Join(parts, ", ")
+}
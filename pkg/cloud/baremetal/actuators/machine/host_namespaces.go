@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AllowedConsumerNamespacesAnnotation is the key for an annotation on a
+	// Namespace holding BareMetalHosts. Its value is a comma-separated list of
+	// the namespaces whose Machines may claim those hosts, or "*" to allow
+	// Machines from any namespace. Machines may always claim hosts in their
+	// own namespace.
+	AllowedConsumerNamespacesAnnotation = "metal3.io/allowed-consumer-namespaces"
+	anyConsumerNamespace                = "*"
+)
+
+// RBAC to read the host pool policy from Namespaces
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// HostNamespacesFromProviderSpec returns the namespaces in which
+// BareMetalHosts are searched for a Machine in consumerNamespace with the
+// given ProviderSpec. The policy of each namespace is not checked.
+func HostNamespacesFromProviderSpec(providerSpec *machinev1beta1.ProviderSpec, consumerNamespace string) ([]string, error) {
+	config, err := configFromProviderSpec(*providerSpec)
+	if err != nil {
+		log.Printf("Error reading ProviderSpec: %s", err.Error())
+		return nil, err
+	}
+	if len(config.HostSelector.HostNamespaces) == 0 {
+		return []string{consumerNamespace}, nil
+	}
+	namespaces := []string{}
+	for _, ns := range config.HostSelector.HostNamespaces {
+		if !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
+
+// AllowedConsumerNamespaces returns the namespaces listed in the
+// AllowedConsumerNamespacesAnnotation of a host Namespace.
+func AllowedConsumerNamespaces(namespace *corev1.Namespace) []string {
+	allowed := []string{}
+	for _, ns := range strings.Split(namespace.Annotations[AllowedConsumerNamespacesAnnotation], ",") {
+		ns = strings.TrimSpace(ns)
+		if ns != "" {
+			allowed = append(allowed, ns)
+		}
+	}
+	return allowed
+}
+
+// ConsumerNamespaceAllowed returns true if Machines in consumerNamespace may
+// claim BareMetalHosts in hostNamespace.
+func ConsumerNamespaceAllowed(ctx context.Context, c client.Reader, hostNamespace, consumerNamespace string) (bool, error) {
+	if hostNamespace == consumerNamespace {
+		return true, nil
+	}
+	namespace := corev1.Namespace{}
+	err := c.Get(ctx, client.ObjectKey{Name: hostNamespace}, &namespace)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	allowed := AllowedConsumerNamespaces(&namespace)
+	return slices.Contains(allowed, anyConsumerNamespace) || slices.Contains(allowed, consumerNamespace), nil
+}
+
+// ListHosts returns the BareMetalHosts that may be claimed by a Machine in
+// consumerNamespace with the given ProviderSpec, from every host namespace
+// that allows it. Hosts are not filtered by the HostSelector labels.
+func ListHosts(ctx context.Context, c client.Reader, providerSpec *machinev1beta1.ProviderSpec, consumerNamespace string) ([]bmh.BareMetalHost, error) {
+	namespaces, err := HostNamespacesFromProviderSpec(providerSpec, consumerNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []bmh.BareMetalHost{}
+	for _, ns := range namespaces {
+		allowed, err := ConsumerNamespaceAllowed(ctx, c, ns, consumerNamespace)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			log.Printf("Namespace '%s' does not allow Machines in namespace '%s' to claim its hosts",
+				ns, consumerNamespace)
+			continue
+		}
+
+		nsHosts := bmh.BareMetalHostList{}
+		err = c.List(ctx, &nsHosts, &client.ListOptions{Namespace: ns})
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, nsHosts.Items...)
+	}
+	return hosts, nil
+}
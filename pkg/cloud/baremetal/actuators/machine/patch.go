@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager is the field manager recorded for the changes the actuator
+// makes to Machines, Nodes and BareMetalHosts.
+const FieldManager = "cluster-api-provider-baremetal"
+
+// patch writes the changes made to obj since original was copied from it as
+// a merge patch. Only the changed fields are sent, so concurrent changes to
+// other fields by other controllers do not cause a conflict. A merge patch
+// replaces a changed list as a whole, so the finalizers and conditions are
+// changed with removeFinalizer and setCondition instead.
+func (a *Actuator) patch(ctx context.Context, obj client.Object, original client.Object) error {
+	return a.client.Patch(ctx, obj, client.MergeFrom(original), client.FieldOwner(FieldManager))
+}
+
+// patchWithLock is like patch, but fails with a conflict if obj was changed
+// by anyone else since original was read. This is used when claiming a
+// BareMetalHost, so that two Machines cannot claim the same host.
+func (a *Actuator) patchWithLock(ctx context.Context, obj client.Object, original client.Object) error {
+	return a.client.Patch(ctx, obj,
+		client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}),
+		client.FieldOwner(FieldManager))
+}
+
+// patchStatus writes the changes made to the status of obj since original was
+// copied from it as a merge patch.
+func (a *Actuator) patchStatus(ctx context.Context, obj client.Object, original client.Object) error {
+	return a.client.Status().Patch(ctx, obj, client.MergeFrom(original), client.FieldOwner(FieldManager))
+}
+
+// jsonPatchOperation is an operation of a JSON patch (RFC 6902).
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// jsonPatch returns a JSON patch of the operations.
+func jsonPatch(operations ...jsonPatchOperation) (client.Patch, error) {
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+	return client.RawPatch(types.JSONPatchType, data), nil
+}
+
+// removeFinalizer removes finalizer from obj. The entry is tested before it
+// is removed, so the finalizers others add or remove in the meantime are
+// kept, and the patch fails rather than removing another entry if the list
+// changed under it.
+func (a *Actuator) removeFinalizer(ctx context.Context, obj client.Object, finalizer string) error {
+	i := slices.Index(obj.GetFinalizers(), finalizer)
+	if i < 0 {
+		return nil
+	}
+	path := fmt.Sprintf("/metadata/finalizers/%d", i)
+	patch, err := jsonPatch(
+		jsonPatchOperation{Op: "test", Path: path, Value: finalizer},
+		jsonPatchOperation{Op: "remove", Path: path},
+	)
+	if err != nil {
+		return err
+	}
+	return a.client.Patch(ctx, obj, patch, client.FieldOwner(FieldManager))
+}
+
+// setCondition writes condition to the status of machine, replacing the
+// condition of the same type. Only that entry is written, so the conditions
+// set by others in the meantime are kept.
+func (a *Actuator) setCondition(ctx context.Context, machine *machinev1beta1.Machine, condition machinev1beta1.Condition) error {
+	var operations []jsonPatchOperation
+	i := slices.IndexFunc(machine.Status.Conditions, func(c machinev1beta1.Condition) bool {
+		return c.Type == condition.Type
+	})
+	switch {
+	case i >= 0:
+		path := fmt.Sprintf("/status/conditions/%d", i)
+		operations = []jsonPatchOperation{
+			{Op: "test", Path: path + "/type", Value: condition.Type},
+			{Op: "replace", Path: path, Value: condition},
+		}
+	case len(machine.Status.Conditions) == 0:
+		operations = []jsonPatchOperation{
+			{Op: "test", Path: "/status/conditions", Value: nil},
+			{Op: "add", Path: "/status/conditions", Value: []machinev1beta1.Condition{condition}},
+		}
+	default:
+		operations = []jsonPatchOperation{
+			{Op: "add", Path: "/status/conditions/-", Value: condition},
+		}
+	}
+	patch, err := jsonPatch(operations...)
+	if err != nil {
+		return err
+	}
+	return a.client.Status().Patch(ctx, machine, patch, client.FieldOwner(FieldManager))
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	machineapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RemediationPendingAnnotation is the key for the annotation in which the
+	// remediation of an unhealthy Machine records why it is waiting to
+	// start. It is removed once the remediation starts.
+	RemediationPendingAnnotation = "remediation.metal3.io/pending"
+
+	remediationPendingReason  = "RemediationPending"
+	remediationAdmittedReason = "RemediationAdmitted"
+)
+
+// RemediationAdmitter decides whether the remediation of a host may start,
+// so that only a limited number of hosts are remediated at once.
+type RemediationAdmitter interface {
+	// AdmitRemediation returns true if the host of machine may be powered
+	// off for its remediation. Otherwise the reason is why it has to wait.
+	// An admission may have a reason too.
+	AdmitRemediation(ctx context.Context, machine *machinev1beta1.Machine,
+		host *bmh.BareMetalHost) (admitted bool, reason string, err error)
+}
+
+// RemediationInFlight returns true if the remediation of machine requested by
+// its MachineHealthCheck has started and is not done yet.
+func RemediationInFlight(machine *machinev1beta1.Machine) bool {
+	if _, ok := machine.Annotations[externalRemediationAnnotation]; !ok {
+		return false
+	}
+	_, requested := machine.Annotations[PowerOffModeAnnotation]
+	_, poweredOff := machine.Annotations[poweredOffForRemediation]
+	return requested || poweredOff
+}
+
+// admitRemediation returns nil if the remediation of machine may start, with
+// an event for the reason of the admission if it has one. If it has to wait,
+// the reason is recorded on machine and a RequeueAfterError is returned.
+func (a *Actuator) admitRemediation(ctx context.Context, machine *machinev1beta1.Machine, baremetalhost *bmh.BareMetalHost) error {
+	if a.remediationAdmitter == nil {
+		return nil
+	}
+	admitted, reason, err := a.remediationAdmitter.AdmitRemediation(ctx, machine, baremetalhost)
+	if err != nil {
+		return err
+	}
+	if admitted {
+		if reason != "" {
+			log.Printf("Remediation of Machine %s is admitted: %s", machine.Name, reason)
+			a.recordEvent(machine, corev1.EventTypeNormal, remediationAdmittedReason,
+				fmt.Sprintf("Remediation of host %s is admitted: %s", baremetalhost.Name, reason))
+		}
+		return nil
+	}
+
+	log.Printf("Remediation of Machine %s is pending: %s", machine.Name, reason)
+	if machine.Annotations[RemediationPendingAnnotation] != reason {
+		original := machine.DeepCopy()
+		machine.Annotations[RemediationPendingAnnotation] = reason
+		if err := a.patch(ctx, machine, original); err != nil {
+			log.Printf("failed to record pending remediation on %s: %s", machine.Name, err.Error())
+			return err
+		}
+		a.recordEvent(machine, corev1.EventTypeNormal, remediationPendingReason,
+			fmt.Sprintf("Remediation of host %s is pending: %s", baremetalhost.Name, reason))
+	}
+	return &machineapierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Kubernetes authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SoftPowerOffTimeoutAnnotation is the key for an optional annotation
+	// with a duration, such as "2m", for which a soft (ACPI) power off of
+	// the host of an unhealthy Machine is tried before it is powered off
+	// hard. It is read from the MachineHealthCheck of the Machine, or from
+	// the Metal3RemediationTemplate of the remediation. Without it the host
+	// is powered off hard right away.
+	SoftPowerOffTimeoutAnnotation = "metal3.io/soft-power-off-timeout"
+
+	// PowerOffModeAnnotation is the key for the annotation in which the
+	// remediation of a Machine records the mode of the last power off it
+	// requested for its host.
+	PowerOffModeAnnotation = "remediation.metal3.io/power-off-mode"
+
+	softPowerOffWillTimeoutAtAnnotation = "remediation.metal3.io/soft-power-off-will-timeout-at"
+)
+
+// SoftPowerOffTimeout returns the grace period for a soft power off set by
+// the SoftPowerOffTimeoutAnnotation, or nil if there is none.
+func SoftPowerOffTimeout(annotations map[string]string) (*metav1.Duration, error) {
+	value, ok := annotations[SoftPowerOffTimeoutAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("annotation %s must be a positive duration, not %q", SoftPowerOffTimeoutAnnotation, value)
+	}
+	return &metav1.Duration{Duration: timeout}, nil
+}
+
+// PowerOffRequest returns the value of a reboot annotation that powers off a
+// BareMetalHost in the given mode.
+func PowerOffRequest(mode bmh.RebootMode) string {
+	value, err := json.Marshal(bmh.RebootAnnotationArguments{Mode: mode})
+	if err != nil {
+		// cannot happen with a struct of strings
+		return ""
+	}
+	return string(value)
+}
+
+// softPowerOffTimeoutForMachine returns the grace period for a soft power off
+// of the host of machine, set on its MachineHealthCheck, or nil if it should
+// be powered off hard right away.
+func (a *Actuator) softPowerOffTimeoutForMachine(machine *machinev1beta1.Machine) *metav1.Duration {
+	mhc := a.getMhcByMachine(machine)
+	if mhc == nil {
+		return nil
+	}
+	timeout, err := SoftPowerOffTimeout(mhc.Annotations)
+	if err != nil {
+		log.Printf("Ignoring soft power off timeout of MHC %q: %s", mhc.Name, err)
+		return nil
+	}
+	return timeout
+}
+
+// softPowerOffRemaining returns how long is left before the soft power off of
+// the host of machine is escalated to a hard one, and false if no soft power
+// off is pending.
+func softPowerOffRemaining(machine *machinev1beta1.Machine) (time.Duration, bool) {
+	if machine.Annotations[PowerOffModeAnnotation] != string(bmh.RebootModeSoft) {
+		return 0, false
+	}
+	timeoutAt, err := time.Parse(annotationTimestampFormat, machine.Annotations[softPowerOffWillTimeoutAtAnnotation])
+	if err != nil {
+		log.Printf("Unable to parse time from annotation %q on machine %q, assuming soft power off has timed out",
+			softPowerOffWillTimeoutAtAnnotation, machine.Name)
+		return 0, true
+	}
+	return time.Until(timeoutAt), true
+}
+
+// forcePowerOff escalates the power off request of the host to a hard one,
+// after a soft power off timed out.
+func (a *Actuator) forcePowerOff(ctx context.Context, machine *machinev1beta1.Machine, baremetalhost *bmh.BareMetalHost) error {
+	originalMachine := machine.DeepCopy()
+	machine.Annotations[PowerOffModeAnnotation] = string(bmh.RebootModeHard)
+	delete(machine.Annotations, softPowerOffWillTimeoutAtAnnotation)
+	if err := a.patch(ctx, machine, originalMachine); err != nil {
+		log.Printf("failed to record hard power off on %s: %s", machine.Name, err.Error())
+		return err
+	}
+
+	originalHost := baremetalhost.DeepCopy()
+	if baremetalhost.Annotations == nil {
+		baremetalhost.Annotations = make(map[string]string)
+	}
+	baremetalhost.Annotations[requestPowerOffAnnotation] = PowerOffRequest(bmh.RebootModeHard)
+	if err := a.patch(ctx, baremetalhost, originalHost); err != nil {
+		log.Printf("failed to request hard power off of %s: %s", baremetalhost.Name, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+)
+
+const (
+	// MinReplicasAnnotation is the key for an optional annotation on an
+	// autoscaled MachineSet with the minimum number of replicas. The
+	// MachineSet is not scaled below it, even if there are fewer hosts.
+	MinReplicasAnnotation = "metal3.io/autoscale-min-replicas"
+
+	// MaxReplicasAnnotation is the key for an optional annotation on an
+	// autoscaled MachineSet with the maximum number of replicas.
+	MaxReplicasAnnotation = "metal3.io/autoscale-max-replicas"
+
+	// SpareHostsAnnotation is the key for an optional annotation on an
+	// autoscaled MachineSet with the number of matching hosts to leave
+	// unclaimed, for example to replace failed hosts.
+	SpareHostsAnnotation = "metal3.io/autoscale-spare-hosts"
+
+	// ScaleDownDelayAnnotation is the key for an optional annotation on an
+	// autoscaled MachineSet with the duration, such as "10m", for which
+	// fewer replicas must be wanted before the MachineSet is scaled down.
+	ScaleDownDelayAnnotation = "metal3.io/autoscale-scale-down-delay"
+
+	// IncludePendingHostsAnnotation is the key for an optional annotation
+	// that, when added to an autoscaled MachineSet with any value, also
+	// counts matching hosts that are still being registered, inspected or
+	// prepared.
+	IncludePendingHostsAnnotation = "metal3.io/autoscale-include-pending-hosts"
+
+	// PriorityAnnotation is the key for an optional annotation on an
+	// autoscaled MachineSet with an integer priority, 0 by default. Free
+	// hosts that the Machines of several autoscaled MachineSets could claim
+	// are only counted by the one with the highest priority, or by the
+	// first by name among those with the same priority.
+	PriorityAnnotation = "metal3.io/autoscale-priority"
+
+	// scaleDownSinceAnnotation records when the autoscaler first wanted
+	// fewer replicas than the MachineSet has, while it waits for the scale
+	// down delay to pass.
+	scaleDownSinceAnnotation = "metal3.io/autoscale-scale-down-since"
+
+	scaleDownSinceFormat = time.RFC3339
+)
+
+// AutoScalePolicyAnnotations are the keys of the annotations that make up an
+// AutoScalePolicy.
+var AutoScalePolicyAnnotations = []string{
+	MinReplicasAnnotation,
+	MaxReplicasAnnotation,
+	SpareHostsAnnotation,
+	ScaleDownDelayAnnotation,
+	IncludePendingHostsAnnotation,
+	PriorityAnnotation,
+}
+
+// AutoScalePolicy bounds the number of replicas an autoscaled MachineSet is
+// scaled to, and how quickly it is scaled down.
+type AutoScalePolicy struct {
+	// MinReplicas is the minimum number of replicas, if set.
+	MinReplicas *int32
+	// MaxReplicas is the maximum number of replicas, if set.
+	MaxReplicas *int32
+	// SpareHosts is the number of matching hosts left unclaimed.
+	SpareHosts int32
+	// ScaleDownDelay is how long fewer replicas must be wanted before
+	// scaling down. Zero scales down immediately.
+	ScaleDownDelay time.Duration
+	// IncludePendingHosts counts hosts that will become available once
+	// they are registered, inspected and prepared.
+	IncludePendingHosts bool
+	// Priority decides which of the autoscaled MachineSets sharing a free
+	// host counts it.
+	Priority int32
+}
+
+// AutoScalePolicyFromAnnotations returns the AutoScalePolicy set by the
+// annotations of a MachineSet.
+func AutoScalePolicyFromAnnotations(annotations map[string]string) (*AutoScalePolicy, error) {
+	policy := &AutoScalePolicy{}
+
+	parseCount := func(key string) (*int32, error) {
+		value, ok := annotations[key]
+		if !ok {
+			return nil, nil
+		}
+		count, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("annotation %s must be a non-negative integer, not %q", key, value)
+		}
+		result := int32(count)
+		return &result, nil
+	}
+
+	var err error
+	if policy.MinReplicas, err = parseCount(MinReplicasAnnotation); err != nil {
+		return nil, err
+	}
+	if policy.MaxReplicas, err = parseCount(MaxReplicasAnnotation); err != nil {
+		return nil, err
+	}
+	spare, err := parseCount(SpareHostsAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	if spare != nil {
+		policy.SpareHosts = *spare
+	}
+	if policy.MinReplicas != nil && policy.MaxReplicas != nil && *policy.MinReplicas > *policy.MaxReplicas {
+		return nil, fmt.Errorf("annotation %s must not be greater than %s", MinReplicasAnnotation, MaxReplicasAnnotation)
+	}
+
+	if value, ok := annotations[ScaleDownDelayAnnotation]; ok {
+		policy.ScaleDownDelay, err = time.ParseDuration(value)
+		if err != nil || policy.ScaleDownDelay < 0 {
+			return nil, fmt.Errorf("annotation %s must be a non-negative duration, not %q", ScaleDownDelayAnnotation, value)
+		}
+	}
+	_, policy.IncludePendingHosts = annotations[IncludePendingHostsAnnotation]
+	if value, ok := annotations[PriorityAnnotation]; ok {
+		priority, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s must be an integer, not %q", PriorityAnnotation, value)
+		}
+		policy.Priority = int32(priority)
+	}
+	return policy, nil
+}
+
+// Replicas returns the number of replicas for a MachineSet with the given
+// number of matching hosts. The spare hosts are subtracted first, then the
+// count is capped at MaxReplicas and raised to MinReplicas.
+func (p *AutoScalePolicy) Replicas(hosts int32) int32 {
+	replicas := hosts - p.SpareHosts
+	if replicas < 0 {
+		replicas = 0
+	}
+	if p.MaxReplicas != nil && replicas > *p.MaxReplicas {
+		replicas = *p.MaxReplicas
+	}
+	if p.MinReplicas != nil && replicas < *p.MinReplicas {
+		replicas = *p.MinReplicas
+	}
+	return replicas
+}
+
+// stabilize returns the number of replicas to scale ms to now, given the
+// number the policy wants, and how long to wait before checking again. A
+// scale down is held back until fewer replicas have been wanted for the
+// whole ScaleDownDelay; the time they were first wanted is recorded in an
+// annotation on ms.
+func (p *AutoScalePolicy) stabilize(ms *machinev1beta1.MachineSet, replicas int32, now time.Time) (int32, time.Duration) {
+	if ms.Spec.Replicas == nil || replicas >= *ms.Spec.Replicas || p.ScaleDownDelay == 0 {
+		delete(ms.Annotations, scaleDownSinceAnnotation)
+		return replicas, 0
+	}
+
+	since, err := time.Parse(scaleDownSinceFormat, ms.Annotations[scaleDownSinceAnnotation])
+	if err != nil {
+		// not waiting yet, or the annotation was mangled; start over
+		since = now
+		ms.Annotations[scaleDownSinceAnnotation] = now.Format(scaleDownSinceFormat)
+	}
+	if wait := since.Add(p.ScaleDownDelay).Sub(now); wait > 0 {
+		return *ms.Spec.Replicas, wait
+	}
+	delete(ms.Annotations, scaleDownSinceAnnotation)
+	return replicas, 0
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAutoScalePolicyFromAnnotations(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		Annotations   map[string]string
+		ExpectedError string
+	}{
+		{
+			Scenario:    "no policy",
+			Annotations: map[string]string{AutoScaleAnnotation: ""},
+		},
+		{
+			Scenario: "all annotations",
+			Annotations: map[string]string{
+				MinReplicasAnnotation:    "1",
+				MaxReplicasAnnotation:    "10",
+				SpareHostsAnnotation:     "2",
+				ScaleDownDelayAnnotation: "10m",
+				PriorityAnnotation:       "-5",
+			},
+		},
+		{
+			Scenario:      "negative count",
+			Annotations:   map[string]string{SpareHostsAnnotation: "-1"},
+			ExpectedError: "metal3.io/autoscale-spare-hosts must be a non-negative integer",
+		},
+		{
+			Scenario:      "not a count",
+			Annotations:   map[string]string{MaxReplicasAnnotation: "lots"},
+			ExpectedError: "metal3.io/autoscale-max-replicas must be a non-negative integer",
+		},
+		{
+			Scenario:      "min greater than max",
+			Annotations:   map[string]string{MinReplicasAnnotation: "3", MaxReplicasAnnotation: "2"},
+			ExpectedError: "must not be greater than",
+		},
+		{
+			Scenario:      "not a duration",
+			Annotations:   map[string]string{ScaleDownDelayAnnotation: "10"},
+			ExpectedError: "metal3.io/autoscale-scale-down-delay must be a non-negative duration",
+		},
+		{
+			Scenario:      "not a priority",
+			Annotations:   map[string]string{PriorityAnnotation: "high"},
+			ExpectedError: "metal3.io/autoscale-priority must be an integer",
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := AutoScalePolicyFromAnnotations(tc.Annotations)
+		switch {
+		case tc.ExpectedError == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.Scenario, err)
+		case tc.ExpectedError != "" && err == nil:
+			t.Errorf("%s: expected error %q", tc.Scenario, tc.ExpectedError)
+		case tc.ExpectedError != "" && !strings.Contains(err.Error(), tc.ExpectedError):
+			t.Errorf("%s: expected error %q, got %v", tc.Scenario, tc.ExpectedError, err)
+		}
+	}
+}
+
+func TestAutoScalePolicyReplicas(t *testing.T) {
+	one, four := int32(1), int32(4)
+	testCases := []struct {
+		Scenario string
+		Policy   AutoScalePolicy
+		Hosts    int32
+		Expected int32
+	}{
+		{Scenario: "no policy", Hosts: 3, Expected: 3},
+		{Scenario: "spare hosts", Policy: AutoScalePolicy{SpareHosts: 2}, Hosts: 5, Expected: 3},
+		{Scenario: "only spare hosts", Policy: AutoScalePolicy{SpareHosts: 2}, Hosts: 1, Expected: 0},
+		{Scenario: "maximum", Policy: AutoScalePolicy{MaxReplicas: &four, SpareHosts: 2}, Hosts: 10, Expected: 4},
+		{Scenario: "minimum", Policy: AutoScalePolicy{MinReplicas: &one, SpareHosts: 2}, Hosts: 2, Expected: 1},
+		{Scenario: "within bounds", Policy: AutoScalePolicy{MinReplicas: &one, MaxReplicas: &four}, Hosts: 3, Expected: 3},
+	}
+
+	for _, tc := range testCases {
+		if replicas := tc.Policy.Replicas(tc.Hosts); replicas != tc.Expected {
+			t.Errorf("%s: expected %d replicas, got %d", tc.Scenario, tc.Expected, replicas)
+		}
+	}
+}
+
+func TestAutoScalePolicyStabilize(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	policy := AutoScalePolicy{ScaleDownDelay: 10 * time.Minute}
+	three := int32(3)
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+		Spec:       machinev1beta1.MachineSetSpec{Replicas: &three},
+	}
+
+	// The first time fewer replicas are wanted, the scale down is delayed
+	replicas, wait := policy.stabilize(ms, 2, now)
+	if replicas != 3 || wait != 10*time.Minute {
+		t.Errorf("expected to wait 10m with 3 replicas, got %d replicas and wait %v", replicas, wait)
+	}
+	if _, ok := ms.Annotations[scaleDownSinceAnnotation]; !ok {
+		t.Errorf("expected %s to be set", scaleDownSinceAnnotation)
+	}
+
+	// Part way through the delay, keep waiting for the rest of it
+	replicas, wait = policy.stabilize(ms, 2, now.Add(4*time.Minute))
+	if replicas != 3 || wait != 6*time.Minute {
+		t.Errorf("expected to wait 6m with 3 replicas, got %d replicas and wait %v", replicas, wait)
+	}
+
+	// A host flapping back into the MachineSet resets the delay
+	replicas, wait = policy.stabilize(ms, 3, now.Add(5*time.Minute))
+	if replicas != 3 || wait != 0 {
+		t.Errorf("expected 3 replicas without waiting, got %d replicas and wait %v", replicas, wait)
+	}
+	if _, ok := ms.Annotations[scaleDownSinceAnnotation]; ok {
+		t.Errorf("expected %s to be removed", scaleDownSinceAnnotation)
+	}
+
+	// Once fewer replicas have been wanted for the whole delay, scale down
+	policy.stabilize(ms, 2, now.Add(6*time.Minute))
+	replicas, wait = policy.stabilize(ms, 1, now.Add(16*time.Minute))
+	if replicas != 1 || wait != 0 {
+		t.Errorf("expected 1 replica without waiting, got %d replicas and wait %v", replicas, wait)
+	}
+	if _, ok := ms.Annotations[scaleDownSinceAnnotation]; ok {
+		t.Errorf("expected %s to be removed", scaleDownSinceAnnotation)
+	}
+}
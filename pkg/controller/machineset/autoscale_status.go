@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AutoScaleStatusAnnotation is the key for the annotation in which the
+// controller records the AutoScaleStatus of an autoscaled MachineSet, as JSON.
+const AutoScaleStatusAnnotation = "metal3.io/autoscale-status"
+
+// Reasons autoscaling can be blocked, and of the events recorded about it.
+const (
+	// BlockedEmptySelector means the MachineSet has an empty selector, so
+	// the hosts of its Machines cannot be told apart.
+	BlockedEmptySelector = "EmptySelector"
+	// BlockedInvalidAnnotations means the autoscaling annotations of the
+	// MachineSet cannot be read.
+	BlockedInvalidAnnotations = "InvalidAnnotations"
+	// BlockedConsumerNotFound means a BareMetalHost is consumed by a Machine
+	// or HostClaim that cannot be found, so it is not known whether it
+	// belongs to the MachineSet.
+	BlockedConsumerNotFound = "ConsumerNotFound"
+
+	autoScaleBlockedEvent   = "AutoScaleBlocked"
+	autoScaleUnblockedEvent = "AutoScaleUnblocked"
+	autoScaledEvent         = "AutoScaled"
+	autoScaleOverlapEvent   = "AutoScaleOverlap"
+)
+
+// AutoScaleStatus describes the last time an autoscaled MachineSet was
+// reconciled.
+type AutoScaleStatus struct {
+	// MatchedHosts is the number of BareMetalHosts matching the
+	// hostSelector, whatever their state.
+	MatchedHosts int32 `json:"matchedHosts"`
+	// CountedHosts is the number of BareMetalHosts the replicas were
+	// computed from: those a new Machine could claim and those claimed by
+	// Machines of the MachineSet.
+	CountedHosts int32 `json:"countedHosts"`
+	// Blocked is the reason the MachineSet is not being scaled, if any.
+	Blocked string `json:"blocked,omitempty"`
+	// Message describes why the MachineSet is not being scaled.
+	Message string `json:"message,omitempty"`
+	// Host is the namespace/name of the BareMetalHost that blocks scaling,
+	// if there is one.
+	Host string `json:"host,omitempty"`
+	// OverlappingMachineSets are the names of the other autoscaled
+	// MachineSets whose new Machines could claim some of the same free
+	// hosts.
+	OverlappingMachineSets []string `json:"overlappingMachineSets,omitempty"`
+	// SharedHosts is the number of free hosts that the Machines of the
+	// OverlappingMachineSets could claim too. Those are only counted by
+	// the MachineSet with the highest priority.
+	SharedHosts int32 `json:"sharedHosts,omitempty"`
+}
+
+// blockedError is returned when a MachineSet cannot be scaled until its
+// owner fixes something.
+type blockedError struct {
+	reason  string
+	host    string
+	message string
+}
+
+func (e *blockedError) Error() string {
+	return e.message
+}
+
+// autoScaleStatus returns the AutoScaleStatus recorded on the MachineSet, or
+// nil if there is none or it cannot be read.
+func autoScaleStatus(ms *machinev1beta1.MachineSet) *AutoScaleStatus {
+	value, ok := ms.Annotations[AutoScaleStatusAnnotation]
+	if !ok {
+		return nil
+	}
+	status := &AutoScaleStatus{}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return nil
+	}
+	return status
+}
+
+// setAutoScaleStatus records the status on the MachineSet, or removes the
+// annotation if status is nil.
+func setAutoScaleStatus(ms *machinev1beta1.MachineSet, status *AutoScaleStatus) {
+	if status == nil {
+		delete(ms.Annotations, AutoScaleStatusAnnotation)
+		return
+	}
+	raw, err := json.Marshal(status)
+	if err != nil {
+		// cannot happen with a struct of strings and integers
+		return
+	}
+	if ms.Annotations == nil {
+		ms.Annotations = map[string]string{}
+	}
+	ms.Annotations[AutoScaleStatusAnnotation] = string(raw)
+}
+
+// recordStatusEvents emits an event when the MachineSet becomes blocked, or
+// is blocked for a different reason or host, and when it is unblocked. It
+// also warns when the MachineSet starts overlapping with others.
+func (r *ReconcileMachineSet) recordStatusEvents(ms *machinev1beta1.MachineSet, old, status *AutoScaleStatus) {
+	var oldOverlaps []string
+	if old != nil {
+		oldOverlaps = old.OverlappingMachineSets
+	}
+	if len(status.OverlappingMachineSets) > 0 && !slices.Equal(oldOverlaps, status.OverlappingMachineSets) {
+		r.recordEvent(ms, corev1.EventTypeWarning, autoScaleOverlapEvent,
+			fmt.Sprintf("%d free hosts are shared with MachineSets %s", status.SharedHosts,
+				strings.Join(status.OverlappingMachineSets, ", ")))
+	}
+
+	wasBlocked := old != nil && old.Blocked != ""
+	switch {
+	case status.Blocked != "":
+		if wasBlocked && old.Blocked == status.Blocked && old.Host == status.Host {
+			return
+		}
+		r.recordEvent(ms, corev1.EventTypeWarning, autoScaleBlockedEvent,
+			fmt.Sprintf("Will not scale to hosts: %s", status.Message))
+	case wasBlocked:
+		r.recordEvent(ms, corev1.EventTypeNormal, autoScaleUnblockedEvent,
+			fmt.Sprintf("Scaling to hosts resumed after %s", old.Blocked))
+	}
+}
+
+// recordEvent emits an event for the MachineSet, if the reconciler was given
+// an EventRecorder.
+func (r *ReconcileMachineSet) recordEvent(ms *machinev1beta1.MachineSet, eventType, reason, message string) {
+	if r.eventRecorder == nil {
+		return
+	}
+	r.eventRecorder.Event(ms, eventType, reason, message)
+}
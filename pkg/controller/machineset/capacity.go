@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CPUAnnotation is the machine-api annotation with the number of CPUs
+	// of a Machine of the MachineSet, used by the cluster autoscaler to
+	// scale it from zero.
+	CPUAnnotation = "machine.openshift.io/vCPU"
+
+	// MemoryAnnotation is the machine-api annotation with the memory, in
+	// MiB, of a Machine of the MachineSet.
+	MemoryAnnotation = "machine.openshift.io/memoryMb"
+
+	// LabelsAnnotation is the cluster autoscaler annotation with the labels
+	// the Nodes of the MachineSet will have, as comma separated key=value
+	// pairs. The controller only manages the architecture label in it.
+	LabelsAnnotation = "capacity.cluster-autoscaler.kubernetes.io/labels"
+
+	// CapacityOwnerAnnotation lists, comma separated, the capacity
+	// annotations and Node labels the controller set from the hosts. The
+	// others were set by someone else, and are left alone.
+	CapacityOwnerAnnotation = "metal3.io/capacity-annotations"
+)
+
+// nodeArchitectures maps the CPU architectures reported by inspection to
+// the values of the kubernetes.io/arch Node label.
+var nodeArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+}
+
+// hostCapacity is what every inspected host of a MachineSet provides.
+type hostCapacity struct {
+	cpus      int
+	memoryMiB int
+	arch      string
+}
+
+// capacityOfHosts returns the smallest number of CPUs and amount of memory
+// among the inspected hosts, and their architecture if they all share one.
+// It returns nil if none of the hosts have been inspected.
+func capacityOfHosts(hosts []*bmh.BareMetalHost) *hostCapacity {
+	var capacity *hostCapacity
+	for _, host := range hosts {
+		hardware := host.Status.HardwareDetails
+		if hardware == nil || hardware.CPU.Count == 0 || hardware.RAMMebibytes == 0 {
+			// not inspected yet
+			continue
+		}
+		arch := hardware.CPU.Arch
+		if nodeArch, ok := nodeArchitectures[arch]; ok {
+			arch = nodeArch
+		}
+		if capacity == nil {
+			capacity = &hostCapacity{cpus: hardware.CPU.Count, memoryMiB: hardware.RAMMebibytes, arch: arch}
+			continue
+		}
+		capacity.cpus = min(capacity.cpus, hardware.CPU.Count)
+		capacity.memoryMiB = min(capacity.memoryMiB, hardware.RAMMebibytes)
+		if capacity.arch != arch {
+			// mixed architectures, so none can be promised
+			capacity.arch = ""
+		}
+	}
+	return capacity
+}
+
+// setCapacityAnnotations updates the capacity annotations of the MachineSet
+// to match the hosts. Only the annotations and the architecture label that
+// are unset or were set by the controller are changed, and they are recorded
+// in the CapacityOwnerAnnotation before they are. Nothing is changed if no
+// host was inspected.
+func setCapacityAnnotations(ms *machinev1beta1.MachineSet, hosts []*bmh.BareMetalHost) {
+	capacity := capacityOfHosts(hosts)
+	if capacity == nil {
+		return
+	}
+	if ms.Annotations == nil {
+		ms.Annotations = map[string]string{}
+	}
+
+	owned := map[string]bool{}
+	for _, key := range strings.Split(ms.Annotations[CapacityOwnerAnnotation], ",") {
+		if key != "" {
+			owned[key] = true
+		}
+	}
+
+	for key, value := range map[string]int{CPUAnnotation: capacity.cpus, MemoryAnnotation: capacity.memoryMiB} {
+		if _, set := ms.Annotations[key]; set && !owned[key] {
+			continue
+		}
+		owned[key] = true
+		ms.Annotations[key] = strconv.Itoa(value)
+	}
+
+	nodeLabels := map[string]string{}
+	for _, pair := range strings.Split(ms.Annotations[LabelsAnnotation], ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			nodeLabels[key] = value
+		}
+	}
+	if _, set := nodeLabels[corev1.LabelArchStable]; !set || owned[corev1.LabelArchStable] {
+		if capacity.arch == "" {
+			delete(nodeLabels, corev1.LabelArchStable)
+			delete(owned, corev1.LabelArchStable)
+		} else {
+			nodeLabels[corev1.LabelArchStable] = capacity.arch
+			owned[corev1.LabelArchStable] = true
+		}
+	}
+	ms.Annotations[CapacityOwnerAnnotation] = strings.Join(slices.Sorted(maps.Keys(owned)), ",")
+
+	if len(nodeLabels) == 0 {
+		delete(ms.Annotations, LabelsAnnotation)
+		return
+	}
+	pairs := make([]string, 0, len(nodeLabels))
+	for key, value := range nodeLabels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	ms.Annotations[LabelsAnnotation] = strings.Join(pairs, ",")
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"reflect"
+	"testing"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+)
+
+func hostWithCapacity(arch string, cpus, memoryMiB int) *bmh.BareMetalHost {
+	host := &bmh.BareMetalHost{}
+	if cpus != 0 {
+		host.Status.HardwareDetails = &bmh.HardwareDetails{
+			CPU:          bmh.CPU{Arch: arch, Count: cpus},
+			RAMMebibytes: memoryMiB,
+		}
+	}
+	return host
+}
+
+func TestSetCapacityAnnotations(t *testing.T) {
+	testCases := []struct {
+		Scenario    string
+		Hosts       []*bmh.BareMetalHost
+		Annotations map[string]string
+		Expected    map[string]string
+	}{
+		{
+			Scenario: "smallest host",
+			Hosts: []*bmh.BareMetalHost{
+				hostWithCapacity("x86_64", 64, 262144),
+				hostWithCapacity("x86_64", 96, 131072),
+				hostWithCapacity("", 0, 0),
+			},
+			Expected: map[string]string{
+				CPUAnnotation:           "64",
+				MemoryAnnotation:        "131072",
+				LabelsAnnotation:        "kubernetes.io/arch=amd64",
+				CapacityOwnerAnnotation: "kubernetes.io/arch,machine.openshift.io/memoryMb,machine.openshift.io/vCPU",
+			},
+		},
+		{
+			Scenario: "mixed architectures",
+			Hosts: []*bmh.BareMetalHost{
+				hostWithCapacity("x86_64", 64, 262144),
+				hostWithCapacity("aarch64", 80, 262144),
+			},
+			Annotations: map[string]string{
+				AutoScaleAnnotation:     "",
+				LabelsAnnotation:        "kubernetes.io/arch=amd64,node-role.kubernetes.io/worker=",
+				CapacityOwnerAnnotation: "kubernetes.io/arch",
+			},
+			Expected: map[string]string{
+				AutoScaleAnnotation:     "",
+				CPUAnnotation:           "64",
+				MemoryAnnotation:        "262144",
+				LabelsAnnotation:        "node-role.kubernetes.io/worker=",
+				CapacityOwnerAnnotation: "machine.openshift.io/memoryMb,machine.openshift.io/vCPU",
+			},
+		},
+		{
+			Scenario: "other labels kept",
+			Hosts:    []*bmh.BareMetalHost{hostWithCapacity("aarch64", 80, 262144)},
+			Annotations: map[string]string{
+				LabelsAnnotation: "node-role.kubernetes.io/worker=",
+			},
+			Expected: map[string]string{
+				CPUAnnotation:           "80",
+				MemoryAnnotation:        "262144",
+				LabelsAnnotation:        "kubernetes.io/arch=arm64,node-role.kubernetes.io/worker=",
+				CapacityOwnerAnnotation: "kubernetes.io/arch,machine.openshift.io/memoryMb,machine.openshift.io/vCPU",
+			},
+		},
+		{
+			Scenario: "values set by others kept",
+			Hosts:    []*bmh.BareMetalHost{hostWithCapacity("aarch64", 80, 262144)},
+			Annotations: map[string]string{
+				CPUAnnotation:    "16",
+				LabelsAnnotation: "node-role.kubernetes.io/worker=,kubernetes.io/arch=amd64",
+			},
+			Expected: map[string]string{
+				CPUAnnotation:           "16",
+				MemoryAnnotation:        "262144",
+				LabelsAnnotation:        "kubernetes.io/arch=amd64,node-role.kubernetes.io/worker=",
+				CapacityOwnerAnnotation: "machine.openshift.io/memoryMb",
+			},
+		},
+		{
+			Scenario: "no inspected hosts",
+			Hosts:    []*bmh.BareMetalHost{hostWithCapacity("", 0, 0)},
+			Annotations: map[string]string{
+				CPUAnnotation:           "64",
+				MemoryAnnotation:        "131072",
+				LabelsAnnotation:        "kubernetes.io/arch=amd64",
+				CapacityOwnerAnnotation: "kubernetes.io/arch,machine.openshift.io/memoryMb,machine.openshift.io/vCPU",
+			},
+			Expected: map[string]string{
+				CPUAnnotation:           "64",
+				MemoryAnnotation:        "131072",
+				LabelsAnnotation:        "kubernetes.io/arch=amd64",
+				CapacityOwnerAnnotation: "kubernetes.io/arch,machine.openshift.io/memoryMb,machine.openshift.io/vCPU",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		ms := &machinev1beta1.MachineSet{}
+		ms.Annotations = tc.Annotations
+		setCapacityAnnotations(ms, tc.Hosts)
+		if !reflect.DeepEqual(tc.Expected, ms.Annotations) {
+			t.Errorf("%s: expected annotations %v, got %v", tc.Scenario, tc.Expected, ms.Annotations)
+		}
+	}
+}
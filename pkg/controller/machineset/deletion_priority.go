@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/baremetal"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DeleteMachineAnnotation is the machine-api annotation that marks a
+	// Machine to be deleted first when its MachineSet is scaled down.
+	DeleteMachineAnnotation = "machine.openshift.io/delete-machine"
+
+	// DeleteMachineReasonAnnotation is the key for the annotation in which
+	// the controller records why it set the DeleteMachineAnnotation on a
+	// Machine.
+	DeleteMachineReasonAnnotation = "metal3.io/delete-machine-reason"
+
+	// QuarantinedHostAnnotation is the key for an annotation that, when
+	// added to a BareMetalHost with any value, marks its hardware as
+	// suspect. Its Machine is deleted first when its MachineSet is
+	// autoscaled down. Remediations ending with the Quarantine step set it.
+	QuarantinedHostAnnotation = baremetal.QuarantinedHostAnnotation
+
+	// MaintenanceHostAnnotation is the key for an annotation that, when
+	// added to a BareMetalHost with any value, marks it as due for
+	// maintenance. Its Machine is deleted first when its MachineSet is
+	// autoscaled down, after those with worse hosts.
+	MaintenanceHostAnnotation = "metal3.io/maintenance"
+
+	deletionPrioritizedEvent = "DeletionPrioritized"
+)
+
+// hostPriority orders the reasons to delete a Machine before the others,
+// from the least to the most urgent.
+type hostPriority int
+
+const (
+	priorityNone hostPriority = iota
+	priorityRemediated
+	priorityMaintenance
+	priorityError
+	priorityQuarantined
+	priorityHostGone
+)
+
+// deletionCandidate is a Machine of a MachineSet, with how urgently its host
+// should be given up.
+type deletionCandidate struct {
+	machine      *machinev1beta1.Machine
+	priority     hostPriority
+	remediations int
+	reason       string
+}
+
+// prioritizeDeletion marks the Machines of the MachineSet with the worst
+// hosts with the DeleteMachineAnnotation, so that they are deleted first when
+// it is scaled down to replicas. Only Machines whose hosts are gone,
+// quarantined, in error, due for maintenance or were remediated are marked;
+// the machine-api chooses among the others. The marks set here carry the
+// DeleteMachineReasonAnnotation, and are removed again once their Machine no
+// longer qualifies or is no longer in excess. Marks set by others are kept.
+func (r *ReconcileMachineSet) prioritizeDeletion(ctx context.Context, ms *machinev1beta1.MachineSet,
+	msselector labels.Selector, replicas int32) error {
+	machines := machinev1beta1.MachineList{}
+	err := r.List(ctx, &machines, client.InNamespace(ms.Namespace), client.MatchingLabelsSelector{Selector: msselector})
+	if err != nil {
+		return err
+	}
+
+	remediations, err := r.remediationCounts(ctx, ms.Namespace)
+	if err != nil {
+		return err
+	}
+
+	excess := 0
+	candidates := []*deletionCandidate{}
+	marked := []*machinev1beta1.Machine{}
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.DeletionTimestamp != nil {
+			// already going away, and no longer counted by the machine-api
+			continue
+		}
+		excess++
+		if _, ok := machine.Annotations[DeleteMachineAnnotation]; ok {
+			if _, ours := machine.Annotations[DeleteMachineReasonAnnotation]; !ours {
+				// marked by someone else, will be deleted first anyway
+				excess--
+				continue
+			}
+			marked = append(marked, machine)
+		}
+		candidate, err := r.deletionCandidate(ctx, machine, remediations[machine.Name])
+		if err != nil {
+			return err
+		}
+		if candidate.priority != priorityNone {
+			candidates = append(candidates, candidate)
+		}
+	}
+	excess -= int(replicas)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.priority != cj.priority {
+			return ci.priority > cj.priority
+		}
+		if ci.remediations != cj.remediations {
+			return ci.remediations > cj.remediations
+		}
+		return ci.machine.Name < cj.machine.Name
+	})
+	candidates = candidates[:max(0, min(excess, len(candidates)))]
+
+	chosen := map[string]bool{}
+	for _, candidate := range candidates {
+		machine := candidate.machine
+		chosen[machine.Name] = true
+		if machine.Annotations[DeleteMachineReasonAnnotation] == candidate.reason {
+			continue
+		}
+		original := machine.DeepCopy()
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[DeleteMachineAnnotation] = "true"
+		machine.Annotations[DeleteMachineReasonAnnotation] = candidate.reason
+		err := r.Patch(ctx, machine, client.MergeFrom(original))
+		if err != nil {
+			return err
+		}
+		r.recordEvent(ms, corev1.EventTypeNormal, deletionPrioritizedEvent,
+			fmt.Sprintf("Machine %s will be deleted first: %s", machine.Name, candidate.reason))
+	}
+
+	for _, machine := range marked {
+		if chosen[machine.Name] {
+			continue
+		}
+		original := machine.DeepCopy()
+		delete(machine.Annotations, DeleteMachineAnnotation)
+		delete(machine.Annotations, DeleteMachineReasonAnnotation)
+		err := r.Patch(ctx, machine, client.MergeFrom(original))
+		if err != nil {
+			return err
+		}
+		r.recordEvent(ms, corev1.EventTypeNormal, deletionPrioritizedEvent,
+			fmt.Sprintf("Machine %s is no longer deleted first", machine.Name))
+	}
+	return nil
+}
+
+// deletionCandidate returns how urgently the Machine should be deleted, based
+// on its BareMetalHost and the number of times it was remediated.
+func (r *ReconcileMachineSet) deletionCandidate(ctx context.Context, machine *machinev1beta1.Machine,
+	remediations int) (*deletionCandidate, error) {
+	candidate := &deletionCandidate{machine: machine, remediations: remediations}
+
+	key, err := actuator.HostKey(machine)
+	if err != nil || key == nil {
+		// no host yet, or an unreadable reference the actuator will
+		// report on the Machine
+		return candidate, nil
+	}
+	host := &bmh.BareMetalHost{}
+	err = r.Get(ctx, *key, host)
+	switch {
+	case errors.IsNotFound(err):
+		candidate.priority = priorityHostGone
+		candidate.reason = fmt.Sprintf("BareMetalHost %s is gone", key)
+		return candidate, nil
+	case err != nil:
+		return nil, err
+	}
+
+	_, quarantined := host.Annotations[QuarantinedHostAnnotation]
+	_, maintenance := host.Annotations[MaintenanceHostAnnotation]
+	switch {
+	case host.DeletionTimestamp != nil:
+		candidate.priority = priorityHostGone
+		candidate.reason = fmt.Sprintf("BareMetalHost %s is being deleted", key)
+	case quarantined:
+		candidate.priority = priorityQuarantined
+		candidate.reason = fmt.Sprintf("BareMetalHost %s is quarantined", key)
+	case host.Status.ErrorMessage != "":
+		candidate.priority = priorityError
+		candidate.reason = fmt.Sprintf("BareMetalHost %s has an error: %s", key, host.Status.ErrorMessage)
+	case maintenance:
+		candidate.priority = priorityMaintenance
+		candidate.reason = fmt.Sprintf("BareMetalHost %s is due for maintenance", key)
+	case remediations > 0:
+		candidate.priority = priorityRemediated
+		candidate.reason = fmt.Sprintf("BareMetalHost %s was remediated %d times", key, remediations)
+	}
+	return candidate, nil
+}
+
+// remediationCounts returns the retry counts of the Metal3Remediations in
+// the namespace, by the name of the Machine they remediate.
+func (r *ReconcileMachineSet) remediationCounts(ctx context.Context, namespace string) (map[string]int, error) {
+	counts := map[string]int{}
+	remediations := infrav1.Metal3RemediationList{}
+	err := r.List(ctx, &remediations, client.InNamespace(namespace))
+	switch {
+	case runtime.IsNotRegisteredError(err):
+		// Metal3Remediations are not in the scheme of this manager
+		return counts, nil
+	case err != nil:
+		return nil, err
+	}
+	for _, remediation := range remediations.Items {
+		for _, owner := range remediation.OwnerReferences {
+			if owner.Kind == "Machine" {
+				counts[owner.Name] = remediation.Status.RetryCount
+			}
+		}
+	}
+	return counts, nil
+}
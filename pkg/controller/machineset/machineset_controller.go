@@ -18,17 +18,22 @@ package machineset
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -47,6 +52,10 @@ var log = logf.Log.WithName("machineset-controller")
 // want to do any scaling until it gets resolved.
 var errConsumerNotFound = fmt.Errorf("consuming Machine not found")
 
+// blockedRequeueAfter is how long to wait before checking whether the
+// consumer of a BareMetalHost that was not found exists now.
+const blockedRequeueAfter = time.Minute
+
 // Add creates a new MachineSet Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
@@ -55,7 +64,11 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileMachineSet{Client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileMachineSet{
+		Client:        mgr.GetClient(),
+		scheme:        mgr.GetScheme(),
+		eventRecorder: mgr.GetEventRecorderFor("machineset-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -78,6 +91,13 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	if err != nil {
 		return err
 	}
+	// Autoscaled MachineSets split the free hosts they share, so the others
+	// count their hosts again when one changes
+	err = c.Watch(source.Kind(mgr.GetCache(), &machinev1beta1.MachineSet{},
+		handler.TypedEnqueueRequestsFromMapFunc(mapper.MapPeers), autoScalePeerPredicate{}))
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -87,7 +107,8 @@ var _ reconcile.Reconciler = &ReconcileMachineSet{}
 // ReconcileMachineSet reconciles a MachineSet object
 type ReconcileMachineSet struct {
 	client.Client
-	scheme *runtime.Scheme
+	scheme        *runtime.Scheme
+	eventRecorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a MachineSet object and makes changes based on the state read
@@ -109,78 +130,229 @@ func (r *ReconcileMachineSet) Reconcile(ctx context.Context, request reconcile.R
 		return reconcile.Result{}, err
 	}
 
-	// Don't take action if the annotation is not present
-	annotations := instance.ObjectMeta.GetAnnotations()
-	if annotations == nil {
+	// Leave the MachineSets of other providers alone
+	if !actuator.IsBareMetalProviderSpec(&instance.Spec.Template.Spec.ProviderSpec) {
 		return reconcile.Result{}, nil
 	}
-	_, present := annotations[AutoScaleAnnotation]
-	if !present {
-		return reconcile.Result{}, nil
+	_, autoscale := instance.Annotations[AutoScaleAnnotation]
+
+	new := instance.DeepCopy()
+	status := &AutoScaleStatus{}
+	wait, err := r.scale(ctx, new, autoscale, status)
+	var blocked *blockedError
+	switch {
+	case goerrors.As(err, &blocked):
+		log.Info("Will not scale MachineSet", "reason", blocked.reason, "message", blocked.message)
+		status.Blocked = blocked.reason
+		status.Message = blocked.message
+		status.Host = blocked.host
+		if blocked.reason == BlockedConsumerNotFound {
+			// Machines are not watched, so check again for the consumer
+			wait = blockedRequeueAfter
+		}
+	case err != nil:
+		return reconcile.Result{}, err
 	}
 
-	// Make sure the MachineSet has a non-empty selector.
-	msselector, err := metav1.LabelSelectorAsSelector(&instance.Spec.Selector)
+	if autoscale {
+		r.recordStatusEvents(instance, autoScaleStatus(instance), status)
+		setAutoScaleStatus(new, status)
+	} else {
+		setAutoScaleStatus(new, nil)
+	}
+	if equality.Semantic.DeepEqual(instance, new) {
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+	// Only the changed annotations and replicas are written. The replicas
+	// are computed from what was read, so they are only written to that.
+	opts := []client.MergeFromOption{}
+	if !equality.Semantic.DeepEqual(instance.Spec.Replicas, new.Spec.Replicas) {
+		opts = append(opts, client.MergeFromWithOptimisticLock{})
+	}
+	err = r.Patch(ctx, new, client.MergeFromWithOptions(instance, opts...))
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if !equality.Semantic.DeepEqual(instance.Spec.Replicas, new.Spec.Replicas) {
+		old := int32(0)
+		if instance.Spec.Replicas != nil {
+			old = *instance.Spec.Replicas
+		}
+		r.recordEvent(instance, corev1.EventTypeNormal, autoScaledEvent,
+			fmt.Sprintf("Scaled from %d to %d replicas with %d hosts", old, *new.Spec.Replicas, status.CountedHosts))
+	}
+	return reconcile.Result{RequeueAfter: wait}, nil
+}
+
+// scale updates the capacity annotations of the MachineSet and, if it is
+// autoscaled, its replicas, and fills in the counts of the status. It
+// returns how long to wait before reconciling again to scale down, and a
+// *blockedError if the MachineSet cannot be scaled until its owner fixes it.
+func (r *ReconcileMachineSet) scale(ctx context.Context, ms *machinev1beta1.MachineSet, autoscale bool,
+	status *AutoScaleStatus) (time.Duration, error) {
+	log := log.WithValues("MachineSet", client.ObjectKeyFromObject(ms).String())
+
+	// Make sure the MachineSet has a non-empty selector.
+	msselector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
 	if msselector.Empty() {
 		// The cluster-api machinesetcontroller expects every MachineSet to have
 		// its Selector set.
-		log.Info("MachineSet has empty selector, which is unexpected. Will not attempt scaling.")
-		return reconcile.Result{}, nil
+		return 0, &blockedError{
+			reason:  BlockedEmptySelector,
+			message: "MachineSet has an empty selector, so the hosts of its Machines cannot be found",
+		}
 	}
 
-	hostselector, err := actuator.SelectorFromProviderSpec(&instance.Spec.Template.Spec.ProviderSpec)
+	policy, err := AutoScalePolicyFromAnnotations(ms.Annotations)
 	if err != nil {
-		return reconcile.Result{}, err
+		// The MachineSet will be reconciled again when its annotations are
+		// fixed.
+		return 0, &blockedError{reason: BlockedInvalidAnnotations, message: err.Error()}
 	}
 
-	hosts := &bmh.BareMetalHostList{}
-	opts := &client.ListOptions{
-		Namespace: instance.Namespace,
+	hosts, err := r.matchingHosts(ctx, ms, msselector, policy, status)
+	if err != nil {
+		return 0, err
+	}
+	status.CountedHosts = int32(len(hosts))
+
+	// The capacity annotations let the cluster autoscaler scale the
+	// MachineSet from zero.
+	setCapacityAnnotations(ms, hosts)
+	if !autoscale {
+		return 0, nil
 	}
 
-	err = r.List(ctx, hosts, opts)
+	count := int32(len(hosts))
+	replicas, wait := policy.stabilize(ms, policy.Replicas(count), time.Now())
+	if wait > 0 {
+		log.Info("Waiting to scale down MachineSet", "hosts", count, "replicas", replicas, "wait", wait)
+	}
+	// Mark the Machines to delete before the machine-api picks them
+	// without knowing about their hosts, and unmark them once they are no
+	// longer in excess
+	err = r.prioritizeDeletion(ctx, ms, msselector, replicas)
 	if err != nil {
-		return reconcile.Result{}, err
+		return 0, err
+	}
+	if ms.Spec.Replicas == nil || replicas != *ms.Spec.Replicas {
+		log.Info("Scaling MachineSet", "hosts", count, "new_replicas", replicas, "old_replicas", ms.Spec.Replicas)
+		ms.Spec.Replicas = &replicas
 	}
+	return wait, nil
+}
 
-	var count int32
-	for i := range hosts.Items {
-		matches, err := r.hostMatches(ctx, hostselector, msselector, &hosts.Items[i])
-		switch {
-		case err == errConsumerNotFound:
-			log.Info("Will not scale while BareMetalHost's consuming Machine is not found", "BareMetalHost.Name", &hosts.Items[i].Name)
-			return reconcile.Result{}, nil
-		case err != nil:
-			return reconcile.Result{}, err
-		case matches == true:
-			count++
-		}
+// CountHosts returns the number of replicas that the MachineSet would be
+// scaled to if it had the AutoScaleAnnotation, ignoring any scale down delay.
+func CountHosts(ctx context.Context, c client.Client, ms *machinev1beta1.MachineSet) (int32, error) {
+	policy, err := AutoScalePolicyFromAnnotations(ms.Annotations)
+	if err != nil {
+		return 0, err
+	}
+	msselector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+	r := &ReconcileMachineSet{Client: c}
+	hosts, err := r.matchingHosts(ctx, ms, msselector, policy, &AutoScaleStatus{})
+	if err != nil {
+		return 0, err
 	}
+	return policy.Replicas(int32(len(hosts))), nil
+}
 
-	if instance.Spec.Replicas == nil || count != *instance.Spec.Replicas {
-		log.Info("Scaling MachineSet", "new_replicas", count, "old_replicas", instance.Spec.Replicas)
-		new := instance.DeepCopy()
-		new.Spec.Replicas = &count
-		err = r.Update(ctx, new)
-		if err != nil {
-			return reconcile.Result{}, err
+// matchingHosts returns the BareMetalHosts that match the MachineSet, and
+// records in the status how many hosts match its hostSelector whatever their
+// state, and which free hosts it shares with other autoscaled MachineSets.
+// Shared hosts are left out if another MachineSet outranks this one. If a
+// host is consumed by a Machine that cannot be found, a *blockedError is
+// returned.
+func (r *ReconcileMachineSet) matchingHosts(ctx context.Context, instance *machinev1beta1.MachineSet,
+	msselector labels.Selector, policy *AutoScalePolicy, status *AutoScaleStatus) ([]*bmh.BareMetalHost, error) {
+	hostmatcher, err := actuator.HostMatcherFromProviderSpec(&instance.Spec.Template.Spec.ProviderSpec)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := actuator.NewMachineSetHostFilter(instance, policy.IncludePendingHosts)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := overlapPeers(ctx, r, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := actuator.ListHosts(ctx, r, &instance.Spec.Template.Spec.ProviderSpec, instance.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		if hostmatcher.Matches(&hosts[i]) {
+			status.MatchedHosts++
 		}
 	}
 
-	return reconcile.Result{}, nil
+	matching := []*bmh.BareMetalHost{}
+	for i := range hosts {
+		matches, err := r.hostMatches(ctx, filter, msselector, &hosts[i])
+		switch {
+		case err == errConsumerNotFound:
+			consumer := hosts[i].Spec.ConsumerRef
+			return nil, &blockedError{
+				reason: BlockedConsumerNotFound,
+				host:   client.ObjectKeyFromObject(&hosts[i]).String(),
+				message: fmt.Sprintf("BareMetalHost %s/%s is consumed by %s %s/%s, which is not found",
+					hosts[i].Namespace, hosts[i].Name, consumer.Kind, consumer.Namespace, consumer.Name),
+			}
+		case err != nil:
+			return nil, err
+		case !matches:
+		case hosts[i].Spec.ConsumerRef == nil && shareHost(instance, policy, peers, &hosts[i], status):
+			// counted by a MachineSet that outranks this one
+		default:
+			matching = append(matching, &hosts[i])
+		}
+	}
+	return matching, nil
 }
 
-// hostMatches returns true if the BareMetalHost matches the MachineSet.
-func (r *ReconcileMachineSet) hostMatches(ctx context.Context, hostselector labels.Selector,
+// hostMatches returns true if the BareMetalHost matches the MachineSet: either
+// a new Machine of the MachineSet could claim it, or it is already claimed by
+// one of its Machines.
+func (r *ReconcileMachineSet) hostMatches(ctx context.Context, filter *actuator.HostFilter,
 	msselector labels.Selector, host *bmh.BareMetalHost) (bool, error) {
 	consumer := host.Spec.ConsumerRef
 
 	if consumer == nil {
-		// BMH is not consumed, so just see if it matches the host selector
-		return hostselector.Matches(labels.Set(host.ObjectMeta.Labels)), nil
+		// BMH is not consumed, so see if a new Machine could claim it,
+		// using the same rules as the actuator
+		reason, _ := filter.Reject(host)
+		return reason == "", nil
+	}
+
+	if consumer.Kind == "HostClaim" && consumer.APIVersion == bmh.GroupVersion.String() {
+		// host is claimed through a HostClaim, which records the Machine
+		// it was created for
+		claim := &bmh.HostClaim{}
+		nn := types.NamespacedName{
+			Name:      consumer.Name,
+			Namespace: consumer.Namespace,
+		}
+		err := r.Get(ctx, nn, claim)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return false, errConsumerNotFound
+			}
+			return false, err
+		}
+		if claim.Spec.ConsumerRef == nil {
+			return false, nil
+		}
+		consumer = claim.Spec.ConsumerRef
 	}
 
 	// We will only count this host if it is consumed by a Machine that
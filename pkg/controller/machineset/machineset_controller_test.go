@@ -18,18 +18,25 @@ package machineset
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	bmoapis "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
 	"golang.org/x/net/context"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -39,14 +46,35 @@ var expectedRequest2 = reconcile.Request{NamespacedName: types.NamespacedName{Na
 var machinesetKey1 = types.NamespacedName{Name: "machineset1", Namespace: "default"}
 var machinesetKey2 = types.NamespacedName{Name: "machineset2", Namespace: "default"}
 
+// newHostFilter returns the HostFilter for a MachineSet whose hosts are
+// selected by the labels.
+func newHostFilter(t *testing.T, matchLabels map[string]string) *actuator.HostFilter {
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{MatchLabels: matchLabels},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset1", Namespace: "default"},
+	}
+	ms.Spec.Template.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawProviderSpec}
+	filter, err := actuator.NewMachineSetHostFilter(ms, false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return filter
+}
+
 func TestHostMatches(t *testing.T) {
 	scheme := runtime.NewScheme()
 	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
 	ctx := context.TODO()
 
 	testCases := []struct {
 		Host          *bmh.BareMetalHost
-		HSelector     labels.Selector
+		HSelector     map[string]string
 		MSSelector    labels.Selector
 		Machines      []runtime.Object
 		ExpectMatch   bool
@@ -59,10 +87,13 @@ func TestHostMatches(t *testing.T) {
 					Namespace: "default",
 					Labels:    map[string]string{"size": "large"},
 				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "large",
-			}),
+			},
 			MSSelector:    labels.NewSelector(),
 			ExpectMatch:   true,
 			ExpectMessage: "Expected match: available host has matching label",
@@ -74,10 +105,50 @@ func TestHostMatches(t *testing.T) {
 					Namespace: "default",
 					Labels:    map[string]string{"size": "large"},
 				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{State: bmh.StateInspecting},
+				},
+			},
+			HSelector: map[string]string{
+				"size": "large",
+			},
+			MSSelector:    labels.NewSelector(),
+			ExpectMatch:   false,
+			ExpectMessage: "Expected no match: host with matching label is still inspecting",
+		},
+		{
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "host1",
+					Namespace: "default",
+					Labels:    map[string]string{"size": "large"},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+					ErrorMessage: "BMC unreachable",
+				},
+			},
+			HSelector: map[string]string{
+				"size": "large",
+			},
+			MSSelector:    labels.NewSelector(),
+			ExpectMatch:   false,
+			ExpectMessage: "Expected no match: host with matching label has an error",
+		},
+		{
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "host1",
+					Namespace: "default",
+					Labels:    map[string]string{"size": "large"},
+				},
+				Status: bmh.BareMetalHostStatus{
+					Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "extralarge",
-			}),
+			},
 			MSSelector:    labels.NewSelector(),
 			ExpectMatch:   false,
 			ExpectMessage: "Expected no match: available host has non-matching label value",
@@ -107,9 +178,9 @@ func TestHostMatches(t *testing.T) {
 					},
 				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "extralarge",
-			}),
+			},
 			MSSelector: labels.SelectorFromSet(map[string]string{
 				"machine.openshift.io/cluster-api-machineset": "cluster0-storage",
 			}),
@@ -141,15 +212,63 @@ func TestHostMatches(t *testing.T) {
 					},
 				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "large",
-			}),
+			},
 			MSSelector: labels.SelectorFromSet(map[string]string{
 				"machine.openshift.io/cluster-api-machineset": "cluster0-storage",
 			}),
 			ExpectMatch:   false,
 			ExpectMessage: "Expected no match: host consumer is a Machine that does not match the MSSelector",
 		},
+		{
+			Host: &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "host1",
+					Namespace: "default",
+					Labels:    map[string]string{"size": "large"},
+				},
+				Spec: bmh.BareMetalHostSpec{
+					ConsumerRef: &v1.ObjectReference{
+						Kind:       "HostClaim",
+						APIVersion: bmh.GroupVersion.String(),
+						Name:       "machine1",
+						Namespace:  "default",
+					},
+				},
+			},
+			Machines: []runtime.Object{
+				&bmh.HostClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "machine1",
+						Namespace: "default",
+					},
+					Spec: bmh.HostClaimSpec{
+						ConsumerRef: &v1.ObjectReference{
+							Kind:       "Machine",
+							APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+							Name:       "machine1",
+							Namespace:  "default",
+						},
+					},
+				},
+				&machinev1beta1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "machine1",
+						Namespace: "default",
+						Labels:    map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-storage"},
+					},
+				},
+			},
+			HSelector: map[string]string{
+				"size": "extralarge",
+			},
+			MSSelector: labels.SelectorFromSet(map[string]string{
+				"machine.openshift.io/cluster-api-machineset": "cluster0-storage",
+			}),
+			ExpectMatch:   true,
+			ExpectMessage: "Expected match: host consumer is a HostClaim for a Machine that matches the MSSelector",
+		},
 		{
 			Host: &bmh.BareMetalHost{
 				ObjectMeta: metav1.ObjectMeta{
@@ -166,9 +285,9 @@ func TestHostMatches(t *testing.T) {
 					},
 				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "large",
-			}),
+			},
 			MSSelector:    labels.NewSelector(),
 			ExpectMatch:   false,
 			ExpectMessage: "Expected no match: host consumer is not a Machine",
@@ -189,9 +308,9 @@ func TestHostMatches(t *testing.T) {
 					},
 				},
 			},
-			HSelector: labels.SelectorFromSet(map[string]string{
+			HSelector: map[string]string{
 				"size": "large",
-			}),
+			},
 			MSSelector:    labels.NewSelector(),
 			ExpectMatch:   false,
 			ExpectMessage: "Expected no match: host consumer is not a Machine at the right API group/version",
@@ -204,7 +323,7 @@ func TestHostMatches(t *testing.T) {
 			Client: c,
 			scheme: scheme,
 		}
-		result, err := reconciler.hostMatches(ctx, tc.HSelector, tc.MSSelector, tc.Host)
+		result, err := reconciler.hostMatches(ctx, newHostFilter(t, tc.HSelector), tc.MSSelector, tc.Host)
 		if err != nil {
 			t.Errorf("%v", err)
 		}
@@ -276,6 +395,9 @@ func TestScale(t *testing.T) {
 			Namespace: "default",
 			Labels:    map[string]string{"size": "large"},
 		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+		},
 	}
 	// This host has a different label, but its consuming Machine is
 	// part of the MachineSet, so it should be counted.
@@ -337,8 +459,34 @@ func TestScale(t *testing.T) {
 		},
 	}
 
+	// This host is still being inspected, so it should not be counted
+	// unless pending hosts are included
+	host6 := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host6",
+			Namespace: "default",
+			Labels:    map[string]string{"size": "large"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{State: bmh.StateInspecting},
+		},
+	}
+	// This host has an error, so a Machine could not claim it and it should
+	// not be counted
+	host7 := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host7",
+			Namespace: "default",
+			Labels:    map[string]string{"size": "large"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+			ErrorMessage: "BMC unreachable",
+		},
+	}
+
 	resources := []runtime.Object{
-		&host1, &host2, &host3, &host4, &host5,
+		&host1, &host2, &host3, &host4, &host5, &host6, &host7,
 		&machine1, &machine2,
 		instance,
 	}
@@ -388,9 +536,908 @@ func TestScale(t *testing.T) {
 		t.Logf("Replicas is not 1")
 		t.FailNow()
 	}
+
+	// Include pending hosts and expect the inspecting host to be counted
+	ms.Annotations[IncludePendingHostsAnnotation] = ""
+	err = c.Update(context.TODO(), &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	ms = machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey1, &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if ms.Spec.Replicas == nil || *ms.Spec.Replicas != 2 {
+		t.Logf("Replicas is not 2")
+		t.FailNow()
+	}
+}
+
+// TestScaleHostNamespaces ensures that hosts are counted from the MachineSet's
+// host namespaces only when those namespaces allow it.
+func TestScaleHostNamespaces(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+	v1.AddToScheme(scheme)
+
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			MatchLabels:    map[string]string{"size": "large"},
+			HostNamespaces: []string{"hosts", "privatehosts"},
+		},
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	zero := int32(0)
+	instance := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        machinesetKey1.Name,
+			Namespace:   machinesetKey1.Namespace,
+			Annotations: map[string]string{AutoScaleAnnotation: "yesplease"},
+		},
+		Spec: machinev1beta1.MachineSetSpec{
+			Template: machinev1beta1.MachineTemplateSpec{
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: machinev1beta1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: rawProviderSpec},
+					},
+				},
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+			},
+			Replicas: &zero,
+		},
+	}
+	hostsNamespace := v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hosts",
+			Annotations: map[string]string{actuator.AllowedConsumerNamespacesAnnotation: "default"},
+		},
+	}
+	privateNamespace := v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "privatehosts",
+		},
+	}
+	resources := []runtime.Object{instance, &hostsNamespace, &privateNamespace}
+	for _, ns := range []string{"default", "hosts", "hosts", "privatehosts"} {
+		resources = append(resources, &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("host%d", len(resources)),
+				Namespace: ns,
+				Labels:    map[string]string{"size": "large"},
+			},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+			},
+		})
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(resources...).Build()
+	reconciler := ReconcileMachineSet{
+		Client: c,
+		scheme: scheme,
+	}
+
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// Only the two hosts in the "hosts" namespace are counted. The host in
+	// "default" is not in a host namespace, and "privatehosts" does not
+	// allow Machines from "default".
+	ms := machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey1, &ms)
+	switch {
+	case err != nil:
+		t.Errorf("%v", err)
+	case ms.Spec.Replicas == nil:
+		t.Logf("Replicas is nil")
+		t.FailNow()
+	case *ms.Spec.Replicas != 2:
+		t.Logf("Replicas %d is not 2", *ms.Spec.Replicas)
+		t.FailNow()
+	}
+}
+
+func TestScaleCELSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			MatchLabels: map[string]string{"size": "large"},
+			CELSelector: "host.status.hardware.nics.filter(n, n.speedGbps >= 25).size() >= 2",
+		},
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	zero := int32(0)
+	instance := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        machinesetKey1.Name,
+			Namespace:   machinesetKey1.Namespace,
+			Annotations: map[string]string{AutoScaleAnnotation: "yesplease"},
+		},
+		Spec: machinev1beta1.MachineSetSpec{
+			Template: machinev1beta1.MachineTemplateSpec{
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: machinev1beta1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: rawProviderSpec},
+					},
+				},
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+			},
+			Replicas: &zero,
+		},
+	}
+	resources := []runtime.Object{instance}
+	for _, speeds := range [][]int{{25, 25}, {100, 25, 1}, {25, 10}, nil} {
+		host := &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("host%d", len(resources)),
+				Namespace: "default",
+				Labels:    map[string]string{"size": "large"},
+			},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+			},
+		}
+		if speeds != nil {
+			host.Status.HardwareDetails = &bmh.HardwareDetails{}
+			for _, speed := range speeds {
+				host.Status.HardwareDetails.NIC = append(host.Status.HardwareDetails.NIC, bmh.NIC{SpeedGbps: speed})
+			}
+		}
+		resources = append(resources, host)
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(resources...).Build()
+	reconciler := ReconcileMachineSet{
+		Client: c,
+		scheme: scheme,
+	}
+
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// Only the two hosts with two 25G NICs are counted. The host without
+	// hardware details fails to evaluate and is not counted either.
+	ms := machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey1, &ms)
+	switch {
+	case err != nil:
+		t.Errorf("%v", err)
+	case ms.Spec.Replicas == nil:
+		t.Logf("Replicas is nil")
+		t.FailNow()
+	case *ms.Spec.Replicas != 2:
+		t.Logf("Replicas %d is not 2", *ms.Spec.Replicas)
+		t.FailNow()
+	}
+}
+
+// TestScalePolicy ensures that the MachineSet is scaled within the bounds set
+// by its annotations, and only scaled down after the scale down delay.
+func TestScalePolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			MatchLabels: map[string]string{"size": "large"},
+		},
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	zero := int32(0)
+	instance := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machinesetKey1.Name,
+			Namespace: machinesetKey1.Namespace,
+			Annotations: map[string]string{
+				AutoScaleAnnotation:      "yesplease",
+				MaxReplicasAnnotation:    "3",
+				SpareHostsAnnotation:     "2",
+				ScaleDownDelayAnnotation: "10m",
+			},
+		},
+		Spec: machinev1beta1.MachineSetSpec{
+			Template: machinev1beta1.MachineTemplateSpec{
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: machinev1beta1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: rawProviderSpec},
+					},
+				},
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+			},
+			Replicas: &zero,
+		},
+	}
+	resources := []runtime.Object{instance}
+	for i := 0; i < 6; i++ {
+		resources = append(resources, &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("host%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"size": "large"},
+			},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+			},
+		})
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(resources...).Build()
+	reconciler := ReconcileMachineSet{
+		Client: c,
+		scheme: scheme,
+	}
+
+	getReplicas := func() int32 {
+		ms := machinev1beta1.MachineSet{}
+		err := c.Get(context.TODO(), machinesetKey1, &ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if ms.Spec.Replicas == nil {
+			t.Logf("Replicas is nil")
+			t.FailNow()
+		}
+		return *ms.Spec.Replicas
+	}
+
+	// Six hosts less two spares is capped at three replicas, and scaling up
+	// is not delayed.
+	result, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if replicas := getReplicas(); replicas != 3 {
+		t.Logf("Replicas %d is not 3", replicas)
+		t.FailNow()
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("unexpected requeue after %v", result.RequeueAfter)
+	}
+
+	// With four hosts, two replicas are wanted, but the MachineSet is not
+	// scaled down until the delay has passed.
+	for _, host := range resources[1:3] {
+		err = c.Delete(context.TODO(), host.(*bmh.BareMetalHost))
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+	result, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if replicas := getReplicas(); replicas != 3 {
+		t.Logf("Replicas %d is not 3", replicas)
+		t.FailNow()
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > 10*time.Minute {
+		t.Errorf("expected requeue within the scale down delay, got %v", result.RequeueAfter)
+	}
+
+	// Pretend the delay has passed
+	ms := machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey1, &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ms.Annotations[scaleDownSinceAnnotation] = time.Now().Add(-time.Hour).Format(scaleDownSinceFormat)
+	err = c.Update(context.TODO(), &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if replicas := getReplicas(); replicas != 2 {
+		t.Logf("Replicas %d is not 2", replicas)
+		t.FailNow()
+	}
+}
+
+// TestCapacity ensures that the capacity annotations are set from the hosts of
+// a MachineSet that is not autoscaled, and that MachineSets of other providers
+// are left alone.
+func TestCapacity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			MatchLabels: map[string]string{"size": "large"},
+		},
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	zero := int32(0)
+	newMachineSet := func(name string, raw []byte) *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Template: machinev1beta1.MachineTemplateSpec{
+					Spec: machinev1beta1.MachineSpec{
+						ProviderSpec: machinev1beta1.ProviderSpec{
+							Value: &runtime.RawExtension{Raw: raw},
+						},
+					},
+				},
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machineset": name},
+				},
+				Replicas: &zero,
+			},
+		}
+	}
+	resources := []runtime.Object{
+		newMachineSet(machinesetKey1.Name, rawProviderSpec),
+		newMachineSet(machinesetKey2.Name, []byte(`{"apiVersion": "machine.openshift.io/v1beta1", "kind": "AWSMachineProviderConfig"}`)),
+	}
+	for i, cpus := range []int{64, 48} {
+		resources = append(resources, &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("host%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"size": "large"},
+			},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+				HardwareDetails: &bmh.HardwareDetails{
+					CPU:          bmh.CPU{Arch: "x86_64", Count: cpus},
+					RAMMebibytes: 196608,
+				},
+			},
+		})
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(resources...).Build()
+	reconciler := ReconcileMachineSet{
+		Client: c,
+		scheme: scheme,
+	}
+
+	for _, key := range []types.NamespacedName{machinesetKey1, machinesetKey2} {
+		_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key})
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+
+	ms := machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey1, &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	expected := map[string]string{
+		CPUAnnotation:    "48",
+		MemoryAnnotation: "196608",
+		LabelsAnnotation: "kubernetes.io/arch=amd64",
+	}
+	for key, value := range expected {
+		if ms.Annotations[key] != value {
+			t.Errorf("expected annotation %s=%q, got %q", key, value, ms.Annotations[key])
+		}
+	}
+	if *ms.Spec.Replicas != 0 {
+		t.Errorf("replicas %d is not 0; the MachineSet is not autoscaled", *ms.Spec.Replicas)
+	}
+
+	ms = machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey2, &ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if len(ms.Annotations) != 0 {
+		t.Errorf("expected no annotations on the MachineSet of another provider, got %v", ms.Annotations)
+	}
+}
+
+// TestScaleBlocked ensures that the reason a MachineSet cannot be scaled is
+// recorded in its status annotation and in events, and cleared once fixed.
+func TestScaleBlocked(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	instance, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	instance.Spec.Selector = metav1.LabelSelector{}
+	host1 := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host1",
+			Namespace: "default",
+			Labels:    map[string]string{"size": "large"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+		},
+	}
+	// This host is consumed by a Machine that does not exist yet
+	host2 := bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host2",
+			Namespace: "default",
+			Labels:    map[string]string{"size": "large"},
+		},
+		Spec: bmh.BareMetalHostSpec{
+			ConsumerRef: &v1.ObjectReference{
+				Kind:       "Machine",
+				APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				Name:       "machine1",
+				Namespace:  "default",
+			},
+		},
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(instance, &host1, &host2).Build()
+	recorder := record.NewFakeRecorder(10)
+	reconciler := ReconcileMachineSet{
+		Client:        c,
+		scheme:        scheme,
+		eventRecorder: recorder,
+	}
+
+	reconcileStatus := func() (*machinev1beta1.MachineSet, *AutoScaleStatus, reconcile.Result) {
+		result, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		ms := &machinev1beta1.MachineSet{}
+		err = c.Get(context.TODO(), machinesetKey1, ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		status := autoScaleStatus(ms)
+		if status == nil {
+			t.Logf("no status annotation in %v", ms.Annotations)
+			t.FailNow()
+		}
+		return ms, status, result
+	}
+	expectEvent := func(expected string) {
+		select {
+		case event := <-recorder.Events:
+			if !strings.HasPrefix(event, expected) {
+				t.Errorf("expected event %q, got %q", expected, event)
+			}
+		default:
+			t.Errorf("expected event %q", expected)
+		}
+	}
+	expectNoEvent := func() {
+		select {
+		case event := <-recorder.Events:
+			t.Errorf("unexpected event %q", event)
+		default:
+		}
+	}
+
+	// The empty selector blocks scaling
+	ms, status, _ := reconcileStatus()
+	if status.Blocked != BlockedEmptySelector {
+		t.Errorf("expected blocked %q, got %+v", BlockedEmptySelector, status)
+	}
+	expectEvent("Warning AutoScaleBlocked Will not scale to hosts: MachineSet has an empty selector")
+
+	// Reconciling again does not repeat the event
+	reconcileStatus()
+	expectNoEvent()
+
+	// Once the selector is fixed, the consumer of host2 is not found
+	ms.Spec.Selector = metav1.LabelSelector{
+		MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+	}
+	err = c.Update(context.TODO(), ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ms, status, result := reconcileStatus()
+	expected := AutoScaleStatus{
+		MatchedHosts: 2,
+		Blocked:      BlockedConsumerNotFound,
+		Message:      "BareMetalHost default/host2 is consumed by Machine default/machine1, which is not found",
+		Host:         "default/host2",
+	}
+	if !reflect.DeepEqual(*status, expected) {
+		t.Errorf("expected status %+v, got %+v", expected, status)
+	}
+	if result.RequeueAfter != blockedRequeueAfter {
+		t.Errorf("expected requeue after %v, got %v", blockedRequeueAfter, result.RequeueAfter)
+	}
+	if *ms.Spec.Replicas != 2 {
+		t.Errorf("replicas %d changed while blocked", *ms.Spec.Replicas)
+	}
+	expectEvent("Warning AutoScaleBlocked Will not scale to hosts: BareMetalHost default/host2")
+
+	// Once the Machine exists, scaling resumes and the status is cleared
+	err = c.Create(context.TODO(), &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine1",
+			Namespace: "default",
+			Labels:    map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+		},
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	_, status, _ = reconcileStatus()
+	expected = AutoScaleStatus{MatchedHosts: 2, CountedHosts: 2}
+	if !reflect.DeepEqual(*status, expected) {
+		t.Errorf("expected status %+v, got %+v", expected, status)
+	}
+	expectEvent("Normal AutoScaleUnblocked Scaling to hosts resumed after ConsumerNotFound")
+	expectNoEvent()
+
+	// A scaling event is recorded when the replicas change
+	err = c.Delete(context.TODO(), &host1)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ms, status, _ = reconcileStatus()
+	if *ms.Spec.Replicas != 1 || status.CountedHosts != 1 {
+		t.Errorf("expected 1 replica and 1 counted host, got %d and %+v", *ms.Spec.Replicas, status)
+	}
+	expectEvent("Normal AutoScaled Scaled from 2 to 1 replicas with 1 hosts")
+
+	// The status is removed when the MachineSet is no longer autoscaled
+	delete(ms.Annotations, AutoScaleAnnotation)
+	err = c.Update(context.TODO(), ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	err = c.Get(context.TODO(), machinesetKey1, ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if _, ok := ms.Annotations[AutoScaleStatusAnnotation]; ok {
+		t.Errorf("expected %s to be removed", AutoScaleStatusAnnotation)
+	}
+}
+
+// TestScaleDeletionPriority ensures that, when the MachineSet is scaled down,
+// the Machines whose hosts are gone or quarantined are marked for deletion
+// first, then those whose hosts are in error, then the remediated ones, and
+// that they are unmarked once they no longer qualify or are not in excess.
+func TestScaleDeletionPriority(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+	infrav1.AddToScheme(scheme)
+
+	instance, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease", MaxReplicasAnnotation: "2"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	rep := int32(4)
+	instance.Spec.Replicas = &rep
+
+	objects := []runtime.Object{instance}
+	for i := 1; i <= 4; i++ {
+		name := fmt.Sprintf("machine%d", i)
+		hostName := fmt.Sprintf("host%d", i)
+		objects = append(objects, &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-worker"},
+				Annotations: map[string]string{actuator.HostAnnotation: "default/" + hostName},
+			},
+		})
+		host := &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      hostName,
+				Namespace: "default",
+				Labels:    map[string]string{"size": "large"},
+			},
+			Spec: bmh.BareMetalHostSpec{
+				ConsumerRef: &v1.ObjectReference{
+					Kind:       "Machine",
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					Name:       name,
+					Namespace:  "default",
+				},
+			},
+		}
+		switch i {
+		case 2:
+			host.Annotations = map[string]string{QuarantinedHostAnnotation: ""}
+		case 3:
+			host.Status.ErrorMessage = "BMC unreachable"
+		case 4:
+			// the host of machine4 was deleted
+			continue
+		}
+		objects = append(objects, host)
+	}
+	// machine1 was remediated twice
+	objects = append(objects, &infrav1.Metal3Remediation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine1",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					Kind:       "Machine",
+					Name:       "machine1",
+				},
+			},
+		},
+		Status: infrav1.Metal3RemediationStatus{RetryCount: 2},
+	})
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+	recorder := record.NewFakeRecorder(20)
+	reconciler := ReconcileMachineSet{
+		Client:        c,
+		scheme:        scheme,
+		eventRecorder: recorder,
+	}
+
+	reconcileMarked := func(expectedReplicas int32) []string {
+		_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		ms := &machinev1beta1.MachineSet{}
+		err = c.Get(context.TODO(), machinesetKey1, ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if *ms.Spec.Replicas != expectedReplicas {
+			t.Errorf("expected %d replicas, got %d", expectedReplicas, *ms.Spec.Replicas)
+		}
+		machines := machinev1beta1.MachineList{}
+		err = c.List(context.TODO(), &machines)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		marked := []string{}
+		for _, machine := range machines.Items {
+			if _, ok := machine.Annotations[DeleteMachineAnnotation]; ok {
+				marked = append(marked, machine.Name)
+				if machine.Annotations[DeleteMachineReasonAnnotation] == "" {
+					t.Errorf("no reason recorded on %s", machine.Name)
+				}
+			}
+		}
+		return marked
+	}
+
+	setMaxReplicas := func(max string) {
+		ms := &machinev1beta1.MachineSet{}
+		err := c.Get(context.TODO(), machinesetKey1, ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		ms.Annotations[MaxReplicasAnnotation] = max
+		err = c.Update(context.TODO(), ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+
+	// The Machines whose hosts are gone or quarantined go first
+	marked := reconcileMarked(2)
+	if strings.Join(marked, ",") != "machine2,machine4" {
+		t.Errorf("expected machine2 and machine4 to be marked, got %v", marked)
+	}
+
+	// Marked Machines count towards the scale down, so only one more is
+	// marked, and the host in error goes before the remediated one
+	setMaxReplicas("1")
+	marked = reconcileMarked(1)
+	if strings.Join(marked, ",") != "machine2,machine3,machine4" {
+		t.Errorf("expected machine2, machine3 and machine4 to be marked, got %v", marked)
+	}
+
+	// Then the remediated one
+	setMaxReplicas("0")
+	marked = reconcileMarked(0)
+	if strings.Join(marked, ",") != "machine1,machine2,machine3,machine4" {
+		t.Errorf("expected all machines to be marked, got %v", marked)
+	}
+
+	// A Machine whose host no longer qualifies is unmarked
+	host := &bmh.BareMetalHost{}
+	err = c.Get(context.TODO(), types.NamespacedName{Name: "host2", Namespace: "default"}, host)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	delete(host.Annotations, QuarantinedHostAnnotation)
+	err = c.Update(context.TODO(), host)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	marked = reconcileMarked(0)
+	if strings.Join(marked, ",") != "machine1,machine3,machine4" {
+		t.Errorf("expected machine1, machine3 and machine4 to be marked, got %v", marked)
+	}
+
+	// The marks are removed when there is no excess any more, but the
+	// marks set by others are kept
+	machine := &machinev1beta1.Machine{}
+	machine2Key := types.NamespacedName{Name: "machine2", Namespace: "default"}
+	err = c.Get(context.TODO(), machine2Key, machine)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	machine.Annotations[DeleteMachineAnnotation] = "true"
+	err = c.Update(context.TODO(), machine)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	setMaxReplicas("4")
+	_, err = reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: machinesetKey1})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	machines := machinev1beta1.MachineList{}
+	err = c.List(context.TODO(), &machines)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	for _, machine := range machines.Items {
+		_, marked := machine.Annotations[DeleteMachineAnnotation]
+		if marked != (machine.Name == "machine2") {
+			t.Errorf("expected only machine2 to stay marked, %s is marked: %v", machine.Name, marked)
+		}
+		if _, ok := machine.Annotations[DeleteMachineReasonAnnotation]; ok {
+			t.Errorf("expected the reason to be removed from %s", machine.Name)
+		}
+	}
+}
+
+// TestScaleOverlap ensures that autoscaled MachineSets selecting the same
+// free hosts are reported as overlapping, and that the shared hosts go to the
+// MachineSet with the highest priority, then to the first one by name.
+func TestScaleOverlap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	ms1, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	// ms2 selects the same hosts, but has different Machines
+	ms2 := ms1.DeepCopy()
+	ms2.Name = machinesetKey2.Name
+	ms2.Spec.Selector.MatchLabels = map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-storage"}
+	machine1 := machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine1",
+			Namespace: "default",
+			Labels:    map[string]string{"machine.openshift.io/cluster-api-machineset": "cluster0-storage"},
+		},
+	}
+	objects := []runtime.Object{ms1, ms2, &machine1}
+	for i := 1; i <= 4; i++ {
+		host := &bmh.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("host%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"size": "large"},
+			},
+			Status: bmh.BareMetalHostStatus{
+				Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+			},
+		}
+		if i == 4 {
+			// claimed by a Machine of ms2, so never shared
+			host.Spec.ConsumerRef = &v1.ObjectReference{
+				Kind:       "Machine",
+				APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+				Name:       "machine1",
+				Namespace:  "default",
+			}
+			host.Status.Provisioning.State = bmh.StateProvisioned
+		}
+		objects = append(objects, host)
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+	recorder := record.NewFakeRecorder(10)
+	reconciler := ReconcileMachineSet{
+		Client:        c,
+		scheme:        scheme,
+		eventRecorder: recorder,
+	}
+
+	reconcileStatus := func(key types.NamespacedName) (*machinev1beta1.MachineSet, *AutoScaleStatus) {
+		_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key})
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		ms := &machinev1beta1.MachineSet{}
+		err = c.Get(context.TODO(), key, ms)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		status := autoScaleStatus(ms)
+		if status == nil {
+			t.Logf("no status annotation in %v", ms.Annotations)
+			t.FailNow()
+		}
+		return ms, status
+	}
+	expectScale := func(key types.NamespacedName, replicas int32, overlapping string) {
+		ms, status := reconcileStatus(key)
+		if *ms.Spec.Replicas != replicas {
+			t.Errorf("%s: expected %d replicas, got %d", key.Name, replicas, *ms.Spec.Replicas)
+		}
+		if strings.Join(status.OverlappingMachineSets, ",") != overlapping || status.SharedHosts != 3 {
+			t.Errorf("%s: expected 3 hosts shared with %s, got %+v", key.Name, overlapping, status)
+		}
+	}
+
+	// With the same priority, the shared hosts go to the first MachineSet
+	// by name
+	expectScale(machinesetKey1, 3, machinesetKey2.Name)
+	expectScale(machinesetKey2, 1, machinesetKey1.Name)
+	overlapEvents := 0
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.HasPrefix(event, "Warning AutoScaleOverlap 3 free hosts are shared") {
+			overlapEvents++
+		}
+	}
+	if overlapEvents != 2 {
+		t.Errorf("expected an overlap event for each MachineSet, got %d", overlapEvents)
+	}
+
+	// A higher priority takes them over
+	ms := &machinev1beta1.MachineSet{}
+	err = c.Get(context.TODO(), machinesetKey2, ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ms.Annotations[PriorityAnnotation] = "1"
+	err = c.Update(context.TODO(), ms)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	expectScale(machinesetKey1, 0, machinesetKey2.Name)
+	expectScale(machinesetKey2, 4, machinesetKey1.Name)
 }
 
-// TestIgnore ensures that a MachineSet without the annotation gets ignored.
+// TestIgnore ensures that a MachineSet without the annotation does not get
+// scaled.
 func TestIgnore(t *testing.T) {
 	scheme := runtime.NewScheme()
 	machinev1beta1.AddToScheme(scheme)
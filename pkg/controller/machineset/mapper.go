@@ -3,11 +3,14 @@ package machineset
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
-	"k8s.io/apimachinery/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -15,7 +18,7 @@ import (
 
 // AutoScaleAnnotation is an annotation key that, when added to a MachineSet
 // with any value, indicates that this controller should scale that MachineSet
-// to equal the number of matching BareMetalHosts in the same namespace.
+// to equal the number of matching BareMetalHosts in its host namespaces.
 const AutoScaleAnnotation = "metal3.io/autoscale-to-hosts"
 
 type msmapper struct {
@@ -26,19 +29,15 @@ type msmapper struct {
 // BareMetalHost and that BareMetalHost matches the MachineSet's HostSelector.
 func (m *msmapper) Map(_ context.Context, host *bmh.BareMetalHost) []reconcile.Request {
 	requests := []reconcile.Request{}
-	msets := machinev1beta1.MachineSetList{}
-	err := m.client.List(context.TODO(), &msets, &client.ListOptions{Namespace: host.Namespace})
+	msets, err := m.listMachineSets(host)
 	if err != nil {
 		log.Error(err, "failed to list MachineSets")
 		return []reconcile.Request{}
 	}
-	for _, ms := range msets.Items {
-		annotations := ms.ObjectMeta.GetAnnotations()
-		if annotations == nil {
-			continue
-		}
-		_, present := annotations[AutoScaleAnnotation]
-		if !present {
+	for _, ms := range msets {
+		// Every MachineSet using BareMetalHosts has capacity annotations
+		// to keep up to date, whether or not it is autoscaled.
+		if !actuator.IsBareMetalProviderSpec(&ms.Spec.Template.Spec.ProviderSpec) {
 			continue
 		}
 
@@ -60,10 +59,85 @@ func (m *msmapper) Map(_ context.Context, host *bmh.BareMetalHost) []reconcile.R
 	return requests
 }
 
+// listMachineSets returns the MachineSets that may claim the BareMetalHost:
+// those in the host's namespace, and those in the namespaces allowed by the
+// host namespace's policy.
+func (m *msmapper) listMachineSets(host *bmh.BareMetalHost) ([]machinev1beta1.MachineSet, error) {
+	namespaces := []string{host.Namespace}
+	hostNamespace := corev1.Namespace{}
+	err := m.client.Get(context.TODO(), client.ObjectKey{Name: host.Namespace}, &hostNamespace)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		// still consider MachineSets in the host's own namespace
+		log.Error(err, "failed to get Namespace of BareMetalHost", "Namespace", host.Namespace)
+	default:
+		for _, ns := range actuator.AllowedConsumerNamespaces(&hostNamespace) {
+			if ns == "*" {
+				// any namespace may use these hosts
+				namespaces = []string{metav1.NamespaceAll}
+				break
+			}
+			if !slices.Contains(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+
+	msets := []machinev1beta1.MachineSet{}
+	for _, ns := range namespaces {
+		nsMsets := machinev1beta1.MachineSetList{}
+		err := m.client.List(context.TODO(), &nsMsets, &client.ListOptions{Namespace: ns})
+		if err != nil {
+			return nil, err
+		}
+		msets = append(msets, nsMsets.Items...)
+	}
+	return msets, nil
+}
+
 func (m *msmapper) hostMatchesMachineSet(host *bmh.BareMetalHost, ms *machinev1beta1.MachineSet) (bool, error) {
-	selector, err := actuator.SelectorFromProviderSpec(&ms.Spec.Template.Spec.ProviderSpec)
+	hostNamespaces, err := actuator.HostNamespacesFromProviderSpec(&ms.Spec.Template.Spec.ProviderSpec, ms.Namespace)
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(hostNamespaces, host.Namespace) {
+		return false, nil
+	}
+	matcher, err := actuator.HostMatcherFromProviderSpec(&ms.Spec.Template.Spec.ProviderSpec)
 	if err != nil {
 		return false, err
 	}
-	return selector.Matches(labels.Set(host.ObjectMeta.Labels)), nil
+	return matcher.Matches(host), nil
+}
+
+// MapPeers returns reconcile requests for the other autoscaled MachineSets
+// using BareMetalHosts in the namespace of the MachineSet, since they split
+// the free hosts they share with it.
+func (m *msmapper) MapPeers(ctx context.Context, ms *machinev1beta1.MachineSet) []reconcile.Request {
+	requests := []reconcile.Request{}
+	msets := machinev1beta1.MachineSetList{}
+	err := m.client.List(ctx, &msets, client.InNamespace(ms.Namespace))
+	if err != nil {
+		log.Error(err, "failed to list MachineSets")
+		return requests
+	}
+	for _, peer := range msets.Items {
+		if peer.Name == ms.Name {
+			continue
+		}
+		if _, autoscale := peer.Annotations[AutoScaleAnnotation]; !autoscale {
+			continue
+		}
+		if !actuator.IsBareMetalProviderSpec(&peer.Spec.Template.Spec.ProviderSpec) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      peer.Name,
+				Namespace: peer.Namespace,
+			},
+		})
+	}
+	return requests
 }
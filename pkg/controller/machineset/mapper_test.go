@@ -25,6 +25,8 @@ import (
 	bmoapis "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -74,8 +76,8 @@ func TestMapper(t *testing.T) {
 				},
 			},
 			Annotations:   map[string]string{},
-			ExpectRequest: false,
-			FailMessage:   "host without annotation should not generate a request",
+			ExpectRequest: true,
+			FailMessage:   "host with matching label should generate a request without annotation, to update the capacity annotations",
 		},
 	}
 
@@ -115,6 +117,174 @@ func TestMapper(t *testing.T) {
 	}
 }
 
+func TestMapperHostNamespaces(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+
+	host := &bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host1",
+			Namespace: "hosts",
+			Labels:    map[string]string{"size": "large"},
+		},
+	}
+
+	testCases := []struct {
+		Scenario       string
+		Allowed        string
+		HostNamespaces []string
+		ExpectRequest  bool
+	}{
+		{
+			Scenario:       "host namespace allows the MachineSet namespace",
+			Allowed:        "default",
+			HostNamespaces: []string{"hosts"},
+			ExpectRequest:  true,
+		},
+		{
+			Scenario:       "host namespace allows any namespace",
+			Allowed:        "*",
+			HostNamespaces: []string{"hosts"},
+			ExpectRequest:  true,
+		},
+		{
+			Scenario:       "host namespace does not allow the MachineSet namespace",
+			Allowed:        "otherns",
+			HostNamespaces: []string{"hosts"},
+			ExpectRequest:  false,
+		},
+		{
+			Scenario:      "MachineSet does not use the host namespace",
+			Allowed:       "default",
+			ExpectRequest: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			ms, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease"})
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+				HostSelector: bmv1alpha1.HostSelector{
+					MatchLabels:    map[string]string{"size": "large"},
+					HostNamespaces: tc.HostNamespaces,
+				},
+			})
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			ms.Spec.Template.Spec.ProviderSpec.Value.Raw = rawProviderSpec
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        host.Namespace,
+					Annotations: map[string]string{actuator.AllowedConsumerNamespacesAnnotation: tc.Allowed},
+				},
+			}
+			c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(ms, host, namespace).Build()
+
+			mapper := msmapper{client: c}
+			requests := mapper.Map(context.TODO(), host)
+
+			expected := 0
+			if tc.ExpectRequest {
+				expected = 1
+			}
+			if len(requests) != expected {
+				t.Fatalf("expected %d requests, got %d", expected, len(requests))
+			}
+		})
+	}
+}
+
+func TestMapperCELSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	ms, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
+		HostSelector: bmv1alpha1.HostSelector{
+			MatchLabels: map[string]string{"size": "large"},
+			CELSelector: "has(host.status.hardware) && host.status.hardware.cpu.count >= 64",
+		},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ms.Spec.Template.Spec.ProviderSpec.Value.Raw = rawProviderSpec
+
+	for _, tc := range []struct {
+		Scenario      string
+		CPUs          int
+		ExpectRequest bool
+	}{
+		{Scenario: "matching host", CPUs: 64, ExpectRequest: true},
+		{Scenario: "small host", CPUs: 8, ExpectRequest: false},
+		{Scenario: "host not inspected", ExpectRequest: false},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			host := &bmh.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "host1",
+					Namespace: "default",
+					Labels:    map[string]string{"size": "large"},
+				},
+			}
+			if tc.CPUs != 0 {
+				host.Status.HardwareDetails = &bmh.HardwareDetails{CPU: bmh.CPU{Count: tc.CPUs}}
+			}
+			c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(ms, host).Build()
+
+			mapper := msmapper{client: c}
+			requests := mapper.Map(context.TODO(), host)
+
+			expected := 0
+			if tc.ExpectRequest {
+				expected = 1
+			}
+			if len(requests) != expected {
+				t.Fatalf("expected %d requests, got %d", expected, len(requests))
+			}
+		})
+	}
+}
+
+func TestMapPeers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	machinev1beta1.AddToScheme(scheme)
+	bmoapis.AddToScheme(scheme)
+
+	ms1, err := newMachineSet(map[string]string{AutoScaleAnnotation: "yesplease"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	ms2 := ms1.DeepCopy()
+	ms2.Name = machinesetKey2.Name
+	// not autoscaled, so it does not split hosts with ms1
+	ms3 := ms1.DeepCopy()
+	ms3.Name = "machineset3"
+	ms3.Annotations = nil
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(ms1, ms2, ms3).Build()
+	m := msmapper{client: c}
+
+	requests := m.MapPeers(context.TODO(), ms1)
+	if len(requests) != 1 || requests[0].NamespacedName != machinesetKey2 {
+		t.Errorf("expected a request for %v, got %v", machinesetKey2, requests)
+	}
+	requests = m.MapPeers(context.TODO(), ms3)
+	if len(requests) != 2 {
+		t.Errorf("expected requests for both autoscaled MachineSets, got %v", requests)
+	}
+}
+
 func newMachineSet(annotations map[string]string) (*machinev1beta1.MachineSet, error) {
 	rawProviderSpec, err := json.Marshal(&bmv1alpha1.BareMetalMachineProviderSpec{
 		HostSelector: bmv1alpha1.HostSelector{
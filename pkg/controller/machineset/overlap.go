@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"context"
+	"slices"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// overlapPeer is another autoscaled MachineSet in the same namespace, whose
+// new Machines may claim some of the same free hosts.
+type overlapPeer struct {
+	name       string
+	priority   int32
+	namespaces []string
+	filter     *actuator.HostFilter
+}
+
+// claims returns true if a new Machine of the peer could claim the host.
+func (p *overlapPeer) claims(host *bmh.BareMetalHost) bool {
+	if !slices.Contains(p.namespaces, host.Namespace) {
+		return false
+	}
+	reason, _ := p.filter.Reject(host)
+	return reason == ""
+}
+
+// outranks returns true if the free hosts the peer shares with the
+// MachineSet of the given name and priority are counted by the peer.
+func (p *overlapPeer) outranks(name string, priority int32) bool {
+	if p.priority != priority {
+		return p.priority > priority
+	}
+	return p.name < name
+}
+
+// overlapPeers returns the other autoscaled MachineSets using BareMetalHosts
+// in the namespace of ms. MachineSets that cannot be scaled because of their
+// annotations or ProviderSpec do not count any host, so they are left out.
+func overlapPeers(ctx context.Context, c client.Reader, ms *machinev1beta1.MachineSet) ([]*overlapPeer, error) {
+	msets := machinev1beta1.MachineSetList{}
+	err := c.List(ctx, &msets, client.InNamespace(ms.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	peers := []*overlapPeer{}
+	for i := range msets.Items {
+		other := &msets.Items[i]
+		if other.Name == ms.Name || other.DeletionTimestamp != nil {
+			continue
+		}
+		if _, autoscale := other.Annotations[AutoScaleAnnotation]; !autoscale {
+			continue
+		}
+		if !actuator.IsBareMetalProviderSpec(&other.Spec.Template.Spec.ProviderSpec) {
+			continue
+		}
+		policy, err := AutoScalePolicyFromAnnotations(other.Annotations)
+		if err != nil {
+			continue
+		}
+		namespaces, err := actuator.HostNamespacesFromProviderSpec(&other.Spec.Template.Spec.ProviderSpec, other.Namespace)
+		if err != nil {
+			continue
+		}
+		filter, err := actuator.NewMachineSetHostFilter(other, policy.IncludePendingHosts)
+		if err != nil {
+			continue
+		}
+		peers = append(peers, &overlapPeer{
+			name:       other.Name,
+			priority:   policy.Priority,
+			namespaces: namespaces,
+			filter:     filter,
+		})
+	}
+	return peers, nil
+}
+
+// shareHost records the peers that could also claim the free host in the
+// status, and returns true if one of them outranks the MachineSet, so that
+// the host is not counted for it.
+func shareHost(ms *machinev1beta1.MachineSet, policy *AutoScalePolicy, peers []*overlapPeer,
+	host *bmh.BareMetalHost, status *AutoScaleStatus) bool {
+	shared, yield := false, false
+	for _, peer := range peers {
+		if !peer.claims(host) {
+			continue
+		}
+		shared = true
+		if !slices.Contains(status.OverlappingMachineSets, peer.name) {
+			status.OverlappingMachineSets = append(status.OverlappingMachineSets, peer.name)
+			slices.Sort(status.OverlappingMachineSets)
+		}
+		if peer.outranks(ms.Name, policy.Priority) {
+			yield = true
+		}
+	}
+	if shared {
+		status.SharedHosts++
+	}
+	return yield
+}
+
+// OverlappingMachineSets returns the names of the other autoscaled
+// MachineSets in the namespace of ms whose new Machines could claim some of
+// the same free BareMetalHosts as those of ms.
+func OverlappingMachineSets(ctx context.Context, c client.Client, ms *machinev1beta1.MachineSet) ([]string, error) {
+	policy, err := AutoScalePolicyFromAnnotations(ms.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	msselector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReconcileMachineSet{Client: c}
+	status := &AutoScaleStatus{}
+	_, err = r.matchingHosts(ctx, ms, msselector, policy, status)
+	if err != nil {
+		return nil, err
+	}
+	return status.OverlappingMachineSets, nil
+}
+
+// autoScalePeerPredicate passes the events for MachineSets that change which
+// free hosts an autoscaled MachineSet could claim, and so which hosts its
+// peers count. Changes of replicas or of the status are left out.
+type autoScalePeerPredicate struct {
+	predicate.TypedFuncs[*machinev1beta1.MachineSet]
+}
+
+func (autoScalePeerPredicate) Create(e event.TypedCreateEvent[*machinev1beta1.MachineSet]) bool {
+	_, autoscale := e.Object.Annotations[AutoScaleAnnotation]
+	return autoscale
+}
+
+func (autoScalePeerPredicate) Delete(e event.TypedDeleteEvent[*machinev1beta1.MachineSet]) bool {
+	_, autoscale := e.Object.Annotations[AutoScaleAnnotation]
+	return autoscale
+}
+
+func (autoScalePeerPredicate) Update(e event.TypedUpdateEvent[*machinev1beta1.MachineSet]) bool {
+	if isNil(e.ObjectOld) || isNil(e.ObjectNew) {
+		return false
+	}
+	oldMS, newMS := e.ObjectOld, e.ObjectNew
+	if (oldMS.DeletionTimestamp == nil) != (newMS.DeletionTimestamp == nil) {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(oldMS.Spec.Template, newMS.Spec.Template) {
+		return true
+	}
+	for _, key := range append([]string{AutoScaleAnnotation}, AutoScalePolicyAnnotations...) {
+		oldValue, oldSet := oldMS.Annotations[key]
+		newValue, newSet := newMS.Annotations[key]
+		if oldSet != newSet || oldValue != newValue {
+			return true
+		}
+	}
+	return false
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/cluster-api-provider-baremetal/pkg/baremetal"
@@ -48,8 +49,10 @@ type Metal3RemediationReconciler struct {
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3remediations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3remediations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3remediationtemplates,verbs=get;list;watch
 // +kubebuilder:rbac:groups=machine.openshift.io,resources=machines;machines/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
 
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts/status,verbs=get;update;patch
@@ -126,151 +129,259 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 
 	remediationType := remediationMgr.GetRemediationType()
 
-	if remediationType != infrav1.RebootRemediationStrategy {
+	switch remediationType {
+	case infrav1.RebootRemediationStrategy, baremetal.PowerOffRemediationStrategy,
+		baremetal.ReprovisionRemediationStrategy, baremetal.DeleteMachineRemediationStep,
+		baremetal.QuarantineRemediationStep:
+	default:
 		r.Log.Info("unsupported remediation strategy")
 		return ctrl.Result{}, nil
 	}
 
-	if remediationType == infrav1.RebootRemediationStrategy {
-		// If no phase set, default to running and set time and retry count
-		if remediationMgr.GetRemediationPhase() == "" {
-			remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
-			now := metav1.Now()
-			remediationMgr.SetLastRemediationTime(&now)
-			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	// If no phase set, resolve the steps, then start once admitted within the
+	// remediation limits
+	switch remediationMgr.GetRemediationPhase() {
+	case "":
+		if err := remediationMgr.ResolveRemediationPolicy(ctx); err != nil {
+			r.Log.Error(err, "error resolving remediation steps")
+			return ctrl.Result{}, errors.Wrap(err, "error resolving remediation steps")
 		}
+		return r.startRemediation(ctx, remediationMgr)
+	case baremetal.PhasePending:
+		return r.startRemediation(ctx, remediationMgr)
+	}
 
-		// handle old clusters which were not setup with RBAC for accessing nodes
-		isNodeForbidden := false
-		node, err := remediationMgr.GetNode(ctx)
-		if err != nil {
-			if apierrors.IsForbidden(err) {
-				r.Log.Info("Node access is forbidden, will skip node deletion")
-				isNodeForbidden = true
-			} else if !apierrors.IsNotFound(err) {
-				r.Log.Error(err, "error getting node for remediation")
-				return ctrl.Result{}, errors.Wrap(err, "error getting node for remediation")
-			}
+	// handle old clusters which were not setup with RBAC for accessing nodes
+	isNodeForbidden := false
+	node, err := remediationMgr.GetNode(ctx)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			r.Log.Info("Node access is forbidden, will skip node deletion")
+			isNodeForbidden = true
+		} else if !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "error getting node for remediation")
+			return ctrl.Result{}, errors.Wrap(err, "error getting node for remediation")
+		}
+	}
+
+	switch remediationMgr.GetRemediationPhase() {
+	case infrav1.PhaseRunning:
+
+		switch remediationType {
+		case baremetal.ReprovisionRemediationStrategy:
+			return r.remediateReprovisionStrategy(ctx, remediationMgr, host, node)
+		case baremetal.DeleteMachineRemediationStep:
+			return r.giveUp(ctx, remediationMgr)
+		case baremetal.QuarantineRemediationStep:
+			return r.quarantineHost(ctx, remediationMgr)
 		}
+		return r.remediateRebootStrategy(ctx, remediationMgr, remediationType, node)
+
+	case baremetal.PhaseDeprovisioning:
+
+		return r.waitForDeprovisioning(ctx, remediationMgr, node)
 
-		switch remediationMgr.GetRemediationPhase() {
-		case infrav1.PhaseRunning:
+	case baremetal.PhaseReprovisioning:
 
-			return r.remediateRebootStrategy(ctx, remediationMgr, node)
+		return r.waitForReprovisioning(ctx, remediationMgr)
 
-		case infrav1.PhaseWaiting:
+	case infrav1.PhaseWaiting:
+
+		if remediationType == baremetal.PowerOffRemediationStrategy {
+			// Leave the host powered off for a human to investigate
+			return r.fenceHost(ctx, remediationMgr)
+		}
 
-			// Node is deleted: remove power off annotation
-			ok, err := remediationMgr.IsPowerOffRequested(ctx)
+		// Node is deleted: remove power off annotation
+		ok, err := remediationMgr.IsPowerOffRequested(ctx)
+		if err != nil {
+			r.Log.Error(err, "error getting poweroff annotation status")
+			return ctrl.Result{}, errors.Wrap(err, "error getting poweroff annotation status")
+		} else if ok {
+			r.Log.Info("Powering on the host")
+			err := remediationMgr.RemovePowerOffAnnotation(ctx)
 			if err != nil {
-				r.Log.Error(err, "error getting poweroff annotation status")
-				return ctrl.Result{}, errors.Wrap(err, "error getting poweroff annotation status")
-			} else if ok {
-				r.Log.Info("Powering on the host")
-				err := remediationMgr.RemovePowerOffAnnotation(ctx)
-				if err != nil {
-					r.Log.Error(err, "error removing poweroff annotation")
-					return ctrl.Result{}, errors.Wrap(err, "error removing poweroff annotation")
-				}
+				r.Log.Error(err, "error removing poweroff annotation")
+				return ctrl.Result{}, errors.Wrap(err, "error removing poweroff annotation")
 			}
+		}
 
-			// Wait until powered on
-			if on, err := remediationMgr.IsPoweredOn(ctx); err != nil {
-				r.Log.Error(err, "error getting power status")
-				return ctrl.Result{}, errors.Wrap(err, "error getting power status")
-			} else if !on {
-				// wait a bit before checking again if we are powered on
-				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
-			}
+		// Wait until powered on
+		if on, err := remediationMgr.IsPoweredOn(ctx); err != nil {
+			r.Log.Error(err, "error getting power status")
+			return ctrl.Result{}, errors.Wrap(err, "error getting power status")
+		} else if !on {
+			// wait a bit before checking again if we are powered on
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
 
-			// Restore node if available and not done yet
-			if remediationMgr.HasFinalizer() {
-				if node != nil {
-					// Node was recreated, restore annotations and labels
-					r.Log.Info("Restoring the node")
-					if err := r.restoreNode(ctx, remediationMgr, node); err != nil {
-						return ctrl.Result{}, err
-					}
-
-					// clean up
-					r.Log.Info("Remediation done, cleaning up remediation CR")
-					remediationMgr.RemoveNodeBackupAnnotations()
-					remediationMgr.UnsetFinalizer()
-					return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
-				} else if isNodeForbidden {
-					// we don't have a node, just remove finalizer
-					remediationMgr.UnsetFinalizer()
-
-					r.Log.Info("Skipping node restore, remediation done, CR should be deleted soon")
-					return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		// Restore node if available and not done yet
+		if remediationMgr.HasFinalizer() {
+			if node != nil {
+				// Node was recreated, restore annotations and labels
+				r.Log.Info("Restoring the node")
+				if err := r.restoreNode(ctx, remediationMgr, node); err != nil {
+					return ctrl.Result{}, err
 				}
-			}
 
-			// Check timeout, either node wasn't recreated yet, or CR is not deleted because of still unhealthy node
-			timedOut, _ := remediationMgr.TimeToRemediate(remediationMgr.GetTimeout().Duration)
-			if !timedOut {
-				// Not yet time to retry or stop remediation, requeue
-				r.Log.Info("Waiting for node to get healthy and CR being deleted")
+				// clean up
+				r.Log.Info("Remediation done, cleaning up remediation CR")
+				remediationMgr.RemoveNodeBackupAnnotations()
+				remediationMgr.UnsetFinalizer()
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
-			}
+			} else if isNodeForbidden {
+				// we don't have a node, just remove finalizer
+				remediationMgr.UnsetFinalizer()
 
-			// Try again if limit not reached
-			if remediationMgr.RetryLimitIsSet() && !remediationMgr.HasReachRetryLimit() {
-				r.Log.Info("Remediation timed out, will retry")
-				remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
-				now := metav1.Now()
-				remediationMgr.SetLastRemediationTime(&now)
-				remediationMgr.IncreaseRetryCount()
-				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+				r.Log.Info("Skipping node restore, remediation done, CR should be deleted soon")
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 			}
+		}
 
-			r.Log.Info("Remediation timed out and retry limit reached")
+		// Check timeout, either node wasn't recreated yet, or CR is not deleted because of still unhealthy node
+		timedOut, _ := remediationMgr.TimeToRemediate(remediationMgr.GetTimeout().Duration)
+		if !timedOut {
+			// Not yet time to retry or stop remediation, requeue
+			r.Log.Info("Waiting for node to get healthy and CR being deleted")
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
 
-			// When machine is still unhealthy after remediation, and it can be re-provisioned, delete the machine.
-			// Note: this differs to the upstream metal3 remediation, which sets a condition which is handled by CAPI
-			if ok, err := remediationMgr.CanReprovision(ctx); err != nil {
-				return ctrl.Result{}, errors.Wrapf(err, "Failed to check if machine can be reprovisoned")
-			} else if ok {
-				r.Log.Info("Deleting machine")
-				if err := remediationMgr.DeleteMachine(ctx); err != nil {
-					return ctrl.Result{}, errors.Wrapf(err, "Failed to delete machine")
-				}
-			} else {
-				r.Log.Info("Machine can't be re-provisioned, will not delete it")
-			}
+		return r.retryOrGiveUp(ctx, remediationMgr)
 
-			// Remediation failed, so set unhealthy annotation on BMH
-			// This prevents BMH to be selected as a host.
-			err = remediationMgr.SetUnhealthyAnnotation(ctx)
-			if err != nil {
-				r.Log.Error(err, "error setting unhealthy annotation")
-				return ctrl.Result{}, errors.Wrapf(err, "error setting unhealthy annotation")
-			}
+	case infrav1.PhaseDeleting:
+		// nothing to do anymore
+		break
 
-			remediationMgr.SetRemediationPhase(infrav1.PhaseDeleting)
-			// no requeue, we are done
-			return ctrl.Result{}, nil
+	case infrav1.PhaseFailed:
+		// nothing to do anymore
+		break
 
-		case infrav1.PhaseDeleting:
-			// nothing to do anymore
-			break
+	case baremetal.PhaseFenced:
+		// nothing to do anymore, the host stays powered off
+		break
 
-		case infrav1.PhaseFailed:
-			// nothing to do anymore
-			break
+	case baremetal.PhaseQuarantined:
+		// nothing to do anymore
+		break
 
-		default:
-			r.Log.Error(nil, "unknown phase!", "phase", remediationMgr.GetRemediationPhase())
+	default:
+		r.Log.Error(nil, "unknown phase!", "phase", remediationMgr.GetRemediationPhase())
+	}
+	return ctrl.Result{}, nil
+}
+
+// retryOrGiveUp is called when a remediation timed out. It starts over if the
+// retry limit is not reached yet, or escalates to the next step if the
+// remediation has one. Otherwise it gives up.
+func (r *Metal3RemediationReconciler) retryOrGiveUp(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	// Try again if limit not reached
+	if remediationMgr.RetryLimitIsSet() && !remediationMgr.HasReachRetryLimit() {
+		r.Log.Info("Remediation timed out, will retry")
+		remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
+		now := metav1.Now()
+		remediationMgr.SetLastRemediationTime(&now)
+		remediationMgr.IncreaseRetryCount()
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	// The remediation leaves the step, so a host deprovisioned by the
+	// Reprovision strategy gets its provisioning spec back
+	switch remediationMgr.GetRemediationPhase() {
+	case baremetal.PhaseDeprovisioning, baremetal.PhaseReprovisioning:
+		r.Log.Info("Remediation timed out while reprovisioning, restoring the host")
+		if err := remediationMgr.EndReprovisioning(ctx); err != nil {
+			r.Log.Error(err, "error ending reprovisioning")
+			return ctrl.Result{}, errors.Wrap(err, "error ending reprovisioning")
+		}
+	}
+
+	// Escalate if there is a next step
+	if remediationMgr.AdvanceRemediationStep(baremetal.StepTimedOut) {
+		r.Log.Info("Remediation timed out and retry limit reached, escalating to the next step")
+		remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
+		now := metav1.Now()
+		remediationMgr.SetLastRemediationTime(&now)
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	r.Log.Info("Remediation timed out and retry limit reached")
+	return r.giveUp(ctx, remediationMgr)
+}
+
+// giveUp deletes the Machine if it can be reprovisioned, and marks its host
+// unhealthy.
+func (r *Metal3RemediationReconciler) giveUp(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	// When machine is still unhealthy after remediation, and it can be re-provisioned, delete the machine.
+	// Note: this differs to the upstream metal3 remediation, which sets a condition which is handled by CAPI
+	if ok, err := remediationMgr.CanReprovision(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "Failed to check if machine can be reprovisoned")
+	} else if ok {
+		r.Log.Info("Deleting machine")
+		if err := remediationMgr.DeleteMachine(ctx); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "Failed to delete machine")
 		}
+	} else {
+		r.Log.Info("Machine can't be re-provisioned, will not delete it")
 	}
+
+	// Remediation failed, so set unhealthy annotation on BMH
+	// This prevents BMH to be selected as a host.
+	err := remediationMgr.SetUnhealthyAnnotation(ctx)
+	if err != nil {
+		r.Log.Error(err, "error setting unhealthy annotation")
+		return ctrl.Result{}, errors.Wrapf(err, "error setting unhealthy annotation")
+	}
+
+	remediationMgr.SetRemediationPhase(infrav1.PhaseDeleting)
+	// no requeue, we are done
 	return ctrl.Result{}, nil
 }
 
-// remediateRebootStrategy executes the remediation using the reboot strategy.
+// startRemediation switches to the running phase and sets the time, or to the
+// pending phase while the remediation limits do not admit the remediation.
+func (r *Metal3RemediationReconciler) startRemediation(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	if ok, result, err := r.admitRemediation(ctx, remediationMgr); !ok {
+		return result, err
+	}
+
+	remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
+	now := metav1.Now()
+	remediationMgr.SetLastRemediationTime(&now)
+	return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+}
+
+// admitRemediation returns true if the host may be powered off within the
+// remediation limits and the quorum of the control plane. Otherwise the
+// remediation switches to the pending phase, and the result and error to
+// return are given.
+func (r *Metal3RemediationReconciler) admitRemediation(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (bool, ctrl.Result, error) {
+	admitted, reason, err := remediationMgr.AdmitRemediation(ctx)
+	if err != nil {
+		r.Log.Error(err, "error checking remediation limits")
+		return false, ctrl.Result{}, errors.Wrap(err, "error checking remediation limits")
+	}
+	if !admitted {
+		r.Log.Info("Remediation is pending", "reason", reason)
+		remediationMgr.SetRemediationPhase(baremetal.PhasePending)
+		return false, ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+	if reason != "" {
+		r.Log.Info("Remediation is admitted", "reason", reason)
+	}
+	return true, ctrl.Result{}, nil
+}
+
+// remediateRebootStrategy executes the remediation using the reboot strategy,
+// up to the host being powered off and its Node deleted. The PowerOff
+// strategy shares these steps.
 // Returns nil, nil when reconcile can continue.
 // Return a Result and optionally an error when reconcile should return.
 func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Context,
-	remediationMgr baremetal.RemediationManagerInterface,
+	remediationMgr baremetal.RemediationManagerInterface, remediationType infrav1.RemediationType,
 	node *corev1.Node) (ctrl.Result, error) {
 	// add finalizer
 	if !remediationMgr.HasFinalizer() {
@@ -283,8 +394,32 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 		r.Log.Error(err, "error getting poweroff annotation status")
 		return ctrl.Result{}, errors.Wrap(err, "error getting poweroff annotation status")
 	} else if !ok {
-		r.Log.Info("Powering off the host")
-		err = remediationMgr.SetPowerOffAnnotation(ctx)
+		// refuse to fence the last healthy members of the control plane
+		if remediationType == baremetal.PowerOffRemediationStrategy {
+			admitted, reason, err := remediationMgr.AdmitFencing(ctx)
+			if err != nil {
+				r.Log.Error(err, "error checking control plane quorum")
+				return ctrl.Result{}, errors.Wrap(err, "error checking control plane quorum")
+			}
+			if !admitted {
+				r.Log.Info("Refusing to fence the host", "reason", reason)
+				remediationMgr.UnsetFinalizer()
+				remediationMgr.SetRemediationPhase(infrav1.PhaseFailed)
+				return ctrl.Result{}, nil
+			}
+		}
+
+		// check the limits and quorum again before each power off
+		if ok, result, err := r.admitRemediation(ctx, remediationMgr); !ok {
+			return result, err
+		}
+
+		mode := bmov1alpha1.RebootModeHard
+		if remediationMgr.GetSoftPowerOffTimeout() != nil {
+			mode = bmov1alpha1.RebootModeSoft
+		}
+		r.Log.Info("Powering off the host", "mode", mode)
+		err = remediationMgr.SetPowerOffAnnotation(ctx, mode)
 		if err != nil {
 			r.Log.Error(err, "error setting poweroff annotation")
 			return ctrl.Result{}, errors.Wrap(err, "error setting poweroff annotation")
@@ -299,6 +434,9 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 		r.Log.Error(err, "error getting power status")
 		return ctrl.Result{}, errors.Wrap(err, "error getting power status")
 	} else if on {
+		if err := r.escalatePowerOff(ctx, remediationMgr); err != nil {
+			return ctrl.Result{}, err
+		}
 		// wait a bit before checking again if we are powered off already
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
@@ -334,6 +472,174 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 }
 
+// escalatePowerOff requests a hard power off of the host once its soft power
+// off has been tried for longer than the grace period.
+func (r *Metal3RemediationReconciler) escalatePowerOff(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) error {
+	mode, requested := remediationMgr.GetPowerOffMode()
+	if mode != bmov1alpha1.RebootModeSoft {
+		return nil
+	}
+	if timeout := remediationMgr.GetSoftPowerOffTimeout(); requested != nil && timeout != nil &&
+		time.Since(requested.Time) < timeout.Duration {
+		return nil
+	}
+
+	r.Log.Info("Soft power off timed out, powering off the host hard")
+	if err := remediationMgr.SetPowerOffAnnotation(ctx, bmov1alpha1.RebootModeHard); err != nil {
+		r.Log.Error(err, "error setting poweroff annotation")
+		return errors.Wrap(err, "error setting poweroff annotation")
+	}
+	return nil
+}
+
+// fenceHost ends the PowerOff strategy once the host is powered off and its
+// Node is deleted. The power off annotation is kept so that the host stays
+// off, and the unhealthy annotation keeps it from being claimed again if its
+// Machine is deleted. The Node is not restored, so the finalizer that guards
+// the node backup is removed.
+func (r *Metal3RemediationReconciler) fenceHost(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	r.Log.Info("Host is fenced, leaving it powered off")
+	err := remediationMgr.SetUnhealthyAnnotation(ctx)
+	if err != nil {
+		r.Log.Error(err, "error setting unhealthy annotation")
+		return ctrl.Result{}, errors.Wrapf(err, "error setting unhealthy annotation")
+	}
+	remediationMgr.UnsetFinalizer()
+	remediationMgr.SetRemediationPhase(baremetal.PhaseFenced)
+	// no requeue, we are done
+	return ctrl.Result{}, nil
+}
+
+// remediateReprovisionStrategy starts the remediation using the reprovision
+// strategy: the Node is backed up and the host is deprovisioned. The Node is
+// only deleted once the host is deprovisioned, and so powered off.
+func (r *Metal3RemediationReconciler) remediateReprovisionStrategy(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface,
+	host *bmov1alpha1.BareMetalHost, node *corev1.Node) (ctrl.Result, error) {
+	// add finalizer
+	if !remediationMgr.HasFinalizer() {
+		remediationMgr.SetFinalizer()
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	if host != nil && host.Spec.ExternallyProvisioned {
+		r.Log.Info("Unable to remediate, Host is externally provisioned and cannot be reprovisioned")
+		remediationMgr.UnsetFinalizer()
+		remediationMgr.SetRemediationPhase(infrav1.PhaseFailed)
+		return ctrl.Result{}, nil
+	}
+
+	// store annotations and labels before the node goes away
+	if node != nil && r.backupNode(remediationMgr, node) {
+		r.Log.Info("Backing up node")
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	// check the limits and quorum again before taking the host down
+	if ok, result, err := r.admitRemediation(ctx, remediationMgr); !ok {
+		return result, err
+	}
+
+	r.Log.Info("Deprovisioning the host")
+	if err := remediationMgr.DeprovisionHost(ctx); err != nil {
+		r.Log.Error(err, "error deprovisioning host")
+		return ctrl.Result{}, errors.Wrap(err, "error deprovisioning host")
+	}
+	remediationMgr.SetRemediationPhase(baremetal.PhaseDeprovisioning)
+	now := metav1.Now()
+	remediationMgr.SetLastRemediationTime(&now)
+	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+}
+
+// waitForDeprovisioning deletes the Node once the host is deprovisioned, then
+// provisions the host again with the image and userData of the Machine.
+func (r *Metal3RemediationReconciler) waitForDeprovisioning(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface, node *corev1.Node) (ctrl.Result, error) {
+	state, err := remediationMgr.GetProvisioningState(ctx)
+	if err != nil {
+		r.Log.Error(err, "error getting provisioning state")
+		return ctrl.Result{}, errors.Wrap(err, "error getting provisioning state")
+	}
+
+	if state != bmov1alpha1.StateAvailable && state != bmov1alpha1.StateReady {
+		if timedOut, _ := remediationMgr.TimeToRemediate(remediationMgr.GetTimeout().Duration); timedOut {
+			return r.retryOrGiveUp(ctx, remediationMgr)
+		}
+		r.Log.Info("Waiting for host to be deprovisioned", "state", state)
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	if node != nil {
+		r.Log.Info("Deleting node")
+		if err := remediationMgr.DeleteNode(ctx, node); err != nil {
+			r.Log.Error(err, "error deleting node")
+			return ctrl.Result{}, errors.Wrap(err, "error deleting node")
+		}
+		// wait until node is gone
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	r.Log.Info("Reprovisioning the host")
+	if err := remediationMgr.ReprovisionHost(ctx); err != nil {
+		r.Log.Error(err, "error reprovisioning host")
+		return ctrl.Result{}, errors.Wrap(err, "error reprovisioning host")
+	}
+	remediationMgr.SetRemediationPhase(baremetal.PhaseReprovisioning)
+	now := metav1.Now()
+	remediationMgr.SetLastRemediationTime(&now)
+	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+}
+
+// waitForReprovisioning switches to waiting for the node restore once the
+// host is provisioned again.
+func (r *Metal3RemediationReconciler) waitForReprovisioning(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	state, err := remediationMgr.GetProvisioningState(ctx)
+	if err != nil {
+		r.Log.Error(err, "error getting provisioning state")
+		return ctrl.Result{}, errors.Wrap(err, "error getting provisioning state")
+	}
+
+	if state != bmov1alpha1.StateProvisioned {
+		if timedOut, _ := remediationMgr.TimeToRemediate(remediationMgr.GetTimeout().Duration); timedOut {
+			return r.retryOrGiveUp(ctx, remediationMgr)
+		}
+		r.Log.Info("Waiting for host to be provisioned", "state", state)
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	if err := remediationMgr.RemoveReprovisioningAnnotation(ctx); err != nil {
+		r.Log.Error(err, "error removing reprovisioning annotation")
+		return ctrl.Result{}, errors.Wrap(err, "error removing reprovisioning annotation")
+	}
+
+	remediationMgr.SetRemediationPhase(infrav1.PhaseWaiting)
+	now := metav1.Now()
+	remediationMgr.SetLastRemediationTime(&now)
+	r.Log.Info("Switch to waiting phase for node restore")
+	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+}
+
+// quarantineHost ends a remediation with the Quarantine step. The Machine is
+// kept, and its host is marked quarantined and unhealthy for a human to
+// investigate. The Node is not restored, so the finalizer that guards the
+// node backup is removed.
+func (r *Metal3RemediationReconciler) quarantineHost(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	r.Log.Info("Quarantining the host")
+	err := remediationMgr.SetQuarantineAnnotation(ctx)
+	if err != nil {
+		r.Log.Error(err, "error setting quarantine annotation")
+		return ctrl.Result{}, errors.Wrapf(err, "error setting quarantine annotation")
+	}
+	remediationMgr.UnsetFinalizer()
+	remediationMgr.SetRemediationPhase(baremetal.PhaseQuarantined)
+	// no requeue, we are done
+	return ctrl.Result{}, nil
+}
+
 func (r *Metal3RemediationReconciler) getMachine(remediationLog logr.Logger, metal3Remediation *infrav1.Metal3Remediation) (*machinev1beta1.Machine, error) {
 	// try to get the machine via owner ref
 	for _, ownerRef := range metal3Remediation.OwnerReferences {
@@ -40,19 +40,27 @@ import (
 )
 
 type reconcileNormalRemediationTestCase struct {
-	ExpectError           bool
-	ExpectRequeue         bool
-	GetUnhealthyHostFails bool
-	HostStatusOffline     bool
-	RemediationPhase      string
-	IsFinalizerSet        bool
-	IsPowerOffRequested   bool
-	IsPoweredOn           bool
-	IsNodeForbidden       bool
-	IsNodeBackedUp        bool
-	IsNodeDeleted         bool
-	IsTimedOut            bool
-	IsRetryLimitReached   bool
+	RemediationType         infrav1.RemediationType
+	ExpectError             bool
+	ExpectRequeue           bool
+	GetUnhealthyHostFails   bool
+	HostStatusOffline       bool
+	RemediationPhase        string
+	IsFinalizerSet          bool
+	IsPowerOffRequested     bool
+	IsPoweredOn             bool
+	IsNodeForbidden         bool
+	IsNodeBackedUp          bool
+	IsNodeDeleted           bool
+	IsTimedOut              bool
+	IsRetryLimitReached     bool
+	IsExternallyProvisioned bool
+	ProvisioningState       bmov1alpha1.ProvisioningState
+	HasNextStep             bool
+	IsSoftPowerOff          bool
+	IsSoftPowerOffTimedOut  bool
+	PendingReason           string
+	IsFencingRefused        bool
 }
 
 func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
@@ -60,6 +68,7 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 	m := baremetal_mocks.NewMockRemediationManagerInterface(ctrl)
 
 	bmh := &bmov1alpha1.BareMetalHost{}
+	bmh.Spec.ExternallyProvisioned = tc.IsExternallyProvisioned
 	if tc.GetUnhealthyHostFails {
 		m.EXPECT().GetUnhealthyHost(context.TODO()).Return(nil, nil, fmt.Errorf("can't find foo_bmh"))
 		return m
@@ -94,11 +103,55 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 		}
 	}
 
-	m.EXPECT().GetRemediationType().Return(infrav1.RebootRemediationStrategy)
+	expectRetryOrGiveUp := func() {
+		m.EXPECT().RetryLimitIsSet().Return(true)
+		m.EXPECT().HasReachRetryLimit().Return(tc.IsRetryLimitReached)
+		if !tc.IsRetryLimitReached {
+			m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
+			m.EXPECT().SetLastRemediationTime(gomock.Any())
+			m.EXPECT().IncreaseRetryCount()
+			return
+		}
+		switch tc.RemediationPhase {
+		case baremetal.PhaseDeprovisioning, baremetal.PhaseReprovisioning:
+			m.EXPECT().EndReprovisioning(context.TODO())
+		}
+		m.EXPECT().AdvanceRemediationStep(baremetal.StepTimedOut).Return(tc.HasNextStep)
+		if tc.HasNextStep {
+			m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
+			m.EXPECT().SetLastRemediationTime(gomock.Any())
+			return
+		}
+		m.EXPECT().CanReprovision(context.TODO())
+		m.EXPECT().SetUnhealthyAnnotation(context.TODO())
+		m.EXPECT().SetRemediationPhase(infrav1.PhaseDeleting)
+	}
+
+	expectTimeout := func() {
+		m.EXPECT().GetTimeout().Return(&metav1.Duration{Duration: time.Second})
+		m.EXPECT().TimeToRemediate(gomock.Any()).Return(tc.IsTimedOut, time.Second)
+		if tc.IsTimedOut {
+			expectRetryOrGiveUp()
+		}
+	}
+
+	remediationType := tc.RemediationType
+	if remediationType == "" {
+		remediationType = infrav1.RebootRemediationStrategy
+	}
+	m.EXPECT().GetRemediationType().Return(remediationType)
 	m.EXPECT().GetRemediationPhase().Return(tc.RemediationPhase).MinTimes(1)
 
 	switch tc.RemediationPhase {
-	case "":
+	case "", baremetal.PhasePending:
+		if tc.RemediationPhase == "" {
+			m.EXPECT().ResolveRemediationPolicy(context.TODO())
+		}
+		m.EXPECT().AdmitRemediation(context.TODO()).Return(tc.PendingReason == "", tc.PendingReason, nil)
+		if tc.PendingReason != "" {
+			m.EXPECT().SetRemediationPhase(baremetal.PhasePending)
+			return m
+		}
 		m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
 		m.EXPECT().SetLastRemediationTime(gomock.Any())
 
@@ -106,20 +159,89 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 
 		expectGetNode()
 
+		switch remediationType {
+		case baremetal.DeleteMachineRemediationStep:
+			m.EXPECT().CanReprovision(context.TODO())
+			m.EXPECT().SetUnhealthyAnnotation(context.TODO())
+			m.EXPECT().SetRemediationPhase(infrav1.PhaseDeleting)
+			return m
+		case baremetal.QuarantineRemediationStep:
+			m.EXPECT().SetQuarantineAnnotation(context.TODO())
+			m.EXPECT().UnsetFinalizer()
+			m.EXPECT().SetRemediationPhase(baremetal.PhaseQuarantined)
+			return m
+		}
+
 		m.EXPECT().HasFinalizer().Return(tc.IsFinalizerSet)
 		if !tc.IsFinalizerSet {
 			m.EXPECT().SetFinalizer().Return()
 			return m
 		}
 
+		if remediationType == baremetal.ReprovisionRemediationStrategy {
+			if tc.IsExternallyProvisioned {
+				m.EXPECT().UnsetFinalizer()
+				m.EXPECT().SetRemediationPhase(infrav1.PhaseFailed)
+				return m
+			}
+			if !tc.IsNodeForbidden && !tc.IsNodeDeleted {
+				m.EXPECT().SetNodeBackupAnnotations("{\"foo\":\"bar\"}", "{\"answer\":\"42\"}").Return(!tc.IsNodeBackedUp)
+				if !tc.IsNodeBackedUp {
+					return m
+				}
+			}
+			m.EXPECT().AdmitRemediation(context.TODO()).Return(tc.PendingReason == "", tc.PendingReason, nil)
+			if tc.PendingReason != "" {
+				m.EXPECT().SetRemediationPhase(baremetal.PhasePending)
+				return m
+			}
+			m.EXPECT().DeprovisionHost(context.TODO())
+			m.EXPECT().SetRemediationPhase(baremetal.PhaseDeprovisioning)
+			m.EXPECT().SetLastRemediationTime(gomock.Any())
+			return m
+		}
+
+		softPowerOffTimeout := &metav1.Duration{Duration: time.Minute}
 		m.EXPECT().IsPowerOffRequested(context.TODO()).Return(tc.IsPowerOffRequested, nil)
 		if !tc.IsPowerOffRequested {
-			m.EXPECT().SetPowerOffAnnotation(context.TODO())
+			if remediationType == baremetal.PowerOffRemediationStrategy {
+				m.EXPECT().AdmitFencing(context.TODO()).Return(!tc.IsFencingRefused, "quorum", nil)
+				if tc.IsFencingRefused {
+					m.EXPECT().UnsetFinalizer()
+					m.EXPECT().SetRemediationPhase(infrav1.PhaseFailed)
+					return m
+				}
+			}
+			m.EXPECT().AdmitRemediation(context.TODO()).Return(tc.PendingReason == "", tc.PendingReason, nil)
+			if tc.PendingReason != "" {
+				m.EXPECT().SetRemediationPhase(baremetal.PhasePending)
+				return m
+			}
+			if tc.IsSoftPowerOff {
+				m.EXPECT().GetSoftPowerOffTimeout().Return(softPowerOffTimeout)
+				m.EXPECT().SetPowerOffAnnotation(context.TODO(), bmov1alpha1.RebootModeSoft)
+			} else {
+				m.EXPECT().GetSoftPowerOffTimeout()
+				m.EXPECT().SetPowerOffAnnotation(context.TODO(), bmov1alpha1.RebootModeHard)
+			}
 			return m
 		}
 
 		m.EXPECT().IsPoweredOn(context.TODO()).Return(tc.IsPoweredOn, nil)
 		if tc.IsPoweredOn {
+			if !tc.IsSoftPowerOff {
+				m.EXPECT().GetPowerOffMode().Return(bmov1alpha1.RebootModeHard, &metav1.Time{Time: time.Now()})
+				return m
+			}
+			requested := metav1.Now()
+			if tc.IsSoftPowerOffTimedOut {
+				requested = metav1.NewTime(requested.Add(-2 * softPowerOffTimeout.Duration))
+			}
+			m.EXPECT().GetPowerOffMode().Return(bmov1alpha1.RebootModeSoft, &requested)
+			m.EXPECT().GetSoftPowerOffTimeout().Return(softPowerOffTimeout)
+			if tc.IsSoftPowerOffTimedOut {
+				m.EXPECT().SetPowerOffAnnotation(context.TODO(), bmov1alpha1.RebootModeHard)
+			}
 			return m
 		}
 
@@ -139,6 +261,13 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 
 		expectGetNode()
 
+		if remediationType == baremetal.PowerOffRemediationStrategy {
+			m.EXPECT().SetUnhealthyAnnotation(context.TODO())
+			m.EXPECT().UnsetFinalizer()
+			m.EXPECT().SetRemediationPhase(baremetal.PhaseFenced)
+			return m
+		}
+
 		m.EXPECT().IsPowerOffRequested(context.TODO()).Return(tc.IsPowerOffRequested, nil)
 		if tc.IsPowerOffRequested {
 			m.EXPECT().RemovePowerOffAnnotation(context.TODO())
@@ -164,27 +293,49 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 			}
 		}
 
-		m.EXPECT().GetTimeout().Return(&metav1.Duration{Duration: time.Second})
-		m.EXPECT().TimeToRemediate(gomock.Any()).Return(tc.IsTimedOut, time.Second)
-		if tc.IsTimedOut {
-			m.EXPECT().RetryLimitIsSet().Return(true)
-			m.EXPECT().HasReachRetryLimit().Return(tc.IsRetryLimitReached)
-			if !tc.IsRetryLimitReached {
-				m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
-				m.EXPECT().SetLastRemediationTime(gomock.Any())
-				m.EXPECT().IncreaseRetryCount()
-				return m
-			}
-			m.EXPECT().CanReprovision(context.TODO())
-			m.EXPECT().SetUnhealthyAnnotation(context.TODO())
-			m.EXPECT().SetRemediationPhase(infrav1.PhaseDeleting)
+		expectTimeout()
+
+	case baremetal.PhaseDeprovisioning:
+
+		expectGetNode()
+
+		m.EXPECT().GetProvisioningState(context.TODO()).Return(tc.ProvisioningState, nil)
+		if tc.ProvisioningState != bmov1alpha1.StateAvailable && tc.ProvisioningState != bmov1alpha1.StateReady {
+			expectTimeout()
+			return m
+		}
+		if !tc.IsNodeForbidden && !tc.IsNodeDeleted {
+			m.EXPECT().DeleteNode(context.TODO(), gomock.Any())
+			return m
 		}
+		m.EXPECT().ReprovisionHost(context.TODO())
+		m.EXPECT().SetRemediationPhase(baremetal.PhaseReprovisioning)
+		m.EXPECT().SetLastRemediationTime(gomock.Any())
+
+	case baremetal.PhaseReprovisioning:
+
+		expectGetNode()
+
+		m.EXPECT().GetProvisioningState(context.TODO()).Return(tc.ProvisioningState, nil)
+		if tc.ProvisioningState != bmov1alpha1.StateProvisioned {
+			expectTimeout()
+			return m
+		}
+		m.EXPECT().RemoveReprovisioningAnnotation(context.TODO())
+		m.EXPECT().SetRemediationPhase(infrav1.PhaseWaiting)
+		m.EXPECT().SetLastRemediationTime(gomock.Any())
 
 	case infrav1.PhaseDeleting:
 		expectGetNode()
 
 	case infrav1.PhaseFailed:
 		expectGetNode()
+
+	case baremetal.PhaseFenced:
+		expectGetNode()
+
+	case baremetal.PhaseQuarantined:
+		expectGetNode()
 	}
 	return m
 }
@@ -202,7 +353,7 @@ var _ = Describe("Metal3Remediation controller", func() {
 
 				remReconcile = &Metal3RemediationReconciler{
 					Client:         fakeClient,
-					ManagerFactory: baremetal.NewManagerFactory(fakeClient),
+					ManagerFactory: baremetal.NewManagerFactory(fakeClient, nil),
 					Log:            logr.Discard(),
 				}
 			})
@@ -247,6 +398,20 @@ var _ = Describe("Metal3Remediation controller", func() {
 					IsNodeDeleted:       false,
 					IsTimedOut:          false,
 				}),
+				Entry("Should wait in the pending phase beyond the remediation limits", reconcileNormalRemediationTestCase{
+					ExpectRequeue:    true,
+					RemediationPhase: "",
+					PendingReason:    "3 hosts are being remediated, the limit is 3",
+				}),
+				Entry("Should stay pending while beyond the remediation limits", reconcileNormalRemediationTestCase{
+					ExpectRequeue:    true,
+					RemediationPhase: baremetal.PhasePending,
+					PendingReason:    "3 hosts are being remediated, the limit is 3",
+				}),
+				Entry("Should start a pending remediation once admitted", reconcileNormalRemediationTestCase{
+					ExpectRequeue:    true,
+					RemediationPhase: baremetal.PhasePending,
+				}),
 				Entry("Should set finalizer, last remediation time and retry count, and then requeue", reconcileNormalRemediationTestCase{
 					ExpectError:         false,
 					ExpectRequeue:       true,
@@ -280,6 +445,39 @@ var _ = Describe("Metal3Remediation controller", func() {
 					IsNodeDeleted:       false,
 					IsTimedOut:          false,
 				}),
+				Entry("Should wait in the pending phase when powering off is not admitted", reconcileNormalRemediationTestCase{
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: false,
+					IsPoweredOn:         true,
+					PendingReason:       "1 of the other 2 control-plane Nodes are Ready, 2 are needed for quorum",
+				}),
+				Entry("Should request a soft power off when a grace period is set, and then requeue", reconcileNormalRemediationTestCase{
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: false,
+					IsPoweredOn:         true,
+					IsSoftPowerOff:      true,
+				}),
+				Entry("Should requeue while still powered on within the soft power off grace period", reconcileNormalRemediationTestCase{
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: true,
+					IsPoweredOn:         true,
+					IsSoftPowerOff:      true,
+				}),
+				Entry("Should power off hard when still powered on after the soft power off grace period", reconcileNormalRemediationTestCase{
+					ExpectRequeue:          true,
+					RemediationPhase:       infrav1.PhaseRunning,
+					IsFinalizerSet:         true,
+					IsPowerOffRequested:    true,
+					IsPoweredOn:            true,
+					IsSoftPowerOff:         true,
+					IsSoftPowerOffTimedOut: true,
+				}),
 				Entry("Should backup node when powered off, and then requeue", reconcileNormalRemediationTestCase{
 					ExpectError:         false,
 					ExpectRequeue:       true,
@@ -414,6 +612,180 @@ var _ = Describe("Metal3Remediation controller", func() {
 					ExpectRequeue:    false,
 					RemediationPhase: infrav1.PhaseFailed,
 				}),
+				Entry("Should power off and delete node with the PowerOff strategy", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.PowerOffRemediationStrategy,
+					ExpectError:         false,
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: true,
+					IsPoweredOn:         false,
+					IsNodeBackedUp:      true,
+					IsNodeDeleted:       false,
+				}),
+				Entry("Should power off the host once fencing is admitted with the PowerOff strategy", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.PowerOffRemediationStrategy,
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: false,
+					IsPoweredOn:         true,
+				}),
+				Entry("Should refuse to fence the last healthy members of the control plane", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.PowerOffRemediationStrategy,
+					RemediationPhase:    infrav1.PhaseRunning,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: false,
+					IsPoweredOn:         true,
+					IsFencingRefused:    true,
+				}),
+				Entry("Should fence the host without powering it on with the PowerOff strategy", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.PowerOffRemediationStrategy,
+					ExpectError:         false,
+					ExpectRequeue:       false,
+					RemediationPhase:    infrav1.PhaseWaiting,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: true,
+					IsPoweredOn:         false,
+					IsNodeDeleted:       true,
+				}),
+				Entry("Should not requeue for Phase Fenced", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.PowerOffRemediationStrategy,
+					ExpectError:      false,
+					ExpectRequeue:    false,
+					RemediationPhase: baremetal.PhaseFenced,
+				}),
+				Entry("Should fail without deprovisioning an externally provisioned host with the Reprovision strategy", reconcileNormalRemediationTestCase{
+					RemediationType:         baremetal.ReprovisionRemediationStrategy,
+					ExpectError:             false,
+					ExpectRequeue:           false,
+					RemediationPhase:        infrav1.PhaseRunning,
+					IsFinalizerSet:          true,
+					IsExternallyProvisioned: true,
+				}),
+				Entry("Should backup node before deprovisioning with the Reprovision strategy, and then requeue", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.ReprovisionRemediationStrategy,
+					ExpectError:      false,
+					ExpectRequeue:    true,
+					RemediationPhase: infrav1.PhaseRunning,
+					IsFinalizerSet:   true,
+					IsNodeBackedUp:   false,
+				}),
+				Entry("Should deprovision the host when node is backed up with the Reprovision strategy, and then requeue", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.ReprovisionRemediationStrategy,
+					ExpectError:      false,
+					ExpectRequeue:    true,
+					RemediationPhase: infrav1.PhaseRunning,
+					IsFinalizerSet:   true,
+					IsNodeBackedUp:   true,
+				}),
+				Entry("Should wait in the pending phase without deprovisioning when the control plane quorum would break with the Reprovision strategy", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.ReprovisionRemediationStrategy,
+					ExpectRequeue:    true,
+					RemediationPhase: infrav1.PhaseRunning,
+					IsFinalizerSet:   true,
+					IsNodeBackedUp:   true,
+					PendingReason:    "1 of the other 2 control-plane Nodes are Ready, 2 are needed for quorum",
+				}),
+				Entry("Should requeue while the host is deprovisioning if not timed out", reconcileNormalRemediationTestCase{
+					RemediationType:   baremetal.ReprovisionRemediationStrategy,
+					ExpectError:       false,
+					ExpectRequeue:     true,
+					RemediationPhase:  baremetal.PhaseDeprovisioning,
+					ProvisioningState: bmov1alpha1.StateDeprovisioning,
+				}),
+				Entry("Should give up when deprovisioning timed out and retry limit is reached", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.ReprovisionRemediationStrategy,
+					ExpectError:         false,
+					ExpectRequeue:       false,
+					RemediationPhase:    baremetal.PhaseDeprovisioning,
+					ProvisioningState:   bmov1alpha1.StateDeprovisioning,
+					IsTimedOut:          true,
+					IsRetryLimitReached: true,
+				}),
+				Entry("Should delete node when the host is deprovisioned, and then requeue", reconcileNormalRemediationTestCase{
+					RemediationType:   baremetal.ReprovisionRemediationStrategy,
+					ExpectError:       false,
+					ExpectRequeue:     true,
+					RemediationPhase:  baremetal.PhaseDeprovisioning,
+					ProvisioningState: bmov1alpha1.StateAvailable,
+				}),
+				Entry("Should reprovision the host when node is deleted, and then requeue", reconcileNormalRemediationTestCase{
+					RemediationType:   baremetal.ReprovisionRemediationStrategy,
+					ExpectError:       false,
+					ExpectRequeue:     true,
+					RemediationPhase:  baremetal.PhaseDeprovisioning,
+					ProvisioningState: bmov1alpha1.StateAvailable,
+					IsNodeDeleted:     true,
+				}),
+				Entry("Should retry when reprovisioning timed out", reconcileNormalRemediationTestCase{
+					RemediationType:   baremetal.ReprovisionRemediationStrategy,
+					ExpectError:       false,
+					ExpectRequeue:     true,
+					RemediationPhase:  baremetal.PhaseReprovisioning,
+					ProvisioningState: bmov1alpha1.StateProvisioning,
+					IsNodeDeleted:     true,
+					IsTimedOut:        true,
+				}),
+				Entry("Should restore the host and give up when reprovisioning timed out and retry limit is reached", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.ReprovisionRemediationStrategy,
+					ExpectError:         false,
+					ExpectRequeue:       false,
+					RemediationPhase:    baremetal.PhaseReprovisioning,
+					ProvisioningState:   bmov1alpha1.StateProvisioning,
+					IsNodeDeleted:       true,
+					IsTimedOut:          true,
+					IsRetryLimitReached: true,
+				}),
+				Entry("Should wait for the node when the host is provisioned again, and then requeue", reconcileNormalRemediationTestCase{
+					RemediationType:   baremetal.ReprovisionRemediationStrategy,
+					ExpectError:       false,
+					ExpectRequeue:     true,
+					RemediationPhase:  baremetal.PhaseReprovisioning,
+					ProvisioningState: bmov1alpha1.StateProvisioned,
+					IsNodeDeleted:     true,
+				}),
+				Entry("Should escalate to the next step when retry limit is reached, and then requeue", reconcileNormalRemediationTestCase{
+					ExpectError:         false,
+					ExpectRequeue:       true,
+					RemediationPhase:    infrav1.PhaseWaiting,
+					IsFinalizerSet:      true,
+					IsPowerOffRequested: false,
+					IsPoweredOn:         true,
+					IsNodeBackedUp:      true,
+					IsNodeDeleted:       true,
+					IsTimedOut:          true,
+					IsRetryLimitReached: true,
+					HasNextStep:         true,
+				}),
+				Entry("Should escalate to the next step when deprovisioning timed out", reconcileNormalRemediationTestCase{
+					RemediationType:     baremetal.ReprovisionRemediationStrategy,
+					ExpectError:         false,
+					ExpectRequeue:       true,
+					RemediationPhase:    baremetal.PhaseDeprovisioning,
+					ProvisioningState:   bmov1alpha1.StateDeprovisioning,
+					IsTimedOut:          true,
+					IsRetryLimitReached: true,
+					HasNextStep:         true,
+				}),
+				Entry("Should delete machine with the DeleteMachine step, and don't requeue", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.DeleteMachineRemediationStep,
+					ExpectError:      false,
+					ExpectRequeue:    false,
+					RemediationPhase: infrav1.PhaseRunning,
+				}),
+				Entry("Should quarantine the host with the Quarantine step, and don't requeue", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.QuarantineRemediationStep,
+					ExpectError:      false,
+					ExpectRequeue:    false,
+					RemediationPhase: infrav1.PhaseRunning,
+				}),
+				Entry("Should not requeue for Phase Quarantined", reconcileNormalRemediationTestCase{
+					RemediationType:  baremetal.QuarantineRemediationStep,
+					ExpectError:      false,
+					ExpectRequeue:    false,
+					RemediationPhase: baremetal.PhaseQuarantined,
+				}),
 			)
 		})
 })
@@ -0,0 +1,101 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Machine implements validation and defaulting webhooks for Machines with a
+// BareMetalMachineProviderSpec.
+type Machine struct {
+	// Defaults sets the cluster-wide defaults on the ProviderSpec of new
+	// Machines. If nil, no defaults are set.
+	Defaults *ProviderSpecDefaulter
+}
+
+var _ crwebhook.CustomDefaulter = &Machine{}
+var _ crwebhook.CustomValidator = &Machine{}
+
+func (w *Machine) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&machinev1beta1.Machine{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+func (w *Machine) Default(ctx context.Context, obj runtime.Object) error {
+	m, ok := obj.(*machinev1beta1.Machine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Machine but got a %T", obj))
+	}
+	return w.Defaults.defaultProviderSpec(ctx, &m.Spec.ProviderSpec)
+}
+
+func (w *Machine) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	m, ok := obj.(*machinev1beta1.Machine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Machine but got a %T", obj))
+	}
+	return nil, validateMachine(m)
+}
+
+// ValidateUpdate only validates the ProviderSpec and the deletion policy
+// annotation if they changed, so that
+// Machines created before the webhook was installed can still be updated and
+// deleted.
+func (w *Machine) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldM, ok := oldObj.(*machinev1beta1.Machine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Machine but got a %T", oldObj))
+	}
+	m, ok := newObj.(*machinev1beta1.Machine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Machine but got a %T", newObj))
+	}
+	if equality.Semantic.DeepEqual(oldM.Spec.ProviderSpec, m.Spec.ProviderSpec) &&
+		oldM.Annotations[actuator.DeletionPolicyAnnotation] == m.Annotations[actuator.DeletionPolicyAnnotation] {
+		return nil, nil
+	}
+	return nil, validateMachine(m)
+}
+
+func (w *Machine) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateMachine(m *machinev1beta1.Machine) error {
+	allErrs := validateProviderSpec(&m.Spec.ProviderSpec, field.NewPath("spec", "providerSpec"))
+	allErrs = append(allErrs, validateDeletionPolicyAnnotation(m.Annotations, &m.Spec.ProviderSpec,
+		field.NewPath("metadata", "annotations"))...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(machinev1beta1.GroupVersion.WithKind("Machine").GroupKind(), m.Name, allErrs)
+}
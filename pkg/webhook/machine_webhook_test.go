@@ -0,0 +1,424 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	bmh "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/controller/machineset"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const validProviderSpec = `{
+	"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+	"kind": "BareMetalMachineProviderSpec",
+	"image": {"url": "http://172.22.0.1/images/rhcos.qcow2", "checksum": "http://172.22.0.1/images/rhcos.qcow2.md5sum"},
+	"hostSelector": {"matchLabels": {"role": "worker"}}
+}`
+
+func machineWithProviderSpec(raw string) *machinev1beta1.Machine {
+	return &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "myns"},
+		Spec: machinev1beta1.MachineSpec{
+			ProviderSpec: machinev1beta1.ProviderSpec{
+				Value: &runtime.RawExtension{Raw: []byte(raw)},
+			},
+		},
+	}
+}
+
+func TestValidateMachine(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario       string
+		ProviderSpec   string
+		ExpectedErrors []string
+	}{
+		{
+			Scenario:     "valid",
+			ProviderSpec: validProviderSpec,
+		},
+		{
+			Scenario:     "other provider",
+			ProviderSpec: `{"apiVersion": "machine.openshift.io/v1beta1", "kind": "AWSMachineProviderConfig", "ami": {}}`,
+		},
+		{
+			Scenario: "unknown field",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"hostSelector": {"matchLabel": {"role": "worker"}}
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.hostSelector.matchLabel: Forbidden: unknown field`},
+		},
+		{
+			Scenario: "missing image",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"image": {"url": "http://172.22.0.1/images/rhcos.qcow2"}
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.image.checksum: Required value`},
+		},
+		{
+			Scenario: "missing image with customDeploy",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"}
+			}`,
+		},
+		{
+			Scenario: "invalid selector",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"hostSelector": {
+					"matchLabels": {"bad key": "worker"},
+					"matchExpressions": [
+						{"key": "role", "operator": "In", "values": ["worker"]},
+						{"key": "role", "operator": "contains", "values": ["worker"]},
+						{"key": "rack", "operator": "=", "values": ["a", "b"]}
+					]
+				}
+			}`,
+			ExpectedErrors: []string{
+				`spec.providerSpec.value.hostSelector.matchLabels[bad key].key: Invalid value: "bad key"`,
+				`spec.providerSpec.value.hostSelector.matchExpressions[1].operator: Unsupported value: "contains"`,
+				`spec.providerSpec.value.hostSelector.matchExpressions[2].values: Invalid value`,
+			},
+		},
+		{
+			Scenario: "numeric and existence operators",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"hostSelector": {
+					"matchExpressions": [
+						{"key": "ram-gb", "operator": "Gt", "values": ["256"]},
+						{"key": "cpus", "operator": "Lt", "values": ["128"]},
+						{"key": "role", "operator": "Exists"},
+						{"key": "maintenance", "operator": "DoesNotExist"}
+					]
+				}
+			}`,
+		},
+		{
+			Scenario: "operator value cardinality",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"hostSelector": {
+					"matchExpressions": [
+						{"key": "ram-gb", "operator": "Gt", "values": ["256", "512"]},
+						{"key": "cpus", "operator": "Lt", "values": ["many"]},
+						{"key": "role", "operator": "Exists", "values": ["worker"]},
+						{"key": "role", "operator": "In"}
+					]
+				}
+			}`,
+			ExpectedErrors: []string{
+				`spec.providerSpec.value.hostSelector.matchExpressions[0].values: Invalid value`,
+				`spec.providerSpec.value.hostSelector.matchExpressions[1].values[0]: Invalid value: "many"`,
+				`spec.providerSpec.value.hostSelector.matchExpressions[2].values: Invalid value`,
+				`spec.providerSpec.value.hostSelector.matchExpressions[3].values: Invalid value`,
+			},
+		},
+		{
+			Scenario: "celSelector",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"hostSelector": {"celSelector": "host.status.hardware.nics.filter(n, n.speedGbps >= 25).size() >= 2"}
+			}`,
+		},
+		{
+			Scenario: "invalid celSelector",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"},
+				"useHostClaim": true,
+				"hostSelector": {"celSelector": "size(host.metadata.name)"}
+			}`,
+			ExpectedErrors: []string{
+				`spec.providerSpec.value.hostSelector.celSelector: Invalid value: "size(host.metadata.name)": expression must evaluate to a bool`,
+				`spec.providerSpec.value.hostSelector.celSelector: Forbidden: cannot be combined with useHostClaim`,
+			},
+		},
+		{
+			Scenario: "valid v1beta1",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1beta1",
+				"kind": "BareMetalMachineProviderSpec",
+				"image": {
+					"url": "http://172.22.0.1/images/rhcos.qcow2",
+					"checksum": "http://172.22.0.1/images/rhcos.qcow2.md5sum"
+				},
+				"dataSecrets": {"userData": {"name": "worker-user-data"}},
+				"selection": {"matchLabels": {"role": "worker"}, "claimMode": "HostClaim"}
+			}`,
+		},
+		{
+			Scenario: "invalid v1beta1",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1beta1",
+				"kind": "BareMetalMachineProviderSpec",
+				"deploy": {"customDeploy": {"method": "install_coreos"}},
+				"selection": {"claimMode": "Shared"},
+				"hostSelector": {}
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.hostSelector: Forbidden: unknown field`},
+		},
+		{
+			Scenario: "v1beta1 field errors",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1beta1",
+				"kind": "BareMetalMachineProviderSpec",
+				"selection": {"claimMode": "Shared", "celSelector": "host.spec.online &&"},
+				"lifecycle": {"deletionPolicy": "Delete"}
+			}`,
+			ExpectedErrors: []string{
+				`spec.providerSpec.value.image: Required value`,
+				`spec.providerSpec.value.selection.claimMode: Unsupported value: "Shared"`,
+				`spec.providerSpec.value.selection.celSelector: Invalid value: "host.spec.online &&"`,
+				`spec.providerSpec.value.lifecycle.deletionPolicy: Unsupported value: "Delete"`,
+			},
+		},
+		{
+			Scenario: "valid without type metadata",
+			ProviderSpec: `{
+				"image": {"url": "http://172.22.0.1/images/rhcos.qcow2", "checksum": "http://172.22.0.1/images/rhcos.qcow2.md5sum"}
+			}`,
+		},
+		{
+			Scenario: "invalid without type metadata",
+			ProviderSpec: `{
+				"image": {"url": "http://172.22.0.1/images/rhcos.qcow2"},
+				"hostSelector": {"matchLabel": {"role": "worker"}}
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.hostSelector.matchLabel: Forbidden: unknown field`},
+		},
+		{
+			Scenario: "invalid without kind",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+				"image": {"url": "http://172.22.0.1/images/rhcos.qcow2"}
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.image.checksum: Required value`},
+		},
+		{
+			Scenario: "unsupported version",
+			ProviderSpec: `{
+				"apiVersion": "baremetal.cluster.k8s.io/v1",
+				"kind": "BareMetalMachineProviderSpec"
+			}`,
+			ExpectedErrors: []string{`spec.providerSpec.value.apiVersion: Unsupported value: "baremetal.cluster.k8s.io/v1"`},
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			w := &Machine{}
+			_, err := w.ValidateCreate(context.TODO(), machineWithProviderSpec(tc.ProviderSpec))
+			if len(tc.ExpectedErrors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				for _, expected := range tc.ExpectedErrors {
+					assert.Contains(t, err.Error(), expected)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMachineUpdate(t *testing.T) {
+	invalid := `{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec"}`
+	w := &Machine{}
+
+	// An existing invalid Machine can still be updated, e.g. to remove a
+	// finalizer
+	oldMachine := machineWithProviderSpec(invalid)
+	newMachine := oldMachine.DeepCopy()
+	newMachine.Finalizers = nil
+	_, err := w.ValidateUpdate(context.TODO(), oldMachine, newMachine)
+	assert.NoError(t, err)
+
+	// Changing the ProviderSpec validates it
+	_, err = w.ValidateUpdate(context.TODO(), machineWithProviderSpec(validProviderSpec), newMachine)
+	assert.Error(t, err)
+}
+
+func TestValidateDeletionPolicyAnnotation(t *testing.T) {
+	hostClaimProviderSpec := `{
+		"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+		"kind": "BareMetalMachineProviderSpec",
+		"customDeploy": {"method": "install_coreos"},
+		"useHostClaim": true
+	}`
+	w := &Machine{}
+
+	// The annotation is honoured without a HostClaim
+	machine := machineWithProviderSpec(validProviderSpec)
+	machine.Annotations = map[string]string{actuator.DeletionPolicyAnnotation: "Retain"}
+	_, err := w.ValidateCreate(context.TODO(), machine)
+	assert.NoError(t, err)
+
+	machine = machineWithProviderSpec(hostClaimProviderSpec)
+	machine.Annotations = map[string]string{actuator.DeletionPolicyAnnotation: "Deprovision"}
+	_, err = w.ValidateCreate(context.TODO(), machine)
+	assert.NoError(t, err)
+
+	// Adding it to a Machine using a HostClaim is rejected
+	newMachine := machine.DeepCopy()
+	newMachine.Annotations[actuator.DeletionPolicyAnnotation] = "Retain"
+	_, err = w.ValidateUpdate(context.TODO(), machine, newMachine)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(),
+			`metadata.annotations[metal3.io/deletion-policy]: Invalid value: "Retain": only Deprovision is supported with useHostClaim`)
+	}
+
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms1", Namespace: "myns"},
+	}
+	ms.Spec.Template.Spec.ProviderSpec = machineWithProviderSpec(hostClaimProviderSpec).Spec.ProviderSpec
+	ms.Spec.Template.Annotations = map[string]string{actuator.DeletionPolicyAnnotation: "Detach"}
+	_, err = (&MachineSet{}).ValidateCreate(context.TODO(), ms)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(),
+			`spec.template.metadata.annotations[metal3.io/deletion-policy]: Invalid value: "Detach"`)
+	}
+}
+
+func TestValidateMachineSet(t *testing.T) {
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms1", Namespace: "myns"},
+	}
+	ms.Spec.Template.Spec.ProviderSpec = machineWithProviderSpec(validProviderSpec).Spec.ProviderSpec
+	w := &MachineSet{}
+	_, err := w.ValidateCreate(context.TODO(), ms)
+	assert.NoError(t, err)
+
+	ms.Spec.Template.Spec.ProviderSpec.Value.Raw = []byte(`{
+		"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+		"kind": "BareMetalMachineProviderSpec",
+		"customDeploy": {"method": "install_coreos"},
+		"deletionPolicy": "Destroy"
+	}`)
+	_, err = w.ValidateCreate(context.TODO(), ms)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `spec.template.spec.providerSpec.value.deletionPolicy: Unsupported value: "Destroy"`)
+	}
+
+	// A ProviderSpec without type metadata is validated as v1alpha1
+	ms.Spec.Template.Spec.ProviderSpec.Value.Raw = []byte(`{
+		"customDeploy": {"method": "install_coreos"},
+		"deletionPolicy": "Destroy"
+	}`)
+	_, err = w.ValidateCreate(context.TODO(), ms)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `spec.template.spec.providerSpec.value.deletionPolicy: Unsupported value: "Destroy"`)
+	}
+}
+
+func TestValidateMachineSetAutoScaleAnnotations(t *testing.T) {
+	ms := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms1", Namespace: "myns"},
+	}
+	ms.Spec.Template.Spec.ProviderSpec = machineWithProviderSpec(validProviderSpec).Spec.ProviderSpec
+	ms.Annotations = map[string]string{
+		machineset.AutoScaleAnnotation:   "",
+		machineset.MinReplicasAnnotation: "2",
+		machineset.MaxReplicasAnnotation: "1",
+	}
+	w := &MachineSet{}
+	_, err := w.ValidateCreate(context.TODO(), ms)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "metadata.annotations: Invalid value")
+		assert.Contains(t, err.Error(), "must not be greater than")
+	}
+
+	// Invalid annotations are only rejected when they change, so the status
+	// can still be recorded
+	newMS := ms.DeepCopy()
+	newMS.Spec.Replicas = new(int32)
+	newMS.Annotations[machineset.AutoScaleStatusAnnotation] = `{"blocked": "InvalidAnnotations"}`
+	_, err = w.ValidateUpdate(context.TODO(), ms, newMS)
+	assert.NoError(t, err)
+
+	newMS.Annotations[machineset.MaxReplicasAnnotation] = "3"
+	newMS.Annotations[machineset.ScaleDownDelayAnnotation] = "soon"
+	_, err = w.ValidateUpdate(context.TODO(), ms, newMS)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "metal3.io/autoscale-scale-down-delay must be a non-negative duration")
+	}
+}
+
+func TestValidateMachineSetOverlap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, machinev1beta1.AddToScheme(scheme))
+	assert.NoError(t, bmh.AddToScheme(scheme))
+
+	newMS := func(name string) *machinev1beta1.MachineSet {
+		ms := &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "myns",
+				Annotations: map[string]string{machineset.AutoScaleAnnotation: ""},
+			},
+		}
+		ms.Spec.Selector.MatchLabels = map[string]string{"machine.openshift.io/cluster-api-machineset": name}
+		ms.Spec.Template.Spec.ProviderSpec = machineWithProviderSpec(validProviderSpec).Spec.ProviderSpec
+		return ms
+	}
+	host := &bmh.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "host1",
+			Namespace: "myns",
+			Labels:    map[string]string{"role": "worker"},
+		},
+		Status: bmh.BareMetalHostStatus{
+			Provisioning: bmh.ProvisionStatus{State: bmh.StateAvailable},
+		},
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(newMS("ms1"), host).Build()
+	w := &MachineSet{Client: c}
+
+	warnings, err := w.ValidateCreate(context.TODO(), newMS("ms2"))
+	assert.NoError(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0], "shares free BareMetalHosts with autoscaled MachineSets ms1")
+	}
+
+	// MachineSets that are not autoscaled do not count hosts
+	ms := newMS("ms2")
+	delete(ms.Annotations, machineset.AutoScaleAnnotation)
+	warnings, err = w.ValidateCreate(context.TODO(), ms)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/controller/machineset"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// MachineSet implements validation and defaulting webhooks for MachineSets
+// whose template has a BareMetalMachineProviderSpec.
+type MachineSet struct {
+	// Defaults sets the cluster-wide defaults on the ProviderSpec of new
+	// MachineSets. If nil, no defaults are set.
+	Defaults *ProviderSpecDefaulter
+	// Client is used to warn about autoscaled MachineSets that share free
+	// BareMetalHosts. If nil, no warnings are given.
+	Client client.Client
+}
+
+var _ crwebhook.CustomDefaulter = &MachineSet{}
+var _ crwebhook.CustomValidator = &MachineSet{}
+
+func (w *MachineSet) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&machinev1beta1.MachineSet{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+func (w *MachineSet) Default(ctx context.Context, obj runtime.Object) error {
+	ms, ok := obj.(*machinev1beta1.MachineSet)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a MachineSet but got a %T", obj))
+	}
+	return w.Defaults.defaultProviderSpec(ctx, &ms.Spec.Template.Spec.ProviderSpec)
+}
+
+func (w *MachineSet) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ms, ok := obj.(*machinev1beta1.MachineSet)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a MachineSet but got a %T", obj))
+	}
+	if err := validateMachineSet(ms); err != nil {
+		return nil, err
+	}
+	return w.overlapWarnings(ctx, ms), nil
+}
+
+// ValidateUpdate only validates the ProviderSpec, the deletion policy
+// annotation of the template and the autoscaling annotations if they changed, so that MachineSets created before the webhook
+// was installed can still be scaled.
+func (w *MachineSet) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldMS, ok := oldObj.(*machinev1beta1.MachineSet)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a MachineSet but got a %T", oldObj))
+	}
+	ms, ok := newObj.(*machinev1beta1.MachineSet)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a MachineSet but got a %T", newObj))
+	}
+	allErrs := field.ErrorList{}
+	if !equality.Semantic.DeepEqual(oldMS.Spec.Template.Spec.ProviderSpec, ms.Spec.Template.Spec.ProviderSpec) {
+		allErrs = append(allErrs, validateProviderSpec(&ms.Spec.Template.Spec.ProviderSpec,
+			field.NewPath("spec", "template", "spec", "providerSpec"))...)
+	}
+	if !equality.Semantic.DeepEqual(oldMS.Spec.Template.Spec.ProviderSpec, ms.Spec.Template.Spec.ProviderSpec) ||
+		oldMS.Spec.Template.Annotations[actuator.DeletionPolicyAnnotation] !=
+			ms.Spec.Template.Annotations[actuator.DeletionPolicyAnnotation] {
+		allErrs = append(allErrs, validateTemplateDeletionPolicyAnnotation(ms)...)
+	}
+	for _, key := range machineset.AutoScalePolicyAnnotations {
+		if oldMS.Annotations[key] != ms.Annotations[key] {
+			allErrs = append(allErrs, validateAutoScaleAnnotations(ms)...)
+			break
+		}
+	}
+	if err := machineSetError(ms, allErrs); err != nil {
+		return nil, err
+	}
+	return w.overlapWarnings(ctx, ms), nil
+}
+
+func (w *MachineSet) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// overlapWarnings warns when an autoscaled MachineSet shares free hosts with
+// other autoscaled MachineSets, since only one of them counts each shared
+// host.
+func (w *MachineSet) overlapWarnings(ctx context.Context, ms *machinev1beta1.MachineSet) admission.Warnings {
+	if w.Client == nil {
+		return nil
+	}
+	if _, autoscale := ms.Annotations[machineset.AutoScaleAnnotation]; !autoscale {
+		return nil
+	}
+	overlapping, err := machineset.OverlappingMachineSets(ctx, w.Client, ms)
+	if err != nil {
+		// the controller reports whatever prevents it from counting hosts
+		return nil
+	}
+	if len(overlapping) == 0 {
+		return nil
+	}
+	return admission.Warnings{fmt.Sprintf(
+		"MachineSet %s shares free BareMetalHosts with autoscaled MachineSets %s; each shared host is only counted by the MachineSet with the highest %s",
+		ms.Name, strings.Join(overlapping, ", "), machineset.PriorityAnnotation)}
+}
+
+func validateMachineSet(ms *machinev1beta1.MachineSet) error {
+	allErrs := validateProviderSpec(&ms.Spec.Template.Spec.ProviderSpec,
+		field.NewPath("spec", "template", "spec", "providerSpec"))
+	allErrs = append(allErrs, validateTemplateDeletionPolicyAnnotation(ms)...)
+	allErrs = append(allErrs, validateAutoScaleAnnotations(ms)...)
+	return machineSetError(ms, allErrs)
+}
+
+// validateTemplateDeletionPolicyAnnotation checks the deletion policy
+// annotation the Machines of the MachineSet get from its template.
+func validateTemplateDeletionPolicyAnnotation(ms *machinev1beta1.MachineSet) field.ErrorList {
+	return validateDeletionPolicyAnnotation(ms.Spec.Template.Annotations, &ms.Spec.Template.Spec.ProviderSpec,
+		field.NewPath("spec", "template", "metadata", "annotations"))
+}
+
+// validateAutoScaleAnnotations checks the annotations that bound the replicas
+// of an autoscaled MachineSet.
+func validateAutoScaleAnnotations(ms *machinev1beta1.MachineSet) field.ErrorList {
+	if _, err := machineset.AutoScalePolicyFromAnnotations(ms.Annotations); err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("metadata", "annotations"), ms.Annotations, err.Error())}
+	}
+	return nil
+}
+
+func machineSetError(ms *machinev1beta1.MachineSet, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(machinev1beta1.GroupVersion.WithKind("MachineSet").GroupKind(), ms.Name, allErrs)
+}
@@ -19,9 +19,12 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/baremetal"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -40,6 +43,22 @@ const (
 var (
 	defaultTimeout = metav1.Duration{Duration: defaultDuration}
 	minTimeout     = metav1.Duration{Duration: minDuration}
+
+	// supportedRemediationStrategies are the strategies the Metal3Remediation
+	// controller implements.
+	supportedRemediationStrategies = []infrav1.RemediationType{
+		infrav1.RebootRemediationStrategy,
+		baremetal.PowerOffRemediationStrategy,
+		baremetal.ReprovisionRemediationStrategy,
+	}
+
+	// finalRemediationSteps are the steps that end a remediation, so no step
+	// can follow them.
+	finalRemediationSteps = []infrav1.RemediationType{
+		baremetal.PowerOffRemediationStrategy,
+		baremetal.DeleteMachineRemediationStep,
+		baremetal.QuarantineRemediationStep,
+	}
 )
 
 // Metal3Remediation implements validation and defaulting webhooks for Metal3Remediation.
@@ -90,11 +109,11 @@ func validateRemediation(r *infrav1.Metal3Remediation) error {
 		))
 	}
 
-	if r.Spec.Strategy.Type != infrav1.RebootRemediationStrategy {
-		allErrs = append(allErrs, field.Invalid(
+	if !slices.Contains(supportedRemediationStrategies, r.Spec.Strategy.Type) {
+		allErrs = append(allErrs, field.NotSupported(
 			field.NewPath("spec", "strategy", "type"),
 			r.Spec.Strategy.Type,
-			"only supported remediation strategy is reboot",
+			supportedRemediationStrategies,
 		))
 	}
 
@@ -106,8 +125,64 @@ func validateRemediation(r *infrav1.Metal3Remediation) error {
 		))
 	}
 
+	allErrs = append(allErrs, validateRemediationSteps(r.Annotations)...)
+	allErrs = append(allErrs, validateSoftPowerOffTimeout(r.Annotations)...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind("Metal3Remediation").GroupKind(), r.Name, allErrs)
 }
+
+// validateRemediationSteps checks the steps set by the
+// RemediationStepsAnnotation, if any.
+func validateRemediationSteps(annotations map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+	path := field.NewPath("metadata", "annotations").Key(baremetal.RemediationStepsAnnotation)
+
+	steps, err := baremetal.RemediationStepsFromAnnotations(annotations)
+	if err != nil {
+		return append(allErrs, field.Invalid(path, annotations[baremetal.RemediationStepsAnnotation], err.Error()))
+	}
+	if steps == nil {
+		return nil
+	}
+	if len(steps) == 0 {
+		return append(allErrs, field.Required(path, "at least one step is required"))
+	}
+
+	validTypes := append(slices.Clone(supportedRemediationStrategies),
+		baremetal.DeleteMachineRemediationStep, baremetal.QuarantineRemediationStep)
+	for i, step := range steps {
+		if !slices.Contains(validTypes, step.Type) {
+			allErrs = append(allErrs, field.NotSupported(path, step.Type, validTypes))
+		}
+		if slices.Contains(finalRemediationSteps, step.Type) && i != len(steps)-1 {
+			allErrs = append(allErrs, field.Invalid(path, step.Type,
+				fmt.Sprintf("step %d ends the remediation and must be the last step", i)))
+		}
+		if step.Timeout != nil && step.Timeout.Seconds() < minTimeout.Seconds() {
+			allErrs = append(allErrs, field.Invalid(path, step.Timeout,
+				fmt.Sprintf("step %d: min duration is %s", i, minTimeout.Duration)))
+		}
+		if step.RetryLimit < 0 {
+			allErrs = append(allErrs, field.Invalid(path, step.RetryLimit,
+				fmt.Sprintf("step %d: retry limit must not be negative", i)))
+		}
+		if step.SoftPowerOffTimeout != nil && step.SoftPowerOffTimeout.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(path, step.SoftPowerOffTimeout,
+				fmt.Sprintf("step %d: soft power off timeout must be positive", i)))
+		}
+	}
+	return allErrs
+}
+
+// validateSoftPowerOffTimeout checks the grace period set by the
+// SoftPowerOffTimeoutAnnotation, if any.
+func validateSoftPowerOffTimeout(annotations map[string]string) field.ErrorList {
+	if _, err := actuator.SoftPowerOffTimeout(annotations); err != nil {
+		path := field.NewPath("metadata", "annotations").Key(actuator.SoftPowerOffTimeoutAnnotation)
+		return field.ErrorList{field.Invalid(path, annotations[actuator.SoftPowerOffTimeoutAnnotation], err.Error())}
+	}
+	return nil
+}
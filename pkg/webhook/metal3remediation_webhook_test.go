@@ -0,0 +1,184 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/openshift/cluster-api-provider-baremetal/pkg/baremetal"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateRemediationStrategy(t *testing.T) {
+	remediation := &infrav1.Metal3Remediation{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "myns"},
+		Spec: infrav1.Metal3RemediationSpec{
+			Strategy: &infrav1.RemediationStrategy{
+				Type:       infrav1.RebootRemediationStrategy,
+				RetryLimit: 1,
+				Timeout:    &defaultTimeout,
+			},
+		},
+	}
+	w := &Metal3Remediation{}
+	_, err := w.ValidateCreate(context.TODO(), remediation)
+	assert.NoError(t, err)
+
+	remediation.Spec.Strategy.Type = baremetal.PowerOffRemediationStrategy
+	_, err = w.ValidateCreate(context.TODO(), remediation)
+	assert.NoError(t, err)
+
+	remediation.Spec.Strategy.Type = baremetal.ReprovisionRemediationStrategy
+	_, err = w.ValidateCreate(context.TODO(), remediation)
+	assert.NoError(t, err)
+
+	remediation.Spec.Strategy.Type = "Explode"
+	_, err = w.ValidateCreate(context.TODO(), remediation)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `spec.strategy.type: Unsupported value: "Explode"`)
+	}
+
+	template := &infrav1.Metal3RemediationTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "myns"},
+	}
+	template.Spec.Template.Spec = *remediation.Spec.DeepCopy()
+	_, err = (&Metal3RemediationTemplate{}).ValidateCreate(context.TODO(), template)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `spec.template.spec.strategy.type: Unsupported value: "Explode"`)
+	}
+	template.Spec.Template.Spec.Strategy.Type = baremetal.PowerOffRemediationStrategy
+	_, err = (&Metal3RemediationTemplate{}).ValidateCreate(context.TODO(), template)
+	assert.NoError(t, err)
+}
+
+func TestValidateRemediationSteps(t *testing.T) {
+	testCases := []struct {
+		Scenario string
+		Steps    string
+		Expected string
+	}{
+		{
+			Scenario: "escalating steps",
+			Steps:    `[{"type":"Reboot","timeout":"5m","retryLimit":2},{"type":"Reprovision","timeout":"30m"},{"type":"DeleteMachine"}]`,
+		},
+		{
+			Scenario: "fencing last",
+			Steps:    `[{"type":"Reboot"},{"type":"PowerOff"}]`,
+		},
+		{
+			Scenario: "not JSON",
+			Steps:    `Reboot,PowerOff`,
+			Expected: "must be a JSON list of steps",
+		},
+		{
+			Scenario: "no steps",
+			Steps:    `[]`,
+			Expected: "at least one step is required",
+		},
+		{
+			Scenario: "unknown step",
+			Steps:    `[{"type":"Explode"}]`,
+			Expected: `Unsupported value: "Explode"`,
+		},
+		{
+			Scenario: "step after the end",
+			Steps:    `[{"type":"Quarantine"},{"type":"Reboot"}]`,
+			Expected: "step 0 ends the remediation and must be the last step",
+		},
+		{
+			Scenario: "short timeout",
+			Steps:    `[{"type":"Reboot","timeout":"10s"}]`,
+			Expected: "step 0: min duration is 1m40s",
+		},
+		{
+			Scenario: "negative retry limit",
+			Steps:    `[{"type":"Reboot"},{"type":"Reprovision","retryLimit":-1}]`,
+			Expected: "step 1: retry limit must not be negative",
+		},
+		{
+			Scenario: "negative soft power off timeout",
+			Steps:    `[{"type":"Reboot","softPowerOffTimeout":"-1m"},{"type":"PowerOff"}]`,
+			Expected: "step 0: soft power off timeout must be positive",
+		},
+	}
+
+	for _, tc := range testCases {
+		template := &infrav1.Metal3RemediationTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "worker",
+				Namespace:   "myns",
+				Annotations: map[string]string{baremetal.RemediationStepsAnnotation: tc.Steps},
+			},
+		}
+		template.Spec.Template.Spec.Strategy = &infrav1.RemediationStrategy{
+			Type:       infrav1.RebootRemediationStrategy,
+			RetryLimit: 1,
+			Timeout:    &defaultTimeout,
+		}
+		_, err := (&Metal3RemediationTemplate{}).ValidateCreate(context.TODO(), template)
+		if tc.Expected == "" {
+			assert.NoError(t, err, tc.Scenario)
+			continue
+		}
+		if assert.Error(t, err, tc.Scenario) {
+			assert.Contains(t, err.Error(), `metadata.annotations[metal3.io/remediation-steps]`, tc.Scenario)
+			assert.Contains(t, err.Error(), tc.Expected, tc.Scenario)
+		}
+	}
+}
+
+func TestValidateSoftPowerOffTimeout(t *testing.T) {
+	testCases := []struct {
+		Scenario string
+		Timeout  string
+		Valid    bool
+	}{
+		{Scenario: "grace period", Timeout: "2m", Valid: true},
+		{Scenario: "no unit", Timeout: "120"},
+		{Scenario: "zero", Timeout: "0s"},
+		{Scenario: "negative", Timeout: "-2m"},
+	}
+
+	for _, tc := range testCases {
+		remediation := &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "machine1",
+				Namespace:   "myns",
+				Annotations: map[string]string{actuator.SoftPowerOffTimeoutAnnotation: tc.Timeout},
+			},
+			Spec: infrav1.Metal3RemediationSpec{
+				Strategy: &infrav1.RemediationStrategy{
+					Type:       infrav1.RebootRemediationStrategy,
+					RetryLimit: 1,
+					Timeout:    &defaultTimeout,
+				},
+			},
+		}
+		_, err := (&Metal3Remediation{}).ValidateCreate(context.TODO(), remediation)
+		if tc.Valid {
+			assert.NoError(t, err, tc.Scenario)
+			continue
+		}
+		if assert.Error(t, err, tc.Scenario) {
+			assert.Contains(t, err.Error(), `metadata.annotations[metal3.io/soft-power-off-timeout]`, tc.Scenario)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -95,11 +96,11 @@ func validateRemediationTemplate(m3rt *infrav1.Metal3RemediationTemplate) error
 		))
 	}
 
-	if m3rt.Spec.Template.Spec.Strategy.Type != infrav1.RebootRemediationStrategy {
-		allErrs = append(allErrs, field.Invalid(
+	if !slices.Contains(supportedRemediationStrategies, m3rt.Spec.Template.Spec.Strategy.Type) {
+		allErrs = append(allErrs, field.NotSupported(
 			field.NewPath("spec", "template", "spec", "strategy", "type"),
 			m3rt.Spec.Template.Spec.Strategy.Type,
-			"only supported remediation strategy is reboot",
+			supportedRemediationStrategies,
 		))
 	}
 
@@ -111,6 +112,9 @@ func validateRemediationTemplate(m3rt *infrav1.Metal3RemediationTemplate) error
 		))
 	}
 
+	allErrs = append(allErrs, validateRemediationSteps(m3rt.Annotations)...)
+	allErrs = append(allErrs, validateSoftPowerOffTimeout(m3rt.Annotations)...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	bmv1beta1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1beta1"
+	actuator "github.com/openshift/cluster-api-provider-baremetal/pkg/cloud/baremetal/actuators/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kjson "sigs.k8s.io/json"
+)
+
+// providerSpecVersion returns the version of a BareMetalMachineProviderSpec,
+// or an empty string if the ProviderSpec is not a BareMetalMachineProviderSpec.
+// The ProviderSpecs of other providers are left to their own webhooks. Like
+// the actuator, a ProviderSpec without apiVersion is read as a v1alpha1
+// BareMetalMachineProviderSpec, so it is validated as one.
+func providerSpecVersion(providerSpec *machinev1beta1.ProviderSpec) string {
+	if providerSpec.Value == nil || len(providerSpec.Value.Raw) == 0 {
+		return ""
+	}
+	if !actuator.IsBareMetalProviderSpec(providerSpec) {
+		return ""
+	}
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(providerSpec.Value.Raw, &typeMeta); err != nil {
+		return ""
+	}
+	if typeMeta.APIVersion == "" {
+		return bmv1alpha1.SchemeGroupVersion.Version
+	}
+	gv, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return ""
+	}
+	return gv.Version
+}
+
+// decodeStrict reads raw into obj, and returns the unknown and duplicate
+// fields as errors with paths relative to valuePath.
+func decodeStrict(raw []byte, obj interface{}, valuePath *field.Path) field.ErrorList {
+	strictErrs, err := kjson.UnmarshalStrict(raw, obj)
+	if err != nil {
+		return field.ErrorList{field.Invalid(valuePath, field.OmitValueType{}, err.Error())}
+	}
+	allErrs := field.ErrorList{}
+	for _, strictErr := range strictErrs {
+		msg := strictErr.Error()
+		if name, ok := strings.CutPrefix(msg, "unknown field "); ok {
+			allErrs = append(allErrs, field.Forbidden(valuePath.Child(strings.Trim(name, `"`)), "unknown field"))
+		} else {
+			allErrs = append(allErrs, field.Invalid(valuePath, field.OmitValueType{}, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateProviderSpec returns the problems with a BareMetalMachineProviderSpec,
+// with paths relative to fldPath. Unknown fields are rejected, as the actuator
+// would fail to read them.
+func validateProviderSpec(providerSpec *machinev1beta1.ProviderSpec, fldPath *field.Path) field.ErrorList {
+	valuePath := fldPath.Child("value")
+	switch version := providerSpecVersion(providerSpec); version {
+	case "":
+		return nil
+	case bmv1beta1.SchemeGroupVersion.Version:
+		config := &bmv1beta1.BareMetalMachineProviderSpec{}
+		if allErrs := decodeStrict(providerSpec.Value.Raw, config, valuePath); len(allErrs) > 0 {
+			return allErrs
+		}
+		return append(config.Validate(valuePath), actuator.ValidateCELSelector(config.Selection.CELSelector,
+			valuePath.Child("selection", "celSelector"))...)
+	case bmv1alpha1.SchemeGroupVersion.Version:
+		config := &bmv1alpha1.BareMetalMachineProviderSpec{}
+		if allErrs := decodeStrict(providerSpec.Value.Raw, config, valuePath); len(allErrs) > 0 {
+			return allErrs
+		}
+		return append(config.Validate(valuePath), actuator.ValidateCELSelector(config.HostSelector.CELSelector,
+			valuePath.Child("hostSelector", "celSelector"))...)
+	default:
+		return field.ErrorList{field.NotSupported(valuePath.Child("apiVersion"),
+			bmv1alpha1.SchemeGroupVersion.Group+"/"+version,
+			[]string{bmv1alpha1.SchemeGroupVersion.String(), bmv1beta1.SchemeGroupVersion.String()})}
+	}
+}
+
+// validateDeletionPolicyAnnotation rejects a DeletionPolicyAnnotation other
+// than Deprovision in the annotations of Machines that claim their host
+// through a HostClaim, since the host of a HostClaim is always deprovisioned.
+func validateDeletionPolicyAnnotation(annotations map[string]string, providerSpec *machinev1beta1.ProviderSpec,
+	fldPath *field.Path) field.ErrorList {
+	value, ok := annotations[actuator.DeletionPolicyAnnotation]
+	if !ok || bmv1alpha1.DeletionPolicy(value) == bmv1alpha1.DeletionPolicyDeprovision ||
+		providerSpecVersion(providerSpec) == "" || !actuator.UsesHostClaim(providerSpec) {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(fldPath.Key(actuator.DeletionPolicyAnnotation), value,
+		"only Deprovision is supported with useHostClaim")}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	bmv1beta1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// ProviderSpecDefaultsKey is the key of the ConfigMap data that holds the
+// ProviderSpecDefaults, as YAML.
+const ProviderSpecDefaultsKey = "defaults.yaml"
+
+// RBAC to read the ConfigMap holding the ProviderSpecDefaults
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// ProviderSpecDefaults are the cluster-wide defaults for the
+// BareMetalMachineProviderSpecs of new Machines and MachineSets.
+type ProviderSpecDefaults struct {
+	// Image is used if the ProviderSpec has no image, customDeploy or
+	// adoptExternallyProvisioned.
+	Image bmv1alpha1.Image `json:"image,omitempty"`
+
+	// ChecksumType is used if the image of the ProviderSpec has a checksum
+	// but no checksumType.
+	ChecksumType string `json:"checksumType,omitempty"`
+
+	// UserDataNamespace is used if the userData of the ProviderSpec has no
+	// namespace.
+	UserDataNamespace string `json:"userDataNamespace,omitempty"`
+
+	// HostSelector is merged into the hostSelector of the ProviderSpec.
+	// Labels and expressions for keys the ProviderSpec already selects on
+	// are skipped, and hostNamespaces and celSelector are only used if the
+	// ProviderSpec has none. The celSelector is not used with useHostClaim.
+	HostSelector bmv1alpha1.HostSelector `json:"hostSelector,omitempty"`
+}
+
+// Apply sets the defaults on the fields of the ProviderSpec that are not set.
+func (d *ProviderSpecDefaults) Apply(config *bmv1alpha1.BareMetalMachineProviderSpec) {
+	if config.Image.URL == "" && config.Image.Checksum == "" &&
+		config.CustomDeploy.Method == "" && !config.AdoptExternallyProvisioned {
+		config.Image = d.Image
+	}
+	if config.Image.Checksum != "" && config.Image.ChecksumType == "" {
+		config.Image.ChecksumType = d.ChecksumType
+	}
+
+	if config.UserData != nil && config.UserData.Namespace == "" {
+		config.UserData.Namespace = d.UserDataNamespace
+	}
+
+	selected := map[string]bool{}
+	for key := range config.HostSelector.MatchLabels {
+		selected[key] = true
+	}
+	for _, req := range config.HostSelector.MatchExpressions {
+		selected[req.Key] = true
+	}
+	for key, value := range d.HostSelector.MatchLabels {
+		if selected[key] {
+			continue
+		}
+		if config.HostSelector.MatchLabels == nil {
+			config.HostSelector.MatchLabels = map[string]string{}
+		}
+		config.HostSelector.MatchLabels[key] = value
+	}
+	for _, req := range d.HostSelector.MatchExpressions {
+		if !selected[req.Key] {
+			config.HostSelector.MatchExpressions = append(config.HostSelector.MatchExpressions, req)
+		}
+	}
+	if len(config.HostSelector.HostNamespaces) == 0 {
+		config.HostSelector.HostNamespaces = slices.Clone(d.HostSelector.HostNamespaces)
+	}
+	if config.HostSelector.CELSelector == "" && !config.UseHostClaim {
+		config.HostSelector.CELSelector = d.HostSelector.CELSelector
+	}
+}
+
+// ProviderSpecDefaulter sets the ProviderSpecDefaults stored in a ConfigMap
+// on new BareMetalMachineProviderSpecs.
+type ProviderSpecDefaulter struct {
+	Client client.Reader
+	// ConfigMap holding the ProviderSpecDefaults. If it does not exist, no
+	// defaults are set.
+	ConfigMap types.NamespacedName
+}
+
+// defaults returns the ProviderSpecDefaults, or nil if there are none.
+func (d *ProviderSpecDefaulter) defaults(ctx context.Context) (*ProviderSpecDefaults, error) {
+	if d == nil || d.ConfigMap.Name == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	err := d.Client.Get(ctx, d.ConfigMap, cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s: %w", d.ConfigMap, err)
+	}
+	data, ok := cm.Data[ProviderSpecDefaultsKey]
+	if !ok {
+		return nil, nil
+	}
+	defaults := &ProviderSpecDefaults{}
+	if err := yaml.UnmarshalStrict([]byte(data), defaults); err != nil {
+		return nil, fmt.Errorf("invalid %s in ConfigMap %s: %w", ProviderSpecDefaultsKey, d.ConfigMap, err)
+	}
+	return defaults, nil
+}
+
+// defaultProviderSpec sets the defaults on a BareMetalMachineProviderSpec
+// when the object is created. The defaults are applied to the v1alpha1
+// version, and only the top-level fields that changed in the version as
+// written are rewritten, so the rest of the ProviderSpec is left as is.
+// ProviderSpecs that cannot be read are left for the validating webhook to
+// reject.
+func (d *ProviderSpecDefaulter) defaultProviderSpec(ctx context.Context, providerSpec *machinev1beta1.ProviderSpec) error {
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Operation != admissionv1.Create {
+		return nil
+	}
+
+	config := &bmv1alpha1.BareMetalMachineProviderSpec{}
+	var versioned conversion.Convertible
+	switch providerSpecVersion(providerSpec) {
+	case bmv1alpha1.SchemeGroupVersion.Version:
+		if len(decodeStrict(providerSpec.Value.Raw, config, nil)) > 0 {
+			return nil
+		}
+	case bmv1beta1.SchemeGroupVersion.Version:
+		versioned = &bmv1beta1.BareMetalMachineProviderSpec{}
+		if len(decodeStrict(providerSpec.Value.Raw, versioned, nil)) > 0 {
+			return nil
+		}
+		if err := versioned.ConvertTo(config); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	defaults, err := d.defaults(ctx)
+	if err != nil || defaults == nil {
+		return err
+	}
+	original := config.DeepCopy()
+	defaults.Apply(config)
+	if equality.Semantic.DeepEqual(original, config) {
+		return nil
+	}
+
+	oldFields, err := fieldsInVersion(original, versioned)
+	if err != nil {
+		return err
+	}
+	newFields, err := fieldsInVersion(config, versioned)
+	if err != nil {
+		return err
+	}
+	value := map[string]interface{}{}
+	if err := json.Unmarshal(providerSpec.Value.Raw, &value); err != nil {
+		return err
+	}
+	for key, newField := range newFields {
+		if !equality.Semantic.DeepEqual(oldFields[key], newField) {
+			value[key] = newField
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	providerSpec.Value.Raw = raw
+	return nil
+}
+
+// fieldsInVersion returns the top-level fields of the v1alpha1 config
+// converted to the version of versioned, or of config itself if versioned is
+// nil.
+func fieldsInVersion(config *bmv1alpha1.BareMetalMachineProviderSpec, versioned conversion.Convertible) (map[string]interface{}, error) {
+	var obj interface{} = config
+	if versioned != nil {
+		converted := versioned.DeepCopyObject().(conversion.Convertible)
+		if err := converted.ConvertFrom(config); err != nil {
+			return nil, err
+		}
+		obj = converted
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
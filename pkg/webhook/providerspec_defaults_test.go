@@ -0,0 +1,179 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	bmv1alpha1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1alpha1"
+	bmv1beta1 "github.com/openshift/cluster-api-provider-baremetal/pkg/apis/baremetal/v1beta1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const testDefaults = `
+image:
+  url: http://172.22.0.1/images/rhcos.qcow2
+  checksum: http://172.22.0.1/images/rhcos.qcow2.sha256sum
+checksumType: sha256
+userDataNamespace: openshift-machine-api
+hostSelector:
+  matchLabels:
+    role: worker
+    datacenter: dc1
+  matchExpressions:
+  - key: rack
+    operator: in
+    values: [a, b]
+`
+
+func newTestDefaulter(defaults string) *ProviderSpecDefaulter {
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	key := types.NamespacedName{Namespace: "openshift-machine-api", Name: "baremetal-provider-defaults"}
+	builder := fakeclient.NewClientBuilder().WithScheme(scheme)
+	if defaults != "" {
+		builder = builder.WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string]string{ProviderSpecDefaultsKey: defaults},
+		})
+	}
+	return &ProviderSpecDefaulter{Client: builder.Build(), ConfigMap: key}
+}
+
+func TestDefaultMachine(t *testing.T) {
+	w := &Machine{Defaults: newTestDefaulter(testDefaults)}
+	m := machineWithProviderSpec(`{
+		"apiVersion": "baremetal.cluster.k8s.io/v1alpha1",
+		"kind": "BareMetalMachineProviderSpec",
+		"userData": {"name": "worker-user-data"},
+		"hostSelector": {"matchLabels": {"role": "storage"}}
+	}`)
+	assert.NoError(t, w.Default(context.TODO(), m))
+
+	config := bmv1alpha1.BareMetalMachineProviderSpec{}
+	assert.NoError(t, json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &config))
+	assert.Equal(t, bmv1alpha1.Image{
+		URL:          "http://172.22.0.1/images/rhcos.qcow2",
+		Checksum:     "http://172.22.0.1/images/rhcos.qcow2.sha256sum",
+		ChecksumType: "sha256",
+	}, config.Image)
+	assert.Equal(t, "openshift-machine-api", config.UserData.Namespace)
+	assert.Equal(t, map[string]string{"role": "storage", "datacenter": "dc1"}, config.HostSelector.MatchLabels)
+	assert.Equal(t, []bmv1alpha1.HostSelectorRequirement{
+		{Key: "rack", Operator: "in", Values: []string{"a", "b"}},
+	}, config.HostSelector.MatchExpressions)
+	assert.Equal(t, "BareMetalMachineProviderSpec", config.Kind)
+
+	// The defaulted ProviderSpec is valid
+	_, err := w.ValidateCreate(context.TODO(), m)
+	assert.NoError(t, err)
+}
+
+func TestDefaultMachineV1beta1(t *testing.T) {
+	w := &Machine{Defaults: newTestDefaulter(testDefaults)}
+	m := machineWithProviderSpec(`{
+		"apiVersion": "baremetal.cluster.k8s.io/v1beta1",
+		"kind": "BareMetalMachineProviderSpec",
+		"dataSecrets": {"userData": {"name": "worker-user-data"}},
+		"selection": {"matchLabels": {"role": "storage"}, "claimMode": "HostClaim"}
+	}`)
+	assert.NoError(t, w.Default(context.TODO(), m))
+
+	raw := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &raw))
+	assert.Equal(t, "baremetal.cluster.k8s.io/v1beta1", raw["apiVersion"])
+	assert.NotContains(t, raw, "hostSelector")
+	assert.NotContains(t, raw, "deploy")
+
+	config := bmv1beta1.BareMetalMachineProviderSpec{}
+	assert.NoError(t, json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &config))
+	assert.Equal(t, &bmv1beta1.Image{
+		URL:          "http://172.22.0.1/images/rhcos.qcow2",
+		Checksum:     "http://172.22.0.1/images/rhcos.qcow2.sha256sum",
+		ChecksumType: "sha256",
+	}, config.Image)
+	assert.Equal(t, "openshift-machine-api", config.DataSecrets.UserData.Namespace)
+	assert.Equal(t, map[string]string{"role": "storage", "datacenter": "dc1"}, config.Selection.MatchLabels)
+	assert.Equal(t, bmv1beta1.ClaimModeHostClaim, config.Selection.ClaimMode)
+
+	_, err := w.ValidateCreate(context.TODO(), m)
+	assert.NoError(t, err)
+}
+
+func TestDefaultMachineUnchanged(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario     string
+		Defaults     string
+		Operation    admissionv1.Operation
+		ProviderSpec string
+	}{
+		{
+			Scenario:     "no defaults",
+			ProviderSpec: `{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec"}`,
+		},
+		{
+			Scenario:     "update",
+			Defaults:     testDefaults,
+			Operation:    admissionv1.Update,
+			ProviderSpec: `{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec"}`,
+		},
+		{
+			Scenario:     "other provider",
+			Defaults:     testDefaults,
+			ProviderSpec: `{"apiVersion": "machine.openshift.io/v1beta1", "kind": "AWSMachineProviderConfig"}`,
+		},
+		{
+			Scenario:     "unknown field",
+			Defaults:     testDefaults,
+			ProviderSpec: `{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec", "imag": {}}`,
+		},
+		{
+			Scenario: "nothing to default",
+			Defaults: "checksumType: sha256\n",
+			ProviderSpec: `{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec",
+				"customDeploy": {"method": "install_coreos"}}`,
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			w := &Machine{Defaults: newTestDefaulter(tc.Defaults)}
+			m := machineWithProviderSpec(tc.ProviderSpec)
+			ctx := context.TODO()
+			if tc.Operation != "" {
+				ctx = admission.NewContextWithRequest(ctx, admission.Request{
+					AdmissionRequest: admissionv1.AdmissionRequest{Operation: tc.Operation},
+				})
+			}
+			assert.NoError(t, w.Default(ctx, m))
+			assert.Equal(t, tc.ProviderSpec, string(m.Spec.ProviderSpec.Value.Raw))
+		})
+	}
+}
+
+func TestDefaultMachineInvalidDefaults(t *testing.T) {
+	w := &Machine{Defaults: newTestDefaulter("imageURL: http://172.22.0.1/images/rhcos.qcow2\n")}
+	m := machineWithProviderSpec(`{"apiVersion": "baremetal.cluster.k8s.io/v1alpha1", "kind": "BareMetalMachineProviderSpec"}`)
+	assert.Error(t, w.Default(context.TODO(), m))
+}